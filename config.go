@@ -1,9 +1,54 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// stringList is a repeatable flag value, also accepting comma separated items
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// Profile is a named set of probing defaults for an environment. Flags
+// given explicitly override the profile's values.
+type Profile struct {
+	Interval time.Duration // time between two probes of a host
+	Timeout  time.Duration // replies slower than this count as lost (-max-rtt)
+	Misses   int           // unanswered probes before a host is offline (-misses)
+}
+
+// profiles selectable with -profile. custom holds the built-in defaults,
+// to be tuned with flags.
+var profiles = map[string]Profile{
+	"lan":    {Interval: 500 * time.Millisecond, Timeout: 200 * time.Millisecond, Misses: 3},
+	"wan":    {Interval: 2 * time.Second, Timeout: 1500 * time.Millisecond, Misses: 3},
+	"custom": {Interval: monitor.DefaultProbeInterval, Timeout: 0, Misses: 2},
+}
+
+// webPassEnv is the environment variable -web-pass falls back to
+const webPassEnv = "MPING_WEB_PASS"
+
 type Config struct {
 	Quiet             bool
 	Privileged        bool
@@ -15,45 +60,500 @@ type Config struct {
 	Tui               bool
 	NoTui             bool
 	HostFile          string
+	HostFileWatch     bool
 	WebPort           int
+	WebAddr           string
 	PprofAddr         string
 	Once              bool
 	OnlyOnline        bool
 	OnlyOffline       bool
 	Debug             bool
 	NoDNS             bool
+	MaxPPS            int
+	LogHeader         bool
+	LogFormat         string
+	LogMaxSize        int
+	Template          string
+	LastReplyOnline   bool
+	StartupTimeout    time.Duration
+	Exclude           stringList
+	MaxRTT            time.Duration
+	OnlineMaxRTT      time.Duration
+	DryRun            bool
+	JitterStart       bool
+	Tmux              bool
+	TmuxColor         bool
+	ICMPRetries       int
+	WebToken          string
+	WebUser           string
+	WebPass           string
+	WebCert           string
+	WebKey            string
+	LossThreshold     float64
+	RTTWarn           int
+	Webhook           string
+	DB                string
+	MQTTBroker        string
+	MQTTPrefix        string
+	InfluxURL         string
+	InfluxDB          string
+	InfluxInterval    time.Duration
+	Bell              bool
+	RTTCrit           int
+	LossWindow        time.Duration
+	EmptyFilterRevert time.Duration
+	ShowTarget        bool
+	Inline            bool
+	HTTPInsecure      bool
+	Shuffle           bool
+	IncludeNetwork    bool
+	MaxCIDR           int
+	DNSTTL            time.Duration
+	DNSNegativeTTL    time.Duration
+	DNSCacheSize      int
+	SessionFile       string
+	NoViewState       bool
+	Profile           string
+	JSONCase          string
+	Duration          time.Duration
+	Interval          time.Duration
+	Timeout           time.Duration
+	Misses            int
+	Seed              uint64
+	Widths            ColumnWidths
+	SelfTest          bool
+	OnceCount         int
+	OnceTimeout       time.Duration
+	FailOn            string
+	Stream            bool
+	OnceSort          string
+	OnceFormat        string
+	NagiosWarn        DownThreshold
+	NagiosCrit        DownThreshold
+	NoBanner          bool
 	Args              []string
+
+	set map[string]bool // flags given explicitly on the command line
 }
 
 func LoadConfig() *Config {
-	c := &Config{}
+	c := &Config{
+		NagiosWarn: DownThreshold{Value: 1},
+		NagiosCrit: DownThreshold{Value: 100, Percent: true},
+	}
 
 	flag.BoolVar(&c.Privileged, "privileged", false, "switch to privileged mode (default if run as root or on windows; ineffective with '-s')")
 	flag.IntVar(&c.Size, "size", 24, "pure-go ICMP packet size (without header's 28 Bytes (note: values to test common limits: 1472 or 8972))\nnot relevant for system's ping, refer to system's ping man page and ping-options option")
 	flag.BoolVar(&c.System, "s", false, "uses system's ping")
 	flag.StringVar(&c.SystemPingOptions, "ping-options", "", "quoted options to provide to system's ping (ex: \"-Q 2\"), implies '-s', refer to system's ping man page")
 	flag.BoolVar(&c.Quiet, "q", false, "quiet mode, disable live update")
+	flag.BoolVar(&c.NoBanner, "no-banner", false, "do not print the version banner in -q/-notui mode, for clean piped output")
+	flag.StringVar(&c.Profile, "profile", "custom", "probing defaults for an environment: lan (500ms interval, 200ms max-rtt, 3 misses), wan (2s interval, 1.5s max-rtt, 3 misses) or custom (1s interval, no max-rtt, 2 misses); explicit -interval, -max-rtt and -misses override")
+	flag.DurationVar(&c.Interval, "interval", monitor.DefaultProbeInterval, fmt.Sprintf("time between two probes of a host, independent of the TUI update rate (min %s; not applied to system's ping)", monitor.MinProbeInterval))
+	flag.DurationVar(&c.Timeout, "timeout", monitor.DefaultProbeTimeout, "time a probe waits for its reply before it counts as a miss, also in -once mode (not applied to system's ping)")
+	flag.IntVar(&c.Misses, "misses", 2, "consecutive unanswered probes before a host is shown offline")
+	flag.DurationVar(&c.MaxRTT, "max-rtt", 0, "treat replies slower than this as lost, e.g. 2s (0 = every reply counts)")
+	flag.DurationVar(&c.OnlineMaxRTT, "online-max-rtt", 0, "hosts replying slower than this are shown as degraded instead of online, e.g. 500ms (0 = any reply is online)")
 	flag.StringVar(&c.Log, "log", "", "transition log `filename`")
+	flag.StringVar(&c.Webhook, "webhook", "", "POST a JSON payload (host, ip, state, timestamp; text/content for Slack and Discord) to this `URL` on every transition")
+	flag.StringVar(&c.DB, "db", "", "record every transition (host, ip, timestamp, transition, state) in the `SQLite database` at this path, created if missing (builds with -tags sqlite only)")
+	flag.StringVar(&c.MQTTBroker, "mqtt-broker", "", "publish each host's state (online/offline) on transitions as a retained message to the MQTT broker at this `URL`, mqtt://[user:pass@]host[:port] or mqtts:// for TLS")
+	flag.StringVar(&c.MQTTPrefix, "mqtt-prefix", "mping", "topic prefix of -mqtt-broker messages, published to <prefix>/<host>/status")
+	flag.StringVar(&c.InfluxURL, "influx-url", "", "push per-host metrics (up, rtt in ms, loss %) in the line protocol to the InfluxDB server at this `URL`, e.g. http://localhost:8086 (needs -influx-db)")
+	flag.StringVar(&c.InfluxDB, "influx-db", "", "InfluxDB database the -influx-url metrics are written to")
+	flag.DurationVar(&c.InfluxInterval, "influx-interval", 10*time.Second, "time between two -influx-url samples of every host")
+	flag.StringVar(&c.LogFormat, "log-format", "json", "transition log format: json (one object per line) or csv (with a column header line)")
+	flag.IntVar(&c.LogMaxSize, "log-max-size", 0, "rotate the transition log at this size in MB: it is renamed with a timestamp suffix and a new one started (0 = never)")
+	flag.BoolVar(&c.LogHeader, "log-header", false, "write a header record (version, start time, hosts and resolved IPs, config) at the start of the transition log")
+	flag.BoolVar(&c.SelfTest, "selftest", false, "check ICMP capabilities (127.0.0.1 and ::1, privileged and unprivileged) and the web port, print an environment summary and exit")
+	flag.BoolVar(&c.DryRun, "dry-run", false, "parse, expand and resolve hosts, print a summary with errors and the effective config, then exit")
 	flag.BoolVar(&c.Update, "update", false, "check and update to latest version (source github)")
 	flag.BoolVar(&c.Tui, "tui", true, "use interactive TUI mode (default) (deprecated, use -notui)")
 	flag.BoolVar(&c.NoTui, "notui", false, "disable interactive TUI mode")
-	flag.StringVar(&c.HostFile, "hostfile", "", "file with hosts (one per line, CIDR allowed)")
+	flag.StringVar(&c.HostFile, "hostfile", "", "file with hosts (one per line, CIDR allowed, exclude=<ip|cidr> lines to skip addresses, # comments, optional expect=up|down, group=<name> and alias after a host, [name] lines grouping the hosts after them)")
+	flag.BoolVar(&c.HostFileWatch, "hostfile-watch", false, "reload the host file when it changes, replacing the monitored hosts")
+	flag.Var(&c.Exclude, "exclude", "IP, CIDR or host to skip after expansion (repeatable or comma separated), e.g. -exclude 10.0.0.1")
 	flag.IntVar(&c.WebPort, "web-port", 8080, "port for web status server in TUI mode (0 to disable)")
+	flag.StringVar(&c.WebAddr, "web-addr", "127.0.0.1", "address the web status server binds to, a host or host:port (the port replaces -web-port); 0.0.0.0 listens on all interfaces")
+	flag.StringVar(&c.WebUser, "web-user", "", "require HTTP basic auth with this user on every web endpoint (needs -web-pass)")
+	flag.StringVar(&c.WebPass, "web-pass", "", "password for -web-user, read from $"+webPassEnv+" when unset (keeps it out of the process list)")
+	flag.StringVar(&c.WebCert, "web-cert", "", "PEM certificate file to serve the web status server over HTTPS (needs -web-key)")
+	flag.StringVar(&c.WebKey, "web-key", "", "PEM private key file for -web-cert")
+	flag.StringVar(&c.JSONCase, "json-case", "snake", "key casing of the web server's /json: snake (last_reply) or camel (lastReply)")
+	flag.StringVar(&c.WebToken, "web-token", "", "token required by mutating web endpoints such as POST /reset (Authorization: Bearer <token>); they are disabled when unset")
 	flag.StringVar(&c.PprofAddr, "pprof", "", "start pprof http server at this addr (e.g., localhost:6060); disabled by default")
+	flag.DurationVar(&c.Duration, "duration", 0, "run for this long, then exit and print an end-of-session summary (uptime, transitions, worst hosts), e.g. 1h (0 = until quit)")
 	flag.BoolVar(&c.Once, "once", false, "ping once and exit")
+	flag.DurationVar(&c.OnceTimeout, "once-timeout", 0, "overall deadline for -once, hosts without a result by then are reported as timed out, e.g. 30s (0 = wait for all)")
+	flag.IntVar(&c.OnceCount, "once-count", 1, "probes sent per host in once mode, online if any is answered")
+	flag.StringVar(&c.OnceSort, "once-sort", "ip", "order of once mode results: ip, name or status (online first)")
+	flag.StringVar(&c.OnceFormat, "once-format", "table", "once mode output: table, or nagios for a Nagios/Icinga plugin status line and exit status")
+	flag.Var(&c.NagiosWarn, "nagios-warn", "-once-format nagios: hosts down for WARNING, a count or a percentage like 10% (0 = never)")
+	flag.Var(&c.NagiosCrit, "nagios-crit", "-once-format nagios: hosts down for CRITICAL, a count or a percentage like 10% (0 = never)")
+	flag.BoolVar(&c.Stream, "stream", false, "once mode: print each result as soon as it is known instead of all at the end")
+	flag.StringVar(&c.FailOn, "fail-on", "any", "once mode exit status: any = 1 if some hosts and 2 if all are down, all = 2 only if all are down, none = always 0")
+	flag.BoolVar(&c.Tmux, "tmux", false, "ping each host once, print online/total (e.g. 2/2) for a tmux status line and exit (exit code 1 unless all are up)")
+	flag.BoolVar(&c.TmuxColor, "tmux-color", false, "wrap -tmux output in tmux color markup (green when all up, red otherwise)")
+	flag.StringVar(&c.Template, "template", "", "Go text/template applied to each result in once mode instead of the table (fields: .IP .Hostname .Status .Online .RTT .Loss), e.g. '{{.IP}},{{.Online}}'")
 	flag.BoolVar(&c.OnlyOnline, "only-online", false, "show only online hosts (initial filter)")
 	flag.BoolVar(&c.OnlyOffline, "only-offline", false, "show only offline hosts (initial filter)")
+	flag.DurationVar(&c.EmptyFilterRevert, "empty-filter-revert", 0, "in the TUI, switch back to showing all hosts when the filter matched none for this long, e.g. 30s (0 = never)")
+	flag.StringVar(&c.SessionFile, "session-file", "", "JSON file holding the host set with hidden hosts and expectations; loaded at startup if it exists (replacing host arguments), saved with Ctrl+S and reloaded with Ctrl+O in the TUI")
+	flag.BoolVar(&c.NoViewState, "no-view-state", false, "don't restore the TUI's filter, sort, update rate, columns and hidden hosts of the last run, nor save them on quit (kept in mping/view.json in the user's config directory)")
+	flag.BoolVar(&c.IncludeNetwork, "include-network", false, "keep the first (network) and last (broadcast) addresses when expanding CIDRs larger than /31")
+	flag.IntVar(&c.MaxCIDR, "max-cidr", 65536, "refuse to expand CIDRs with more addresses than this, e.g. an IPv6 /64 (0 = no limit)")
+	flag.BoolVar(&c.Shuffle, "shuffle", false, "randomize host order before starting probes so adjacent addresses don't probe together (display sorting is unaffected)")
+	flag.Uint64Var(&c.Seed, "seed", 0, "seed for -shuffle, for a reproducible order (0 = random)")
+	flag.BoolVar(&c.HTTPInsecure, "http-insecure", false, "skip TLS certificate verification for https:// hosts")
+	flag.BoolVar(&c.Inline, "inline", false, "render the TUI inline in the terminal instead of the alternate screen (for logging sessions and terminals that don't restore it)")
+	flag.BoolVar(&c.Bell, "bell", false, "in the TUI, ring the terminal bell when a host goes offline (at most every 30s per host)")
+	flag.BoolVar(&c.ShowTarget, "show-target", false, "in the TUI, show the host as given in parentheses after its DNS name when they differ (toggle with h)")
+	flag.IntVar(&c.Widths.Name, "name-width", defaultColumnWidths.Name, fmt.Sprintf("TUI Name column width (min %d)", minColumnWidths.Name))
+	flag.IntVar(&c.Widths.IP, "ip-width", defaultColumnWidths.IP, fmt.Sprintf("TUI IP column width (min %d)", minColumnWidths.IP))
+	flag.IntVar(&c.Widths.RTT, "rtt-width", defaultColumnWidths.RTT, fmt.Sprintf("TUI RTT column width (min %d)", minColumnWidths.RTT))
+	flag.IntVar(&c.Widths.LastReply, "last-reply-width", defaultColumnWidths.LastReply, fmt.Sprintf("TUI Last Reply column width (min %d)", minColumnWidths.LastReply))
+	flag.IntVar(&c.Widths.LastLoss, "last-loss-width", defaultColumnWidths.LastLoss, fmt.Sprintf("TUI Last Loss column width (min %d)", minColumnWidths.LastLoss))
+	flag.BoolVar(&c.LastReplyOnline, "last-reply-online", false, "show the Last Reply column for online hosts too (default: offline hosts only)")
 	flag.BoolVar(&c.Debug, "debug", false, "enable debug output")
+	flag.DurationVar(&c.StartupTimeout, "startup-timeout", 60*time.Second, "max time to wait for all hosts to start before the TUI opens (raise for very large subnets)")
 	flag.BoolVar(&c.NoDNS, "no-dns", false, "skip reverse DNS lookups (faster startup for large subnets)")
+	flag.DurationVar(&c.DNSTTL, "dns-ttl", monitor.DNSCacheTTL, "how long a reverse DNS name is cached before it is looked up again")
+	flag.DurationVar(&c.DNSNegativeTTL, "dns-negative-ttl", monitor.DNSCacheNegativeTTL, "how long a failed reverse DNS lookup is cached before it is retried")
+	flag.IntVar(&c.DNSCacheSize, "dns-cache-size", monitor.DNSCacheSize, "reverse DNS cache entries at most, one per IP")
+	flag.BoolVar(&c.JitterStart, "jitter-start", true, "delay each host's first probe by a random offset within the probe interval to spread probes over time (-jitter-start=false for deterministic start)")
+	flag.IntVar(&c.ICMPRetries, "icmp-retries", 3, "pure-go ping: retries with backoff when a send fails with a transient error such as ENOBUFS (0 = no retry)")
+	flag.IntVar(&c.RTTWarn, "rtt-warn", 100, "in the TUI and web view, color RTTs from this many milliseconds yellow instead of green (0 = never)")
+	flag.IntVar(&c.RTTCrit, "rtt-crit", 200, "in the TUI and web view, color RTTs from this many milliseconds red (0 = never)")
+	flag.Float64Var(&c.LossThreshold, "loss-threshold", 10, "packet loss percentage above which an online host is highlighted as lossy (0 = disabled; not available with system's ping)")
+	flag.DurationVar(&c.LossWindow, "loss-window", time.Minute, "recent time the Loss column and -loss-threshold are computed over, so past outages age out (0 = since start)")
+	flag.IntVar(&c.MaxPPS, "max-pps", 0, "cap aggregate probe rate across all hosts in packets per second, probes are paced not dropped (0 = unlimited; not applied to system's ping)")
 
 	flag.Usage = usage
-	flag.Parse()
+	// Parse errors exit like flag.ExitOnError does, but as UNKNOWN for a
+	// Nagios check, whose stdout is left to the status line
+	nagios := nagiosArgs(os.Args[1:])
+	if nagios {
+		flag.Usage = func() {}
+	}
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			if nagios {
+				usage()
+			}
+			os.Exit(0)
+		}
+		if nagios {
+			exitNagiosUnknown(err.Error())
+		}
+		os.Exit(2)
+	}
+
+	if c.WebPass == "" {
+		c.WebPass = os.Getenv(webPassEnv)
+	}
 
 	c.Args = flag.Args()
+	c.set = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { c.set[f.Name] = true })
+
+	c.applyProfile()
 
 	return c
 }
 
+// applyProfile fills in the -profile values for flags not given explicitly.
+// An unknown profile is left for Validate to report.
+func (c *Config) applyProfile() {
+	p, ok := profiles[c.Profile]
+	if !ok {
+		return
+	}
+	if !c.set["interval"] {
+		c.Interval = p.Interval
+	}
+	if !c.set["max-rtt"] {
+		c.MaxRTT = p.Timeout
+	}
+	if !c.set["misses"] {
+		c.Misses = p.Misses
+	}
+}
+
+// Validate checks for flag combinations that conflict or have no effect.
+// Conflicts are returned as an error; ineffective flags as warnings.
+func (c *Config) Validate() ([]string, error) {
+	if c.OnlyOnline && c.OnlyOffline {
+		return nil, errors.New("-only-online and -only-offline are mutually exclusive")
+	}
+	if c.set["tui"] && c.Tui && c.NoTui {
+		return nil, errors.New("-tui and -notui are mutually exclusive")
+	}
+	if _, ok := profiles[c.Profile]; !ok {
+		return nil, fmt.Errorf("unknown -profile %q (lan, wan or custom)", c.Profile)
+	}
+	if c.JSONCase != "snake" && c.JSONCase != "camel" {
+		return nil, fmt.Errorf("-json-case must be snake or camel, got %q", c.JSONCase)
+	}
+	if c.Duration < 0 {
+		return nil, errors.New("-duration must not be negative")
+	}
+	if c.LossWindow < 0 {
+		return nil, errors.New("-loss-window must not be negative")
+	}
+	if c.Interval < monitor.MinProbeInterval {
+		return nil, fmt.Errorf("-interval must be at least %s", monitor.MinProbeInterval)
+	}
+	if _, err := webListenAddr(c.WebAddr, c.WebPort); err != nil {
+		return nil, err
+	}
+	if _, _, err := net.SplitHostPort(c.WebAddr); err == nil && c.set["web-port"] {
+		return nil, fmt.Errorf("-web-addr %q already has a port, drop -web-port", c.WebAddr)
+	}
+	if (c.WebUser == "") != (c.WebPass == "") {
+		return nil, fmt.Errorf("-web-user and -web-pass (or $%s) must be set together", webPassEnv)
+	}
+	if (c.WebCert == "") != (c.WebKey == "") {
+		return nil, errors.New("-web-cert and -web-key must be set together")
+	}
+	if c.LogMaxSize < 0 {
+		return nil, errors.New("-log-max-size must not be negative")
+	}
+	if c.LogFormat != "json" && c.LogFormat != "csv" {
+		return nil, fmt.Errorf("-log-format must be json or csv, got %q", c.LogFormat)
+	}
+	if c.Timeout <= 0 {
+		return nil, errors.New("-timeout must be positive")
+	}
+	if c.MaxCIDR < 0 {
+		return nil, errors.New("-max-cidr must not be negative")
+	}
+	if c.DNSTTL <= 0 || c.DNSNegativeTTL <= 0 {
+		return nil, errors.New("-dns-ttl and -dns-negative-ttl must be positive")
+	}
+	if c.DNSCacheSize < 1 {
+		return nil, errors.New("-dns-cache-size must be at least 1")
+	}
+	if c.Misses < 1 {
+		return nil, errors.New("-misses must be at least 1")
+	}
+	if c.OnceFormat != "table" && c.OnceFormat != "nagios" {
+		return nil, fmt.Errorf("-once-format must be table or nagios, got %q", c.OnceFormat)
+	}
+	if c.OnceFormat == "nagios" && c.Template != "" {
+		return nil, errors.New("-template and -once-format nagios are mutually exclusive")
+	}
+	if !slices.Contains(onceSorts, c.OnceSort) {
+		return nil, fmt.Errorf("-once-sort must be ip, name or status, got %q", c.OnceSort)
+	}
+	if c.FailOn != "any" && c.FailOn != "all" && c.FailOn != "none" {
+		return nil, fmt.Errorf("-fail-on must be any, all or none, got %q", c.FailOn)
+	}
+	if c.OnceCount < 1 {
+		return nil, errors.New("-once-count must be at least 1")
+	}
+	if c.Webhook != "" {
+		if u, err := url.Parse(c.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("-webhook must be an http:// or https:// URL, got %q", c.Webhook)
+		}
+	}
+	if c.MQTTBroker != "" {
+		u, err := url.Parse(c.MQTTBroker)
+		if err != nil || !slices.Contains([]string{"mqtt", "mqtts", "tcp", "ssl"}, u.Scheme) || u.Hostname() == "" {
+			return nil, fmt.Errorf("-mqtt-broker must be an mqtt:// or mqtts:// URL, got %q", c.MQTTBroker)
+		}
+	}
+	if strings.ContainsAny(c.MQTTPrefix, "+#") {
+		return nil, errors.New("-mqtt-prefix must not contain the MQTT wildcards + and #")
+	}
+	if c.InfluxURL != "" {
+		if u, err := url.Parse(c.InfluxURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("-influx-url must be an http:// or https:// URL, got %q", c.InfluxURL)
+		}
+		if c.InfluxDB == "" {
+			return nil, errors.New("-influx-url needs -influx-db")
+		}
+	}
+	if c.InfluxInterval < time.Second {
+		return nil, errors.New("-influx-interval must be at least 1s")
+	}
+	if c.RTTWarn < 0 || c.RTTCrit < 0 {
+		return nil, errors.New("-rtt-warn and -rtt-crit must not be negative")
+	}
+	if c.RTTWarn > 0 && c.RTTCrit > 0 && c.RTTWarn >= c.RTTCrit {
+		return nil, fmt.Errorf("-rtt-warn %d must be below -rtt-crit %d", c.RTTWarn, c.RTTCrit)
+	}
+
+	for _, w := range []struct {
+		name     string
+		val, min int
+	}{
+		{"name-width", c.Widths.Name, minColumnWidths.Name},
+		{"ip-width", c.Widths.IP, minColumnWidths.IP},
+		{"rtt-width", c.Widths.RTT, minColumnWidths.RTT},
+		{"last-reply-width", c.Widths.LastReply, minColumnWidths.LastReply},
+		{"last-loss-width", c.Widths.LastLoss, minColumnWidths.LastLoss},
+	} {
+		if w.val < w.min {
+			return nil, fmt.Errorf("-%s must be at least %d", w.name, w.min)
+		}
+	}
+
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	system := c.System || c.SystemPingOptions != ""
+	tuiMode := c.Tui && !c.NoTui && !c.Quiet && !c.Once
+
+	if c.Quiet && c.set["tui"] && c.Tui {
+		warn("-q disables the TUI, -tui is ignored")
+	}
+	if c.Once && (c.set["tui"] || c.NoTui) {
+		warn("-once does not use the TUI, -tui/-notui are ignored")
+	}
+	if !tuiMode {
+		for _, name := range []string{"web-port", "web-addr", "web-token", "web-user", "web-pass", "web-cert", "web-key", "json-case", "last-reply-online", "startup-timeout", "empty-filter-revert", "show-target", "inline", "no-view-state", "rtt-warn", "rtt-crit", "bell",
+			"name-width", "ip-width", "rtt-width", "last-reply-width", "last-loss-width"} {
+			if c.set[name] {
+				warn("-%s only applies to TUI mode and is ignored", name)
+			}
+		}
+	}
+	if c.NoBanner && (tuiMode || c.Once) {
+		warn("-no-banner only applies to -q/-notui mode and is ignored")
+	}
+	if c.set["seed"] && !c.Shuffle {
+		warn("-seed has no effect without -shuffle")
+	}
+	for _, name := range []string{"once-count", "once-timeout", "fail-on", "stream", "once-sort", "once-format"} {
+		if c.set[name] && !c.Once {
+			warn("-%s only applies to -once and is ignored", name)
+		}
+	}
+	for _, name := range []string{"nagios-warn", "nagios-crit"} {
+		if c.set[name] && c.OnceFormat != "nagios" {
+			warn("-%s has no effect without -once-format nagios", name)
+		}
+	}
+	for _, name := range []string{"fail-on", "stream"} {
+		if c.set[name] && c.Once && c.OnceFormat == "nagios" {
+			warn("-%s is ignored with -once-format nagios", name)
+		}
+	}
+	if c.Duration > 0 && (c.Once || c.Tmux || c.DryRun) {
+		warn("-duration only applies to continuous monitoring and is ignored")
+	}
+	if c.Webhook != "" && (c.Once || c.Tmux || c.DryRun) {
+		warn("-webhook only applies to continuous monitoring and is ignored")
+	}
+	if c.DB != "" && (c.Once || c.Tmux || c.DryRun) {
+		warn("-db only applies to continuous monitoring and is ignored")
+	}
+	if c.MQTTBroker != "" && (c.Once || c.Tmux || c.DryRun) {
+		warn("-mqtt-broker only applies to continuous monitoring and is ignored")
+	}
+	if c.set["mqtt-prefix"] && c.MQTTBroker == "" {
+		warn("-mqtt-prefix has no effect without -mqtt-broker")
+	}
+	if c.InfluxURL != "" && (c.Once || c.Tmux || c.DryRun) {
+		warn("-influx-url only applies to continuous monitoring and is ignored")
+	}
+	for _, name := range []string{"influx-db", "influx-interval"} {
+		if c.set[name] && c.InfluxURL == "" {
+			warn("-%s has no effect without -influx-url", name)
+		}
+	}
+	if c.Timeout > c.Interval && !c.Once {
+		warn("-timeout %s exceeds the probe interval %s, probes of a host overlap", c.Timeout, c.Interval)
+	}
+	if c.Template != "" && !c.Once {
+		warn("-template only applies to -once and is ignored")
+	}
+	if c.HostFileWatch && c.HostFile == "" {
+		warn("-hostfile-watch has no effect without -hostfile")
+	} else if c.HostFileWatch && (c.Once || c.Tmux || c.DryRun) {
+		warn("-hostfile-watch only applies to continuous monitoring and is ignored")
+	}
+	if c.TmuxColor && !c.Tmux {
+		warn("-tmux-color has no effect without -tmux")
+	}
+	if c.LogHeader && c.Log == "" {
+		warn("-log-header has no effect without -log")
+	}
+	if c.LogMaxSize > 0 && c.Log == "" {
+		warn("-log-max-size has no effect without -log")
+	}
+	if c.set["log-format"] && c.Log == "" {
+		warn("-log-format has no effect without -log")
+	} else if c.set["log-format"] && c.Once {
+		warn("-log-format only applies to the transition log, -once writes JSON")
+	}
+	if system {
+		if c.set["size"] {
+			warn("-size has no effect with system's ping, use -ping-options")
+		}
+		if c.Privileged {
+			warn("-privileged has no effect with system's ping")
+		}
+		if c.MaxPPS > 0 {
+			warn("-max-pps is not applied to system's ping")
+		}
+		if c.set["loss-threshold"] {
+			warn("-loss-threshold is not available with system's ping")
+		}
+		if c.set["loss-window"] {
+			warn("-loss-window is not available with system's ping")
+		}
+		if c.set["icmp-retries"] {
+			warn("-icmp-retries is not applied to system's ping")
+		}
+		if c.set["timeout"] {
+			warn("-timeout is not applied to system's ping, use -ping-options")
+		}
+		if c.Interval != monitor.DefaultProbeInterval {
+			warn("-interval is not applied to system's ping, use -ping-options")
+		}
+	}
+
+	return warnings, nil
+}
+
+// Summary returns the effective configuration values relevant to probing,
+// keyed by flag name.
+func (c *Config) Summary() map[string]string {
+	return map[string]string{
+		"privileged":      strconv.FormatBool(c.Privileged),
+		"size":            strconv.Itoa(c.Size),
+		"s":               strconv.FormatBool(c.System),
+		"ping-options":    c.SystemPingOptions,
+		"hostfile":        c.HostFile,
+		"no-dns":          strconv.FormatBool(c.NoDNS),
+		"max-pps":         strconv.Itoa(c.MaxPPS),
+		"max-rtt":         c.MaxRTT.String(),
+		"online-max-rtt":  c.OnlineMaxRTT.String(),
+		"loss-threshold":  strconv.FormatFloat(c.LossThreshold, 'f', -1, 64),
+		"loss-window":     c.LossWindow.String(),
+		"include-network": strconv.FormatBool(c.IncludeNetwork),
+		"max-cidr":        strconv.Itoa(c.MaxCIDR),
+		"profile":         c.Profile,
+		"interval":        c.Interval.String(),
+		"timeout":         c.Timeout.String(),
+		"misses":          strconv.Itoa(c.Misses),
+	}
+}
+
+// exitError reports an error found before probing and exits with code, or
+// as a Nagios UNKNOWN with -once-format nagios
+func (c *Config) exitError(code int, format string, args ...any) {
+	if c.OnceFormat == "nagios" {
+		exitNagiosUnknown(strings.TrimPrefix(fmt.Sprintf(format, args...), "error: "))
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(code)
+}
+
 // usage is moved here or imported from main if exported.
 // Since usage() uses VersionStringLong which is in main.go, we might have a cycle if we are not careful.
 // But they are in the same package 'main', so it's fine.
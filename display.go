@@ -5,11 +5,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/babs/multiping/monitor"
 	"github.com/pterm/pterm"
 )
 
 type Display struct {
-	pwh                 *WrapperHolder
+	pwh                 *monitor.WrapperHolder
 	noheader            bool
 	area                *pterm.AreaPrinter
 	host_format_string  string
@@ -18,7 +19,7 @@ type Display struct {
 	onlyOffline         bool
 }
 
-func NewDisplay(pwh *WrapperHolder) *Display {
+func NewDisplay(pwh *monitor.WrapperHolder) *Display {
 	return &Display{
 		pwh: pwh,
 	}
@@ -56,9 +57,9 @@ func (d *Display) Update() {
 	}
 
 	for _, wrapper := range d.pwh.Wrappers() {
-		stats := wrapper.CalcStats(2 * 1e9)
+		stats := wrapper.CalcStats(monitor.DefaultOfflineAfter)
 
-		isOnline := stats.state && stats.error_message == ""
+		isOnline := stats.State && stats.ErrorMessage == ""
 
 		if d.onlyOnline && !isOnline {
 			continue
@@ -74,22 +75,22 @@ func (d *Display) Update() {
 		}
 
 		sb.WriteString(fmt.Sprintf(d.host_format_string, displayName))
-		if stats.error_message != "" {
-			sb.WriteString(bold_red.Sprintf("❌ %v", stats.error_message))
-		} else if stats.last_seen_nano > 2*1e9 {
-			if stats.lastrecv == 0 {
+		if stats.ErrorMessage != "" {
+			sb.WriteString(bold_red.Sprintf("❌ %v", stats.ErrorMessage))
+		} else if stats.LastSeenNano > 2*1e9 {
+			if stats.LastRecv == 0 {
 				sb.WriteString(bold_red.Sprintf("❌ never had reply"))
 			} else {
-				sb.WriteString(bold_red.Sprintf("❌ last reply %s ago", time.Duration(stats.last_seen_nano).Round(time.Second)))
+				sb.WriteString(bold_red.Sprintf("❌ last reply %s ago", time.Duration(stats.LastSeenNano).Round(time.Second)))
 			}
 		} else {
-			sb.WriteString(bold_green.Sprintf("✅ %-8s", stats.lastrtt_as_string))
-			if stats.last_loss_nano > 0 {
+			sb.WriteString(bold_green.Sprintf("✅ %-8s", stats.LastRTTString))
+			if stats.LastLossNano > 0 {
 				last_log := fmt.Sprintf(
 					" (last loss %s: %s ago for %s)",
-					time.Unix(0, stats.last_loss_nano).Format("2006-01-02 15:04:05"),
-					time.Duration(time.Now().UnixNano()-stats.last_loss_nano).Round(time.Second),
-					time.Duration(stats.last_loss_duration).Round(time.Second/10),
+					time.Unix(0, stats.LastLossNano).Format("2006-01-02 15:04:05"),
+					time.Duration(time.Now().UnixNano()-stats.LastLossNano).Round(time.Second),
+					time.Duration(stats.LastLossDuration).Round(time.Second/10),
 				)
 				if d.longest_host_string+12+len(last_log) >= pterm.GetTerminalWidth() {
 					sb.WriteString(fmt.Sprintf("\n%"+fmt.Sprintf("%v", pterm.GetTerminalWidth())+"s", last_log))
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+// RunDryRun validates the host arguments and expanded hosts without starting
+// any wrapper, prints a summary to out and returns the process exit code.
+func RunDryRun(out io.Writer, config *Config, rawHosts, hosts []string) int {
+	var errs []string
+
+	// Arguments that look like a CIDR but failed to expand are typos,
+	// not host names
+	for _, arg := range rawHosts {
+		if !strings.Contains(arg, "/") || strings.Contains(arg, "://") {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(arg); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", arg, err))
+		}
+	}
+
+	for _, host := range hosts {
+		if monitor.IsSRVSpec(host) {
+			targets, err := monitor.ResolveSRV(host)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%v: %v", host, err))
+			} else if len(targets) == 0 {
+				errs = append(errs, fmt.Sprintf("%v: no SRV targets", host))
+			}
+			continue
+		}
+		if strings.Contains(host, "/") && !strings.Contains(host, "://") {
+			continue // already reported as an invalid CIDR
+		}
+		spec, err := monitor.ParseHostSpec(host)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if _, err := monitor.Resolve(spec.Host, spec.Family); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", host, err))
+		}
+	}
+
+	mode := "tui"
+	switch {
+	case config.Once:
+		mode = "once"
+	case config.Quiet:
+		mode = "quiet"
+	case !config.Tui:
+		mode = "notui"
+	}
+
+	fmt.Fprintf(out, "Dry run: %d hosts from %d arguments, mode %s\n", len(hosts), len(rawHosts), mode)
+
+	summary := config.Summary()
+	keys := make([]string, 0, len(summary))
+	for k := range summary {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintln(out, "Config:")
+	for _, k := range keys {
+		fmt.Fprintf(out, "  -%s=%s\n", k, summary[k])
+	}
+
+	if len(errs) == 0 {
+		fmt.Fprintln(out, "No errors")
+		return 0
+	}
+	fmt.Fprintf(out, "%d errors:\n", len(errs))
+	for _, e := range errs {
+		fmt.Fprintf(out, "  %s\n", e)
+	}
+	return 1
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// hostFileWatchInterval is how often -hostfile-watch checks the file's
+// modification time. A change is only loaded once the file kept the same
+// modification time for a whole interval, so an editor saving in several
+// writes triggers a single reload.
+const hostFileWatchInterval = time.Second
+
+// HostFileReload is the outcome of a reload by HostFileWatcher
+type HostFileReload struct {
+	Hosts int   // hosts after expansion and exclusions
+	Err   error // reading the host file or a host in it failed, the hosts were kept
+}
+
+// HostFileWatcher reloads the host file into a PingService whenever it
+// changes (-hostfile-watch). Hosts from the command line, -exclude and
+// -shuffle apply to every reload like at startup.
+type HostFileWatcher struct {
+	ps             *monitor.PingService
+	path           string
+	args           []string // host arguments, kept on every reload
+	excludes       []string // -exclude, combined with the file's exclude= lines
+	includeNetwork bool
+	shuffle        bool
+	seed           uint64
+	onReload       func(HostFileReload) // optional, called after each reload
+	loaded         time.Time            // modification time of the loaded file
+	stopChan       chan struct{}
+	running        bool
+	mu             sync.Mutex
+}
+
+// NewHostFileWatcher creates a watcher for the host file given in config.
+// The file is taken as loaded as it is now.
+func NewHostFileWatcher(ps *monitor.PingService, config *Config) *HostFileWatcher {
+	w := &HostFileWatcher{
+		ps:             ps,
+		path:           config.HostFile,
+		args:           config.Args,
+		excludes:       []string(config.Exclude),
+		includeNetwork: config.IncludeNetwork,
+		shuffle:        config.Shuffle,
+		seed:           config.Seed,
+	}
+	if fi, err := os.Stat(w.path); err == nil {
+		w.loaded = fi.ModTime()
+	}
+	return w
+}
+
+// SetReloadHandler sets the function called after each reload. Must be set
+// before Start.
+func (w *HostFileWatcher) SetReloadHandler(h func(HostFileReload)) {
+	w.onReload = h
+}
+
+// Start starts the goroutine polling the host file
+func (w *HostFileWatcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running {
+		return
+	}
+	w.stopChan = make(chan struct{})
+	w.running = true
+	go w.run(w.stopChan)
+}
+
+// Stop stops polling the host file
+func (w *HostFileWatcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.running {
+		return
+	}
+	close(w.stopChan)
+	w.running = false
+}
+
+func (w *HostFileWatcher) run(stop chan struct{}) {
+	ticker := time.NewTicker(hostFileWatchInterval)
+	defer ticker.Stop()
+
+	var pending time.Time // changed modification time waiting to settle
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		fi, err := os.Stat(w.path)
+		if err != nil {
+			// Editors replacing the file may briefly remove it
+			pending = time.Time{}
+			continue
+		}
+		mtime := fi.ModTime()
+		switch {
+		case mtime.Equal(w.loaded):
+			pending = time.Time{}
+		case !mtime.Equal(pending):
+			pending = mtime
+		default:
+			w.loaded = mtime
+			pending = time.Time{}
+			reload := w.reload()
+			if w.onReload != nil {
+				w.onReload(reload)
+			}
+		}
+	}
+}
+
+// reload reads the host file and replaces the hosts of the PingService.
+// On error the current hosts are kept.
+func (w *HostFileWatcher) reload() HostFileReload {
+	fileHosts, fileExcludes, fileExpect, fileAlias, fileGroup, err := loadHostsFromFile(w.path)
+	if err != nil {
+		return HostFileReload{Err: err}
+	}
+	hosts, expect, alias, group, err := expandHostSpecs(slices.Concat(fileHosts, w.args), fileExpect, fileAlias, fileGroup, w.includeNetwork)
+	if err != nil {
+		return HostFileReload{Err: err}
+	}
+	hosts, _ = ExcludeHosts(hosts, slices.Concat(w.excludes, fileExcludes))
+	if w.shuffle {
+		ShuffleHosts(hosts, w.seed)
+	}
+	if monitor.DebugMode {
+		fmt.Fprintf(os.Stderr, "DEBUG: Host file %s changed, reloading %d hosts\n", w.path, len(hosts))
+	}
+	if err := w.ps.ReplaceAnnotatedHosts(hosts, expect, alias, group); err != nil {
+		return HostFileReload{Err: err}
+	}
+	return HostFileReload{Hosts: len(hosts)}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	influxBufferSize = 10000           // points kept while the server is unreachable, the oldest are dropped
+	influxTimeout    = 5 * time.Second // per write request
+)
+
+// InfluxOptions configures the InfluxDB exporter, disabled without URL
+type InfluxOptions struct {
+	URL      string        // server base URL, e.g. http://localhost:8086
+	DB       string        // database written to
+	Interval time.Duration // time between two samples of every host
+}
+
+// InfluxExporter samples every host on its own ticker and writes the points
+// in the InfluxDB line protocol. It never computes stats itself: whoever
+// does, the TUI or the -notui loop, hands it the hosts' values with Update
+// and each sample stamps the latest of them. Points that could not be
+// written are kept and sent with the next sample, up to influxBufferSize.
+type InfluxExporter struct {
+	writeURL string
+	interval time.Duration
+	client   *http.Client
+	mu       sync.Mutex // guards latest
+	latest   []string   // points of the last Update, without timestamp
+	buf      []string   // lines not written yet, oldest first; owned by run
+	dropped  int64      // lines dropped from a full buffer; owned by run
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartInfluxExporter starts sampling the hosts passed to Update
+func StartInfluxExporter(opts InfluxOptions) (*InfluxExporter, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the query, InfluxDB 1.x takes credentials as u= and p=
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/write"
+	q := u.Query()
+	q.Set("db", opts.DB)
+	q.Set("precision", "ns")
+	u.RawQuery = q.Encode()
+
+	e := &InfluxExporter{
+		writeURL: u.String(),
+		interval: opts.Interval,
+		client:   &http.Client{Timeout: influxTimeout},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// Stop takes a last sample, writes the pending points, waiting at most one
+// request timeout, and stops the exporter. It is safe to call more than once.
+func (e *InfluxExporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+		<-e.done
+		if len(e.buf) > 0 {
+			fmt.Fprintf(os.Stderr, "influx: %d points not written\n", len(e.buf))
+		}
+		if e.dropped > 0 {
+			fmt.Fprintf(os.Stderr, "influx: %d points dropped while the server was unreachable\n", e.dropped)
+		}
+	})
+}
+
+func (e *InfluxExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			e.sample(now)
+			e.flush()
+		case <-e.stop:
+			e.sample(time.Now())
+			e.flush()
+			return
+		}
+	}
+}
+
+// Update replaces the hosts' values sampled from now on with points built
+// by influxPoint, one per monitored host
+func (e *InfluxExporter) Update(points []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.latest = points
+}
+
+// sample appends the latest point of every host, dropping the oldest points
+// past influxBufferSize
+func (e *InfluxExporter) sample(now time.Time) {
+	e.mu.Lock()
+	latest := e.latest
+	e.mu.Unlock()
+	for _, point := range latest {
+		e.buf = append(e.buf, fmt.Sprintf("%s %d", point, now.UnixNano()))
+	}
+	if excess := len(e.buf) - influxBufferSize; excess > 0 {
+		e.dropped += int64(excess)
+		e.buf = append(e.buf[:0], e.buf[excess:]...)
+	}
+}
+
+// flush writes the buffered points, keeping them for the next attempt on
+// failure
+func (e *InfluxExporter) flush() {
+	if len(e.buf) == 0 {
+		return
+	}
+	body := strings.Join(e.buf, "\n") + "\n"
+	resp, err := e.client.Post(e.writeURL, "text/plain; charset=utf-8", bytes.NewReader([]byte(body)))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("HTTP %s", resp.Status)
+		}
+	}
+	if err != nil {
+		if monitor.DebugMode {
+			fmt.Fprintf(os.Stderr, "DEBUG: influx write of %d points failed: %v\n", len(e.buf), err)
+		}
+		return
+	}
+	e.buf = e.buf[:0]
+}
+
+// influxPoint formats a host's point without its timestamp: up as 0/1, the
+// last RTT in ms once a reply was received and the loss percentage over
+// -loss-window once a probe was sent, e.g.
+//
+//	mping,host=gw,ip=10.0.0.1 up=1i,rtt=1.25,loss=0
+func influxPoint(wrapper monitor.PingWrapperInterface, stats *monitor.PWStats) string {
+	host := stats.GetHostRepr()
+	if host == "" {
+		host = wrapper.Host()
+	}
+	var b strings.Builder
+	b.WriteString("mping,host=")
+	b.WriteString(influxTagValue(host))
+	// Tag values can't be empty, e.g. before a name resolved
+	if stats.IPRepr != "" {
+		b.WriteString(",ip=")
+		b.WriteString(influxTagValue(stats.IPRepr))
+	}
+	up := 0
+	if stats.State && stats.ErrorMessage == "" {
+		up = 1
+	}
+	fmt.Fprintf(&b, " up=%di", up)
+	if stats.LastRecv > 0 {
+		fmt.Fprintf(&b, ",rtt=%g", float64(stats.LastRTT)/float64(time.Millisecond))
+	}
+	if stats.PacketsSent > 0 {
+		fmt.Fprintf(&b, ",loss=%g", stats.WindowLossPercent())
+	}
+	return b.String()
+}
+
+// influxTagValue escapes a line protocol tag value: comma, equals sign and
+// space
+func influxTagValue(v string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/babs/multiping/monitor"
+	"time"
+)
+
+// NewLogHeader builds a header for the given hosts and their wrappers,
+// which must be in the same order as created by InitHosts.
+func NewLogHeader(config *Config, hosts []string, wrappers []monitor.PingWrapperInterface) *monitor.LogHeader {
+	now := time.Now()
+	header := &monitor.LogHeader{
+		Type:      "header",
+		Version:   Version + "-" + CommitHash,
+		Timestamp: now.String(),
+		UnixNano:  now.UnixNano(),
+		Hosts:     make(map[string]string, len(hosts)),
+		Config:    config.Summary(),
+	}
+	for i, host := range hosts {
+		if i < len(wrappers) {
+			header.Hosts[host] = wrappers[i].Stats().IPRepr
+		}
+	}
+	return header
+}
@@ -5,10 +5,16 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/babs/multiping/monitor"
+	"io/fs"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
 )
 
@@ -16,97 +22,149 @@ var Version = "v1.0.6"
 var CommitHash = "dev"
 var BuildTimestamp = "1970-01-01T00:00:00"
 var Builder = "go version go1.xx.y os/platform"
-var DebugMode = false
-var SkipDNS = false
-
-// Options struct is replaced by Config in config.go, but we need to keep Options for compatibility 
-// with WrapperHolder.InitHosts signature if we don't change it.
-// However, I should update WrapperHolder to use Config or keep Options as an alias/adapter.
-// For now, let's adapt Config to Options or update WrapperHolder.
-// WrapperHolder.InitHosts takes Options. Let's update WrapperHolder to take Config.
-
-// But wait, I can't change WrapperHolder in this tool call.
-// I will define Options here as a type alias or just struct matching Config fields if needed, 
-// OR I will update WrapperHolder in the next step.
-// Actually, I can just update main to use Config, and create an Options struct that matches what WrapperHolder expects
-// populated from Config.
-// The original Options struct had pointers.
-type Options struct {
-	quiet               *bool
-	privileged          *bool
-	size                *int
-	system              *bool
-	log                 *string
-	update              *bool
-	system_ping_options *string
-	tui                 *bool
-	notui               *bool
-	hostfile            *string
-	webPort             *int
-	pprofAddr           *string
-}
 
 func main() {
 	config := LoadConfig()
 
+	warnings, err := config.Validate()
+	if err != nil {
+		config.exitError(2, "error: %v", err)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+
 	if config.Debug {
-		DebugMode = true
+		monitor.DebugMode = true
 	}
 
 	if config.NoDNS {
-		SkipDNS = true
+		monitor.SkipDNS = true
 	}
 
+	MaxCIDRAddresses = config.MaxCIDR
+	monitor.DNSCacheTTL = config.DNSTTL
+	monitor.DNSCacheNegativeTTL = config.DNSNegativeTTL
+	monitor.DNSCacheSize = config.DNSCacheSize
+
 	if config.NoTui {
 		config.Tui = false
 	}
 
+	var onceTemplate *template.Template
+	if config.Template != "" {
+		var err error
+		onceTemplate, err = template.New("once").Parse(config.Template)
+		if err != nil {
+			config.exitError(1, "invalid -template: %v", err)
+		}
+	}
+
+	webAddr, _ := webListenAddr(config.WebAddr, config.WebPort) // checked by Validate
+
+	if config.SelfTest {
+		os.Exit(RunSelfTest(os.Stdout, webAddr))
+	}
+
 	if config.PprofAddr != "" {
 		go startPprof(config.PprofAddr)
 	}
 
 	var rawHosts []string
+	var rawExpect map[string]string // host file expect= annotations
+	var rawAlias map[string]string  // host file aliases
+	var rawGroup map[string]string  // host file groups
+	excludes := []string(config.Exclude)
 	if config.HostFile != "" {
-		fileHosts, err := loadHostsFromFile(config.HostFile)
+		fileHosts, fileExcludes, fileExpect, fileAlias, fileGroup, err := loadHostsFromFile(config.HostFile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error reading host file: %v\n", err)
-			os.Exit(1)
+			config.exitError(1, "error reading host file: %v", err)
 		}
 		rawHosts = append(rawHosts, fileHosts...)
+		excludes = append(excludes, fileExcludes...)
+		rawExpect = fileExpect
+		rawAlias = fileAlias
+		rawGroup = fileGroup
 	}
 	rawHosts = append(rawHosts, config.Args...)
-	var hosts []string
 
-	for _, arg := range rawHosts {
-		// Try to expand as CIDR
-		ips, err := ExpandCIDR(arg)
-		if err == nil {
-			if DebugMode {
-				fmt.Fprintf(os.Stderr, "DEBUG: Expanded %s to %d IPs\n", arg, len(ips))
+	// An existing session replaces the hosts given otherwise, a missing one
+	// is created on the first save
+	var sessionHidden map[string]bool
+	sessionLoaded := false
+	if config.SessionFile != "" {
+		session, err := LoadSession(config.SessionFile)
+		switch {
+		case err == nil:
+			if len(rawHosts) > 0 {
+				fmt.Fprintf(os.Stderr, "Using the hosts of session %s, ignoring %d host arguments\n", config.SessionFile, len(rawHosts))
 			}
-			hosts = append(hosts, ips...)
-		} else {
-			// Not a CIDR, treat as single host
-			hosts = append(hosts, arg)
+			rawHosts, rawExpect, rawAlias, rawGroup, sessionHidden = session.Specs()
+			sessionLoaded = true
+		case !errors.Is(err, fs.ErrNotExist):
+			config.exitError(1, "error reading session file: %v", err)
 		}
 	}
+	hosts, expect, alias, group, err := expandHostSpecs(rawHosts, rawExpect, rawAlias, rawGroup, config.IncludeNetwork)
+	if err != nil {
+		config.exitError(1, "error: %v", err)
+	}
+
+	// Exclusions apply after expansion so "10.0.0.0/24 -exclude 10.0.0.1" works
+	if len(excludes) > 0 {
+		var excluded int
+		hosts, excluded = ExcludeHosts(hosts, excludes)
+		fmt.Fprintf(os.Stderr, "Excluded %d hosts\n", excluded)
+	}
 
-	if DebugMode {
+	// Probe scheduling follows host order, display order is sorted separately
+	if config.Shuffle {
+		ShuffleHosts(hosts, config.Seed)
+	}
+
+	if monitor.DebugMode {
 		fmt.Fprintf(os.Stderr, "DEBUG: Total hosts to ping: %d\n", len(hosts))
 	}
 
+	if config.DryRun {
+		os.Exit(RunDryRun(os.Stdout, config, rawHosts, hosts))
+	}
+
 	if config.Update {
 		selfUpdate()
 		return
 	}
 
+	if config.Tmux {
+		os.Exit(RunTmux(os.Stdout, hosts, config.TmuxColor, config.Timeout))
+	}
+
 	if config.Once {
 		if len(hosts) == 0 {
+			if config.OnceFormat == "nagios" {
+				exitNagiosUnknown("no host provided")
+			}
 			fmt.Println("no host provided")
 			return
 		}
-		RunPingOnce(hosts, config.OnlyOnline, config.OnlyOffline, config.Log)
-		return
+		summary := RunPingOnce(hosts, OnceOptions{
+			OnlyOnline:   config.OnlyOnline,
+			OnlyOffline:  config.OnlyOffline,
+			LogFile:      config.Log,
+			Template:     onceTemplate,
+			Count:        config.OnceCount,
+			Timeout:      config.OnceTimeout,
+			ProbeTimeout: config.Timeout,
+			Stream:       config.Stream,
+			Sort:         config.OnceSort,
+			Format:       config.OnceFormat,
+			NagiosWarn:   config.NagiosWarn,
+			NagiosCrit:   config.NagiosCrit,
+		})
+		if config.OnceFormat == "nagios" {
+			os.Exit(summary.NagiosState(config.NagiosWarn, config.NagiosCrit))
+		}
+		os.Exit(summary.ExitCode(config.FailOn))
 	}
 
 	if len(config.SystemPingOptions) > 0 {
@@ -118,58 +176,187 @@ func main() {
 		return
 	}
 
-	quitSig := make(chan bool)
 	quitFlag := false
 
-	transition_writer := &TransitionWriter{}
-	if config.Log != "" {
-		transition_writer.Init(config.Log, &quitFlag)
-		defer transition_writer.Close()
+	transition_writer := &monitor.TransitionWriter{}
+	if config.Webhook != "" {
+		webhook := NewWebhookSender(config.Webhook)
+		transition_writer.AddSink(webhook)
+		defer webhook.Close()
+	}
+	if config.DB != "" {
+		db, err := OpenTransitionDB(config.DB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		transition_writer.AddSink(db)
+		defer db.Close()
+	}
+	if config.MQTTBroker != "" {
+		mqtt, err := NewMQTTPublisher(config.MQTTBroker, config.MQTTPrefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		transition_writer.AddSink(mqtt)
+		defer mqtt.Close()
+	}
+
+	options := monitor.ProbeOptions{
+		Privileged:        config.Privileged,
+		Size:              config.Size,
+		System:            config.System,
+		SystemPingOptions: config.SystemPingOptions,
+		Limiter:           monitor.NewRateLimiter(config.MaxPPS),
+		MaxRTT:            config.MaxRTT,
+		OnlineMaxRTT:      config.OnlineMaxRTT,
+		JitterStart:       config.JitterStart,
+		ICMPRetries:       config.ICMPRetries,
+		LossThreshold:     config.LossThreshold,
+		LossWindow:        config.LossWindow,
+		HTTPInsecure:      config.HTTPInsecure,
+		Expect:            expect,
+		Alias:             alias,
+		Group:             group,
+		Interval:          config.Interval,
+		Timeout:           config.Timeout,
+		Misses:            config.Misses,
 	}
 
-	// Adapter for WrapperHolder which expects Options with pointers
-	// This is temporary until we refactor WrapperHolder to use Config
-	options := Options{
-		quiet:               &config.Quiet,
-		privileged:          &config.Privileged,
-		size:                &config.Size,
-		system:              &config.System,
-		log:                 &config.Log,
-		update:              &config.Update,
-		system_ping_options: &config.SystemPingOptions,
-		tui:                 &config.Tui,
-		notui:               &config.NoTui,
-		hostfile:            &config.HostFile,
-		webPort:             &config.WebPort,
-		pprofAddr:           &config.PprofAddr,
-	}
-
-	wh := &WrapperHolder{}
 	// Initialize Repository and Service
-	repo := NewMemoryHostRepository()
-	ps := NewPingService(repo, options, transition_writer)
+	repo := monitor.NewMemoryHostRepository()
+	ps := monitor.NewPingService(repo, options, transition_writer)
 	ps.InitHosts(hosts)
 
+	var watcher *HostFileWatcher
+	if config.HostFileWatch && config.HostFile != "" {
+		if sessionLoaded {
+			fmt.Fprintf(os.Stderr, "Using the hosts of session %s, not watching %s\n", config.SessionFile, config.HostFile)
+		} else {
+			watcher = NewHostFileWatcher(ps, config)
+		}
+	}
+
+	// Opened after InitHosts so the header can list resolved IPs
+	if config.Log != "" {
+		var header *monitor.LogHeader
+		if config.LogHeader {
+			header = NewLogHeader(config, hosts, repo.GetAll())
+		}
+		transition_writer.Init(config.Log, config.LogFormat, int64(config.LogMaxSize)<<20, &quitFlag, header)
+		defer transition_writer.Close()
+	}
+
+	influx := InfluxOptions{URL: config.InfluxURL, DB: config.InfluxDB, Interval: config.InfluxInterval}
+
+	start := time.Now()
+
 	// TUI mode (default, interactive)
 	if config.Tui && !config.Quiet {
 		initialFilter := determineInitialFilter(config.OnlyOnline, config.OnlyOffline)
-		ps.Start()
-		err := RunTUI(ps, repo, transition_writer, initialFilter, config.WebPort)
+		var viewStateFile string
+		if !config.NoViewState {
+			var err error
+			if viewStateFile, err = defaultViewStatePath(); err != nil {
+				fmt.Fprintf(os.Stderr, "Not restoring the view: %v\n", err)
+			}
+		}
+		// RunTUI starts the wrappers itself, with progress and timeout handling
+		err := RunTUI(ps, repo, transition_writer, TUIOptions{
+			InitialFilter:   initialFilter,
+			WebAddr:         webAddr,
+			LastReplyOnline: config.LastReplyOnline,
+			StartupTimeout:  config.StartupTimeout,
+			WebToken:        config.WebToken,
+			WebUser:         config.WebUser,
+			WebPass:         config.WebPass,
+			WebCert:         config.WebCert,
+			WebKey:          config.WebKey,
+			EmptyRevert:     config.EmptyFilterRevert,
+			ShowTarget:      config.ShowTarget,
+			Inline:          config.Inline,
+			IncludeNetwork:  config.IncludeNetwork,
+			SessionFile:     config.SessionFile,
+			ViewStateFile:   viewStateFile,
+			Bell:            config.Bell,
+			JSONCase:        config.JSONCase,
+			Hidden:          sessionHidden,
+			Widths:          config.Widths,
+			RTTBands:        RTTBands{Warn: time.Duration(config.RTTWarn) * time.Millisecond, Crit: time.Duration(config.RTTCrit) * time.Millisecond},
+			Duration:        config.Duration,
+			HostFileWatcher: watcher,
+			Influx:          influx,
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "TUI error: %v\n", err)
+			transition_writer.Close()
 			os.Exit(1)
 		}
+		if config.Duration > 0 {
+			WriteSessionSummary(os.Stdout, repo.GetAll(), time.Since(start))
+		}
 		return
-	} else {
+	}
+
+	if !config.NoBanner {
 		fmt.Print(VersionString())
-		for !quitFlag {
-			wh.CalcStats(2 * 1e9)
-			time.Sleep(100 * time.Millisecond)
+	}
+	// Interrupting ends the run like -duration, so the deferred Close
+	// flushes the transition log
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	interrupted := false
+
+	// Without a display, stats are only computed to drive transitions
+	ps.Start()
+	if watcher != nil {
+		watcher.SetReloadHandler(func(r HostFileReload) {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "Reloading host file failed, keeping the hosts: %v\n", r.Err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Reloaded host file (%d hosts)\n", r.Hosts)
+		})
+		watcher.Start()
+	}
+	var exporter *InfluxExporter
+	if influx.URL != "" {
+		var err error
+		exporter, err = StartInfluxExporter(influx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
 	}
-
-	<-quitSig
-
+	for !interrupted && (config.Duration == 0 || time.Since(start) < config.Duration) {
+		var points []string
+		for _, wrapper := range repo.GetAll() {
+			stats := wrapper.CalcStats(monitor.DefaultOfflineAfter)
+			if exporter != nil {
+				points = append(points, influxPoint(wrapper, &stats))
+			}
+		}
+		if exporter != nil {
+			exporter.Update(points)
+		}
+		select {
+		case <-interrupt:
+			interrupted = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if exporter != nil {
+		exporter.Stop()
+	}
+	if watcher != nil {
+		watcher.Stop()
+	}
+	ps.Stop()
+	quitFlag = true
+	if config.Duration > 0 {
+		WriteSessionSummary(os.Stdout, repo.GetAll(), time.Since(start))
+	}
 }
 
 func VersionString() string {
@@ -201,6 +388,9 @@ Hosts can have the following form:
 - hostname or ip or ip://hostname => ping (implementation used depends on '-s' flag)
 - tcp://hostname:port or tcp://[ipv6]:port => tcp probing
     While using ip addresses, tcp:// can take IPv4 or IPv6 (w/ brackets), tcp4:// can only take IPv4 and tcp6:// only IPv6 (w/ brackets)
+- srv://_service._proto.domain => tcp probing of every target:port of the SRV record, re-resolved periodically
+- http://host[:port]/path or https://... => HTTP GET, online on 2xx/3xx (-http-insecure skips TLS verification)
+- dns://resolver[:port][/name] => A query for name (default example.com) to the resolver, online on any answer incl. NXDOMAIN
 
 Hint on address family can be provided with the following form:
 - ip://hostname and tcp://hostname resolves as default
@@ -210,26 +400,145 @@ Hint on address family can be provided with the following form:
 Notes about implementation: tcp implementation between probing (S/SA/R) and full handshake depends on the platform`)
 }
 
-func loadHostsFromFile(path string) ([]string, error) {
+// loadHostsFromFile reads one host per line. Empty lines and everything after
+// a # are ignored. Lines of the form exclude=<ip|cidr|host> are returned
+// separately as exclusions. A host may be followed by an expect=up|down
+// annotation, a group=<name> (or group:<name>) annotation and by an alias to
+// display instead of its name, all returned keyed by host. A [name] line puts
+// the hosts after it in that group, unless they name their own.
+func loadHostsFromFile(path string) ([]string, []string, map[string]string, map[string]string, map[string]string, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, nil, err
 	}
 	defer f.Close()
 
-	var hosts []string
+	var hosts, excludes []string
+	expect := make(map[string]string)
+	alias := make(map[string]string)
+	group := make(map[string]string)
+	section := ""
 	scanner := bufio.NewScanner(f)
+	lineNo := 0
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
 		if line == "" {
 			continue
 		}
-		hosts = append(hosts, line)
+		if spec, ok := strings.CutPrefix(line, "exclude="); ok {
+			excludes = append(excludes, strings.TrimSpace(spec))
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			name, ok = strings.CutSuffix(name, "]")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" || strings.ContainsAny(name, " \t") {
+				return nil, nil, nil, nil, nil, fmt.Errorf("%s:%d: a group header is [name], got %q", path, lineNo, line)
+			}
+			section = name
+			continue
+		}
+		fields := strings.Fields(line)
+		host := fields[0]
+		if section != "" {
+			group[host] = section
+		}
+		for _, annotation := range fields[1:] {
+			if name, ok := strings.CutPrefix(annotation, "group="); ok || strings.HasPrefix(annotation, "group:") {
+				if !ok {
+					name = strings.TrimPrefix(annotation, "group:")
+				}
+				if name == "" {
+					return nil, nil, nil, nil, nil, fmt.Errorf("%s:%d: empty group name", path, lineNo)
+				}
+				group[host] = name
+				continue
+			}
+			value, ok := strings.CutPrefix(annotation, "expect=")
+			if !ok {
+				if strings.Contains(annotation, "=") {
+					return nil, nil, nil, nil, nil, fmt.Errorf("%s:%d: unknown annotation %q", path, lineNo, annotation)
+				}
+				if _, dup := alias[host]; dup {
+					return nil, nil, nil, nil, nil, fmt.Errorf("%s:%d: more than one alias for %s", path, lineNo, host)
+				}
+				if _, _, err := net.ParseCIDR(host); err == nil {
+					return nil, nil, nil, nil, nil, fmt.Errorf("%s:%d: a range can't have an alias", path, lineNo)
+				}
+				alias[host] = annotation
+				continue
+			}
+			if value != "up" && value != "down" {
+				return nil, nil, nil, nil, nil, fmt.Errorf("%s:%d: expect must be up or down, got %q", path, lineNo, value)
+			}
+			expect[host] = value
+		}
+		hosts = append(hosts, host)
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, nil, nil, nil, nil, err
+	}
+	return hosts, excludes, expect, alias, group, nil
+}
+
+// stripComment drops a # comment starting the line or following whitespace,
+// keeping the # of a target such as a URL fragment
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// expandHostSpecs expands CIDRs in the given host specs and keys their
+// expect= annotations, aliases and groups by the resulting hosts. An
+// expectation or group on a CIDR applies to every address in it. A CIDR over
+// -max-cidr is an error.
+func expandHostSpecs(specs []string, rawExpect, rawAlias, rawGroup map[string]string, includeNetwork bool) ([]string, map[string]string, map[string]string, map[string]string, error) {
+	var hosts []string
+	expect := make(map[string]string)
+	alias := make(map[string]string)
+	group := make(map[string]string)
+
+	for _, arg := range specs {
+		// Try to expand as CIDR
+		ips, err := ExpandCIDR(arg, includeNetwork)
+		if errors.Is(err, ErrCIDRTooLarge) {
+			return nil, nil, nil, nil, err
+		}
+		if err == nil {
+			if monitor.DebugMode {
+				fmt.Fprintf(os.Stderr, "DEBUG: Expanded %s to %d IPs\n", arg, len(ips))
+			}
+			hosts = append(hosts, ips...)
+			if e, ok := rawExpect[arg]; ok {
+				for _, ip := range ips {
+					expect[ip] = e
+				}
+			}
+			if g, ok := rawGroup[arg]; ok {
+				for _, ip := range ips {
+					group[ip] = g
+				}
+			}
+		} else {
+			// Not a CIDR, treat as single host
+			hosts = append(hosts, arg)
+			if e, ok := rawExpect[arg]; ok {
+				expect[arg] = e
+			}
+			if a, ok := rawAlias[arg]; ok {
+				alias[arg] = a
+			}
+			if g, ok := rawGroup[arg]; ok {
+				group[arg] = g
+			}
+		}
 	}
-	return hosts, nil
+	return hosts, expect, alias, group, nil
 }
 
 // startPprof launches a pprof HTTP server on the given address.
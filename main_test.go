@@ -0,0 +1,91 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeHostFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHostsFromFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		hosts    []string
+		excludes []string
+		expect   map[string]string
+		alias    map[string]string
+		group    map[string]string
+		err      bool
+	}{
+		{name: "comments", content: "# core\n\n  # indented\n8.8.8.8 # dns\n1.1.1.1\t# dns\n",
+			hosts: []string{"8.8.8.8", "1.1.1.1"}},
+		{name: "# inside a target", content: "dns.lan#53\n",
+			hosts: []string{"dns.lan#53"}},
+		{name: "sections", content: "192.168.1.1\n[core]\n10.0.0.1\n10.0.0.2 group=edge\n[ home ]\nnas.lan group:lab\n",
+			hosts: []string{"192.168.1.1", "10.0.0.1", "10.0.0.2", "nas.lan"},
+			group: map[string]string{"10.0.0.1": "core", "10.0.0.2": "edge", "nas.lan": "lab"}},
+		{name: "expect", content: "10.0.0.1 expect=up\n10.0.0.2 expect=down\n",
+			hosts:  []string{"10.0.0.1", "10.0.0.2"},
+			expect: map[string]string{"10.0.0.1": "up", "10.0.0.2": "down"}},
+		{name: "exclude", content: "10.0.0.0/24\nexclude=10.0.0.5\nexclude= 10.0.0.8/30 \n",
+			hosts: []string{"10.0.0.0/24"}, excludes: []string{"10.0.0.5", "10.0.0.8/30"}},
+		{name: "alias", content: "192.168.1.10 nas expect=up group=home # basement\n",
+			hosts:  []string{"192.168.1.10"},
+			expect: map[string]string{"192.168.1.10": "up"},
+			alias:  map[string]string{"192.168.1.10": "nas"},
+			group:  map[string]string{"192.168.1.10": "home"}},
+		{name: "unclosed group", content: "[core\n10.0.0.1\n", err: true},
+		{name: "empty group", content: "[]\n", err: true},
+		{name: "group with space", content: "[core net]\n", err: true},
+		{name: "empty group annotation", content: "10.0.0.1 group=\n", err: true},
+		{name: "bad expect", content: "10.0.0.1 expect=maybe\n", err: true},
+		{name: "unknown annotation", content: "10.0.0.1 color=red\n", err: true},
+		{name: "two aliases", content: "10.0.0.1 nas lab\n", err: true},
+		{name: "range alias", content: "10.0.0.0/24 office\n", err: true},
+	}
+	for _, tt := range tests {
+		hosts, excludes, expect, alias, group, err := loadHostsFromFile(writeHostFile(t, tt.content))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(hosts, tt.hosts) || !slices.Equal(excludes, tt.excludes) {
+			t.Errorf("%s: hosts %q excludes %q, want %q %q", tt.name, hosts, excludes, tt.hosts, tt.excludes)
+		}
+		if !maps.Equal(expect, tt.expect) || !maps.Equal(alias, tt.alias) || !maps.Equal(group, tt.group) {
+			t.Errorf("%s: expect %v alias %v group %v, want %v %v %v", tt.name, expect, alias, group, tt.expect, tt.alias, tt.group)
+		}
+	}
+}
+
+func TestLoadHostsFromFileURL(t *testing.T) {
+	path := writeHostFile(t, "# status pages\nhttps://example.com/status#frag status # the page\n\thttp://example.com/#top\n")
+	hosts, _, _, alias, _, err := loadHostsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://example.com/status#frag", "http://example.com/#top"}
+	if !slices.Equal(hosts, want) {
+		t.Errorf("hosts = %q, want %q", hosts, want)
+	}
+	if alias["https://example.com/status#frag"] != "status" {
+		t.Errorf("alias = %q, want status", alias)
+	}
+}
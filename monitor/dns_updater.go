@@ -1,12 +1,21 @@
-package main
+package monitor
 
 import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Reverse DNS cache limits, set by -dns-ttl, -dns-negative-ttl and
+// -dns-cache-size
+var (
+	DNSCacheTTL         = time.Hour       // how long a found name is kept
+	DNSCacheNegativeTTL = 5 * time.Minute // how long a failed lookup is kept
+	DNSCacheSize        = 65536           // entries at most, one per IP
+)
+
 type dnsCacheEntry struct {
 	name      string
 	expiresAt time.Time
@@ -20,6 +29,7 @@ type DNSUpdater struct {
 	mu             sync.Mutex
 	dnsCache       map[string]dnsCacheEntry
 	cacheMu        sync.RWMutex
+	onCycle        func() // optional hook run at the start of every update cycle
 }
 
 // NewDNSUpdater creates a new DNSUpdater
@@ -38,6 +48,8 @@ func (d *DNSUpdater) Start() {
 		return
 	}
 	d.stopChan = make(chan struct{})
+	// Captured so a Stop/Start from within a cycle (onCycle) ends this goroutine
+	stop := d.stopChan
 	d.running = true
 	d.mu.Unlock()
 
@@ -52,8 +64,8 @@ func (d *DNSUpdater) Start() {
 
 		select {
 		case <-initialTimer.C:
-			d.performDNSUpdates()
-		case <-d.stopChan:
+			d.runCycle()
+		case <-stop:
 			return
 		}
 
@@ -64,8 +76,8 @@ func (d *DNSUpdater) Start() {
 		for {
 			select {
 			case <-ticker.C:
-				d.performDNSUpdates()
-			case <-d.stopChan:
+				d.runCycle()
+			case <-stop:
 				return
 			}
 		}
@@ -89,6 +101,14 @@ func (d *DNSUpdater) Stop() {
 	}
 }
 
+// runCycle runs the optional onCycle hook followed by the reverse DNS updates
+func (d *DNSUpdater) runCycle() {
+	if d.onCycle != nil {
+		d.onCycle()
+	}
+	d.performDNSUpdates()
+}
+
 // performDNSUpdates updates DNS names for all online hosts
 func (d *DNSUpdater) performDNSUpdates() {
 	if SkipDNS {
@@ -96,34 +116,37 @@ func (d *DNSUpdater) performDNSUpdates() {
 	}
 
 	wrappers := d.wrappersSource()
-	updated := 0
+	// Counted from the lookup goroutines
+	var updated atomic.Int64
+
+	d.sweepCache(time.Now())
 
 	// Use semaphore to limit concurrent DNS lookups
 	sem := make(chan struct{}, 20)
 	var wg sync.WaitGroup
 
 	for _, wrapper := range wrappers {
-		stats := wrapper.CalcStats(2_000_000_000) // 2s threshold
+		stats := wrapper.CalcStats(DefaultOfflineAfter)
 
 		// Only update DNS for online hosts
-		if !stats.state || stats.error_message != "" {
+		if !stats.State || stats.ErrorMessage != "" {
 			continue
 		}
 
 		// Check cache
 		d.cacheMu.RLock()
-		entry, found := d.dnsCache[stats.iprepr]
+		entry, found := d.dnsCache[stats.IPRepr]
 		d.cacheMu.RUnlock()
 
 		if found && time.Now().Before(entry.expiresAt) {
 			// Cache hit, update wrapper if needed (though wrapper usually holds the state)
 			// Ideally we would set the cached name on the wrapper here if it was lost,
-			// but updateHostDisplayName does the lookup AND set.
-			// We should modify updateHostDisplayName or do the check here.
+			// but UpdateHostDisplayName does the lookup AND set.
+			// We should modify UpdateHostDisplayName or do the check here.
 			// For now, let's assume if it's in cache, the wrapper likely has it,
 			// OR we can skip the lookup.
 			// Actually, the wrapper stores the "hrepr".
-			// If we skip calling updateHostDisplayName, we rely on the wrapper keeping it.
+			// If we skip calling UpdateHostDisplayName, we rely on the wrapper keeping it.
 			// But if the wrapper doesn't have it yet (e.g. first run), we need to set it.
 			// Let's modify the logic to use the cache.
 			if stats.GetHostRepr() != "" {
@@ -144,7 +167,7 @@ func (d *DNSUpdater) performDNSUpdates() {
 
 			// We need to peek at the IP again
 			s := pw.Stats()
-			ip := s.iprepr
+			ip := s.IPRepr
 
 			// Double check cache inside goroutine
 			d.cacheMu.RLock()
@@ -154,34 +177,59 @@ func (d *DNSUpdater) performDNSUpdates() {
 				return
 			}
 
-			if updateHostDisplayName(pw) {
-				d.mu.Lock()
-				updated++
-				d.mu.Unlock()
+			if UpdateHostDisplayName(pw) {
+				updated.Add(1)
 
 				// Update cache
 				newStats := pw.Stats()
-				d.cacheMu.Lock()
-				d.dnsCache[ip] = dnsCacheEntry{
+				d.cacheStore(ip, dnsCacheEntry{
 					name:      newStats.GetHostRepr(),
-					expiresAt: time.Now().Add(1 * time.Hour), // 1 hour TTL
-				}
-				d.cacheMu.Unlock()
+					expiresAt: time.Now().Add(DNSCacheTTL),
+				})
 			} else {
 				// Cache negative result for a shorter time
-				d.cacheMu.Lock()
-				d.dnsCache[ip] = dnsCacheEntry{
+				d.cacheStore(ip, dnsCacheEntry{
 					name:      "",
-					expiresAt: time.Now().Add(5 * time.Minute), // 5 min TTL for failures
-				}
-				d.cacheMu.Unlock()
+					expiresAt: time.Now().Add(DNSCacheNegativeTTL),
+				})
 			}
 		}(wrapper)
 	}
 
 	wg.Wait()
 
-	if DebugMode && updated > 0 {
-		fmt.Fprintf(os.Stderr, "DEBUG: Updated DNS names for %d online hosts\n", updated)
+	if n := updated.Load(); DebugMode && n > 0 {
+		fmt.Fprintf(os.Stderr, "DEBUG: Updated DNS names for %d online hosts\n", n)
+	}
+}
+
+// sweepCache drops the cache entries expired at now
+func (d *DNSUpdater) sweepCache(now time.Time) {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+	for ip, e := range d.dnsCache {
+		if !now.Before(e.expiresAt) {
+			delete(d.dnsCache, ip)
+		}
+	}
+}
+
+// cacheStore adds or replaces the entry of ip. A new IP in a full cache
+// first evicts an expired entry, or an arbitrary one if none has expired,
+// so the cache never holds more than DNSCacheSize entries.
+func (d *DNSUpdater) cacheStore(ip string, entry dnsCacheEntry) {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+	if _, ok := d.dnsCache[ip]; !ok && len(d.dnsCache) >= DNSCacheSize {
+		now := time.Now()
+		victim := ""
+		for k, e := range d.dnsCache {
+			victim = k
+			if !now.Before(e.expiresAt) {
+				break
+			}
+		}
+		delete(d.dnsCache, victim)
 	}
+	d.dnsCache[ip] = entry
 }
@@ -0,0 +1,70 @@
+package monitor
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDNSCacheSize(t *testing.T) {
+	defer func(size int) { DNSCacheSize = size }(DNSCacheSize)
+	DNSCacheSize = 8
+
+	d := NewDNSUpdater(nil)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				d.cacheStore(fmt.Sprintf("10.%d.0.%d", g, i), dnsCacheEntry{expiresAt: time.Now().Add(time.Hour)})
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := len(d.dnsCache); n > DNSCacheSize {
+		t.Errorf("cache holds %d entries, more than DNSCacheSize %d", n, DNSCacheSize)
+	}
+
+	// A full cache makes room by evicting an expired entry first
+	d = NewDNSUpdater(nil)
+	for i := 0; i < DNSCacheSize-1; i++ {
+		d.cacheStore(fmt.Sprintf("10.0.0.%d", i), dnsCacheEntry{expiresAt: time.Now().Add(time.Hour)})
+	}
+	d.cacheStore("10.0.1.0", dnsCacheEntry{expiresAt: time.Now().Add(-time.Second)})
+	d.cacheStore("10.0.2.0", dnsCacheEntry{name: "new", expiresAt: time.Now().Add(time.Hour)})
+	if _, ok := d.dnsCache["10.0.1.0"]; ok {
+		t.Error("expired entry kept while a live one was stored in the full cache")
+	}
+	if n := len(d.dnsCache); n != DNSCacheSize {
+		t.Errorf("cache holds %d entries, want %d", n, DNSCacheSize)
+	}
+	// Replacing an entry evicts nothing
+	d.cacheStore("10.0.2.0", dnsCacheEntry{name: "newer", expiresAt: time.Now().Add(time.Hour)})
+	if n := len(d.dnsCache); n != DNSCacheSize || d.dnsCache["10.0.2.0"].name != "newer" {
+		t.Errorf("replacing an entry: %d entries, name %q", n, d.dnsCache["10.0.2.0"].name)
+	}
+}
+
+func TestDNSCacheSweep(t *testing.T) {
+	d := NewDNSUpdater(nil)
+	now := time.Now()
+	d.cacheStore("10.0.0.1", dnsCacheEntry{name: "expired", expiresAt: now.Add(-time.Minute)})
+	d.cacheStore("10.0.0.2", dnsCacheEntry{expiresAt: now})
+	d.cacheStore("10.0.0.3", dnsCacheEntry{name: "live", expiresAt: now.Add(time.Minute)})
+	d.sweepCache(now)
+	if len(d.dnsCache) != 1 || d.dnsCache["10.0.0.3"].name != "live" {
+		t.Errorf("after sweep the cache holds %v, want only 10.0.0.3", d.dnsCache)
+	}
+
+	// Every update cycle sweeps
+	defer func(skip bool) { SkipDNS = skip }(SkipDNS)
+	SkipDNS = false
+	d = NewDNSUpdater(func() []PingWrapperInterface { return nil })
+	d.cacheStore("10.0.0.1", dnsCacheEntry{expiresAt: time.Now().Add(-time.Minute)})
+	d.performDNSUpdates()
+	if len(d.dnsCache) != 0 {
+		t.Errorf("after an update cycle the cache holds %v, want nothing", d.dnsCache)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package monitor
 
 import (
 	"context"
@@ -9,10 +9,13 @@ import (
 	"time"
 )
 
-// hostDisplayName returns either the original host or the reverse DNS name when the input was an IP.
+// SkipDNS turns off the reverse DNS lookups naming hosts given as IPs
+var SkipDNS = false
+
+// HostDisplayName returns either the original host or the reverse DNS name when the input was an IP.
 // Uses a 500ms timeout for DNS lookups to avoid blocking on slow/non-existent PTR records.
 // Can be disabled globally with -no-dns flag for faster startup.
-func hostDisplayName(original string, ip *net.IPAddr) string {
+func HostDisplayName(original string, ip *net.IPAddr) string {
 	if ip == nil {
 		return original
 	}
@@ -40,10 +43,10 @@ func hostDisplayName(original string, ip *net.IPAddr) string {
 	return strings.TrimSuffix(names[0], ".")
 }
 
-// updateHostDisplayName performs a reverse DNS lookup and updates the wrapper's hrepr field.
+// UpdateHostDisplayName performs a reverse DNS lookup and updates the wrapper's hrepr field.
 // This is used for periodic/delayed DNS updates instead of blocking at startup.
 // Returns true if the name was updated, false otherwise.
-func updateHostDisplayName(wrapper PingWrapperInterface) bool {
+func UpdateHostDisplayName(wrapper PingWrapperInterface) bool {
 	if SkipDNS {
 		return false
 	}
@@ -53,11 +56,16 @@ func updateHostDisplayName(wrapper PingWrapperInterface) bool {
 		return false
 	}
 
+	// An alias from the host file is never replaced by the PTR name
+	if stats.Alias != "" {
+		return false
+	}
+
 	// Refresh computed fields so we work with up-to-date info
-	stats.ComputeState(2_000_000_000)
+	stats.ComputeState(DefaultOfflineAfter)
 
-	// Get IP from stats.iprepr (already resolved during wrapper creation)
-	ipStr := stats.iprepr
+	// Get IP from stats.IPRepr (already resolved during wrapper creation)
+	ipStr := stats.IPRepr
 	if ipStr == "" {
 		if DebugMode {
 			fmt.Fprintf(os.Stderr, "DEBUG DNS: No iprepr for %s\n", wrapper.Host())
@@ -95,6 +103,11 @@ func updateHostDisplayName(wrapper PingWrapperInterface) bool {
 	currentRepr := stats.GetHostRepr()
 	var newRepr string
 
+	// A URL names its target already, and the Host header must not change
+	if strings.HasPrefix(currentRepr, "http://") || strings.HasPrefix(currentRepr, "https://") || strings.HasPrefix(currentRepr, "dns://") {
+		return false
+	}
+
 	if strings.HasPrefix(currentRepr, "tcp://") {
 		// Extract port from current representation
 		parts := strings.Split(currentRepr, ":")
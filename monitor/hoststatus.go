@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// HostStatus represents the public status information for a host.
+type HostStatus struct {
+	Host             string `json:"host"`
+	IP               string `json:"ip"`
+	Online           bool   `json:"online"`
+	State            string `json:"state"` // online, degraded or offline
+	Lossy            bool   `json:"lossy"` // online with loss over -loss-threshold
+	RTT              string `json:"rtt"`
+	LastReply        string `json:"last_reply"`
+	LastLossAgo      string `json:"last_loss_ago,omitempty"`
+	LastLossDuration string `json:"last_loss_duration,omitempty"`
+	Error            string `json:"error,omitempty"`
+	SeqGaps          int64  `json:"seq_gaps"`
+	Reorders         int64  `json:"reorders"`
+	DupReplies       int64  `json:"dup_replies"`
+	MaxMisses        int64  `json:"max_consecutive_misses"`
+	Loss             string `json:"loss"`               // loss percentage over -loss-window, "-" before the first probe
+	Jitter           string `json:"jitter"`             // smoothed deviation of consecutive RTTs, "-" when offline
+	Uptime           string `json:"uptime"`             // share of the time since startup spent online, "-" before the first probe
+	Expected         string `json:"expected,omitempty"` // expect= from the host file
+	MeetsExpectation bool   `json:"meets_expectation"`
+
+	// raw timestamps for the text view's absolute time mode, not in JSON
+	LastRecvNano int64 `json:"-"`
+	LastLossNano int64 `json:"-"`
+}
+
+// NewHostStatus builds the public status of a wrapper from its computed stats.
+func NewHostStatus(wrapper PingWrapperInterface, stats *PWStats, now time.Time) HostStatus {
+	host := stats.GetHostRepr()
+	if host == "" {
+		host = wrapper.Host()
+	}
+
+	ip := stats.IPRepr
+	online := stats.State && stats.ErrorMessage == ""
+	degraded := stats.Degraded && stats.ErrorMessage == ""
+	state := "offline"
+	if online {
+		state = "online"
+	} else if degraded {
+		state = "degraded"
+	}
+	rtt := "-"
+	if (online || degraded) && stats.LastRTTString != "" {
+		rtt = stats.LastRTTString
+	}
+
+	lastReply := "never"
+	if stats.LastRecv > 0 {
+		lastReply = fmt.Sprintf("%s ago", time.Duration(stats.LastSeenNano).Round(time.Second))
+	}
+
+	var lastLossAgo, lastLossDuration string
+	if stats.LastLossNano > 0 {
+		lastLossAgo = fmt.Sprintf("%s ago", time.Duration(now.UnixNano()-stats.LastLossNano).Round(time.Second))
+		lastLossDuration = time.Duration(stats.LastLossDuration).Round(time.Second / 10).String()
+	}
+
+	loss := "-"
+	if stats.PacketsSent > 0 {
+		loss = fmt.Sprintf("%.1f%%", stats.WindowLossPercent())
+	}
+
+	jitter := "-"
+	if online || degraded {
+		jitter = Round(stats.Jitter, 2).String()
+	}
+
+	uptime := "-"
+	if pct, ok := stats.UptimePercent(now.UnixNano()); ok {
+		uptime = fmt.Sprintf("%.1f%%", pct)
+	}
+
+	return HostStatus{
+		Host:             host,
+		IP:               ip,
+		Online:           online,
+		State:            state,
+		Lossy:            stats.Lossy(),
+		RTT:              rtt,
+		LastReply:        lastReply,
+		LastLossAgo:      lastLossAgo,
+		LastLossDuration: lastLossDuration,
+		Error:            stats.ErrorMessage,
+		SeqGaps:          stats.SeqGaps,
+		Reorders:         stats.Reorders,
+		DupReplies:       stats.DupReplies,
+		MaxMisses:        stats.MaxMissStreak,
+		Loss:             loss,
+		Jitter:           jitter,
+		Uptime:           uptime,
+		Expected:         stats.Expect,
+		MeetsExpectation: stats.MeetsExpectation(),
+		LastRecvNano:     stats.LastRecv,
+		LastLossNano:     stats.LastLossNano,
+	}
+}
@@ -0,0 +1,222 @@
+// Package monitor is the probing core of mping: the ICMP, TCP, HTTP and
+// DNS wrappers, their statistics and state transitions, and Monitor, which
+// runs them for programs embedding mping.
+package monitor
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// monitorTick is how often a Monitor computes the host states
+const monitorTick = 100 * time.Millisecond
+
+// Options configures a Monitor. Zero values take the defaults of
+// the command line.
+type Options struct {
+	Interval   time.Duration // time between two probes of a host (1s)
+	Timeout    time.Duration // time a probe waits for its reply (1s)
+	Misses     int           // unanswered probes before a host is offline (2)
+	MaxPPS     int           // cap on the probes per second of all hosts (0 = unlimited)
+	Privileged bool          // raw ICMP sockets, needed on some systems for pure Go ping
+	System     bool          // use the system's ping instead of pure Go ping
+	Size       int           // pure Go ICMP payload size (24)
+}
+
+// Monitor probes a set of hosts for programs embedding mping without its
+// command line, TUI or status server. Hosts are given as on the command
+// line (not as CIDRs) and can be added and removed while it runs.
+// Snapshot returns their statuses as /json does and OnTransition
+// registers callbacks for state changes.
+type Monitor struct {
+	repo      *MemoryHostRepository
+	service   *PingService
+	hosts     []string
+	callbacks []func(TransitionRecord)
+	pending   []TransitionRecord // queued by monitorSink, not yet passed to callbacks
+	running   bool
+	stopped   bool          // wrappers stopped, they can't be started again
+	stop      chan struct{} // closed by Stop
+	done      chan struct{} // closed once the state loop has returned
+	mu        sync.Mutex
+}
+
+// New creates a Monitor without hosts. Probing starts with Start.
+func New(opts Options) *Monitor {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultProbeInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultProbeTimeout
+	}
+	if opts.Misses <= 0 {
+		opts.Misses = 2
+	}
+	if opts.Size <= 0 {
+		opts.Size = 24
+	}
+	options := ProbeOptions{
+		Privileged: opts.Privileged,
+		Size:       opts.Size,
+		System:     opts.System,
+		Limiter:    NewRateLimiter(opts.MaxPPS),
+		Interval:   opts.Interval,
+		Timeout:    opts.Timeout,
+		Misses:     opts.Misses,
+	}
+	m := &Monitor{repo: NewMemoryHostRepository()}
+	tw := &TransitionWriter{}
+	tw.AddSink(monitorSink{m})
+	m.service = NewPingService(m.repo, options, tw)
+	return m
+}
+
+// OnTransition registers fn to be called with every state change. Callbacks
+// run one at a time on the Monitor's goroutine, a slow one delays the next
+// state updates. They may call AddHosts, RemoveHosts and Snapshot but not
+// Stop, which waits for that goroutine.
+func (m *Monitor) OnTransition(fn func(TransitionRecord)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks = append(m.callbacks, fn)
+}
+
+// AddHosts adds hosts not monitored yet. While running they are probed
+// right away; if one is invalid or doesn't resolve, none is added and the
+// error returned.
+func (m *Monitor) AddHosts(hosts ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	next := slices.Clone(m.hosts)
+	for _, host := range hosts {
+		if !slices.Contains(next, host) {
+			next = append(next, host)
+		}
+	}
+	return m.setHosts(next)
+}
+
+// RemoveHosts stops monitoring the given hosts
+func (m *Monitor) RemoveHosts(hosts ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	next := slices.DeleteFunc(slices.Clone(m.hosts), func(host string) bool {
+		return slices.Contains(hosts, host)
+	})
+	return m.setHosts(next)
+}
+
+// setHosts applies the hosts while running. Caller must hold m.mu.
+func (m *Monitor) setHosts(hosts []string) error {
+	if m.running {
+		if err := m.service.ReplaceHosts(hosts); err != nil {
+			return err
+		}
+	}
+	m.hosts = hosts
+	return nil
+}
+
+// Start starts probing the hosts. It fails like AddHosts when a host can't
+// be probed, and once the Monitor was stopped. It does nothing when already
+// running.
+func (m *Monitor) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return nil
+	}
+	if m.stopped {
+		return errors.New("monitor stopped, it can't be started again")
+	}
+	if err := m.service.ReplaceHosts(m.hosts); err != nil {
+		return err
+	}
+	m.running = true
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(m.stop, m.done)
+	return nil
+}
+
+// Stop stops probing for good. Transitions detected until then are passed
+// to the callbacks before it returns, Snapshot keeps the last statuses.
+// It must not be called from a callback.
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = false
+	m.stopped = true
+	close(m.stop)
+	done := m.done
+	m.mu.Unlock()
+	<-done
+	m.service.Stop()
+	// the DNS updater may have queued some after the last state update
+	m.deliver()
+}
+
+// run computes the host states every monitorTick and passes the
+// transitions to the callbacks until stop is closed
+func (m *Monitor) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(monitorTick)
+	defer ticker.Stop()
+	for {
+		for _, wrapper := range m.repo.GetAll() {
+			wrapper.CalcStats(DefaultOfflineAfter)
+		}
+		m.deliver()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// deliver passes the pending transitions to the callbacks, outside the lock
+// so a callback may add, remove and snapshot hosts
+func (m *Monitor) deliver() {
+	m.mu.Lock()
+	pending, callbacks := m.pending, slices.Clone(m.callbacks)
+	m.pending = nil
+	m.mu.Unlock()
+	for _, rec := range pending {
+		for _, fn := range callbacks {
+			fn(rec)
+		}
+	}
+}
+
+// Snapshot returns the status of every host in the order they were added,
+// as of the last state update
+func (m *Monitor) Snapshot() []HostStatus {
+	now := time.Now()
+	wrappers := m.repo.GetAll()
+	statuses := make([]HostStatus, len(wrappers))
+	for i, wrapper := range wrappers {
+		stats := wrapper.Stats().Snapshot()
+		statuses[i] = NewHostStatus(wrapper, &stats, now)
+	}
+	return statuses
+}
+
+// monitorSink queues the transitions of a Monitor's hosts for its callbacks
+type monitorSink struct {
+	m *Monitor
+}
+
+func (s monitorSink) Send(rec TransitionRecord) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.pending = append(s.m.pending, rec)
+}
+
+// Close does nothing, Stop delivers what is pending
+func (s monitorSink) Close() {}
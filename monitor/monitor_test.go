@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a few seconds have passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	defer func(skip bool) { SkipDNS = skip }(SkipDNS)
+	SkipDNS = true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	host := srv.URL + "/"
+
+	m := New(Options{Interval: 20 * time.Millisecond, Timeout: 200 * time.Millisecond})
+	transitions := make(chan TransitionRecord, 16)
+	m.OnTransition(func(rec TransitionRecord) { transitions <- rec })
+
+	if err := m.AddHosts(host, host); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	waitFor(t, "the host to be online", func() bool {
+		s := m.Snapshot()
+		return len(s) == 1 && s[0].Online
+	})
+	select {
+	case rec := <-transitions:
+		if rec.Target != host || !rec.State {
+			t.Errorf("transition %+v, want %s going up", rec, host)
+		}
+	case <-time.After(time.Second):
+		t.Error("no transition passed to the callback")
+	}
+
+	// Without the server the host goes down after the misses
+	srv.Close()
+	select {
+	case rec := <-transitions:
+		if rec.Target != host || rec.State {
+			t.Errorf("transition %+v, want %s going down", rec, host)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no transition passed to the callback after the server closed")
+	}
+	if s := m.Snapshot(); s[0].Online {
+		t.Error("host online after its transition down")
+	}
+
+	if err := m.AddHosts("tcp://127.0.0.1"); err == nil {
+		t.Error("AddHosts accepted tcp:// without a port")
+	}
+	if n := len(m.Snapshot()); n != 1 {
+		t.Errorf("%d hosts after a failed AddHosts, want 1", n)
+	}
+
+	if err := m.RemoveHosts(host); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.Snapshot()); n != 0 {
+		t.Errorf("%d hosts after RemoveHosts, want 0", n)
+	}
+
+	m.Stop()
+	if err := m.Start(); err == nil {
+		t.Error("Start after Stop succeeded")
+	}
+}
+
+func TestMonitorStopDeliversPending(t *testing.T) {
+	m := New(Options{})
+	got := make(chan TransitionRecord, 2)
+	m.OnTransition(func(rec TransitionRecord) { got <- rec })
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// Queued as the DNS updater does: the first once the state loop runs,
+	// the second between two state updates
+	monitorSink{m}.Send(TransitionRecord{Target: "first"})
+	<-got
+	monitorSink{m}.Send(TransitionRecord{Target: "second"})
+	m.Stop()
+	select {
+	case rec := <-got:
+		if rec.Target != "second" {
+			t.Errorf("transition %+v after Stop, want second", rec)
+		}
+	default:
+		t.Error("transition queued before Stop not passed to the callback")
+	}
+	m.Stop()
+}
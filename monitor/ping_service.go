@@ -0,0 +1,274 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DebugMode reports the start of wrappers, SRV and reverse DNS updates and
+// the probe traffic estimate on stderr
+var DebugMode = false
+
+// PingService manages the lifecycle of ping wrappers
+type PingService struct {
+	repo             HostRepository
+	options          ProbeOptions
+	transitionWriter *TransitionWriter
+	dnsUpdater       *DNSUpdater
+	hostSpecs        []string            // hosts as given, before srv:// expansion
+	srvTargets       map[string][]string // srv:// spec -> last resolved targets
+	mu               sync.Mutex          // protects hostSpecs/srvTargets and serializes replacements
+	started          atomic.Int64        // wrappers started by the current Start(), for progress display
+}
+
+// NewPingService creates a new PingService
+func NewPingService(repo HostRepository, options ProbeOptions, tw *TransitionWriter) *PingService {
+	if options.Gate == nil {
+		options.Gate = &ProbeGate{}
+	}
+	ps := &PingService{
+		repo:             repo,
+		options:          options,
+		transitionWriter: tw,
+		srvTargets:       make(map[string][]string),
+	}
+	// Initialize DNSUpdater with a source function that gets wrappers from the repo
+	ps.dnsUpdater = NewDNSUpdater(repo.GetAll)
+	ps.dnsUpdater.onCycle = ps.refreshSRV
+	return ps
+}
+
+// InitHosts initializes the hosts and stores them in the repository
+func (s *PingService) InitHosts(hosts []string) {
+	s.mu.Lock()
+	s.hostSpecs = hosts
+	var expanded []string
+	expanded, s.srvTargets = s.expandSRV(hosts)
+	s.mu.Unlock()
+
+	wrappers := make([]PingWrapperInterface, len(expanded))
+	for i, host := range expanded {
+		wrappers[i] = NewPingWrapper(host, s.options, s.transitionWriter)
+	}
+	s.repo.UpdateAll(wrappers)
+	s.options.Limiter.SetHosts(len(wrappers))
+}
+
+// HostSpecs returns the hosts as last given to InitHosts/ReplaceHosts,
+// with srv:// specs unexpanded
+func (s *PingService) HostSpecs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.hostSpecs...)
+}
+
+// expandSRV replaces srv:// specs with their resolved tcp:// targets, also
+// returned by spec to become srvTargets once the hosts are applied. On
+// resolution failure the previously known targets are kept.
+// Caller must hold s.mu.
+func (s *PingService) expandSRV(hosts []string) ([]string, map[string][]string) {
+	var out []string
+	srvTargets := make(map[string][]string)
+	for _, host := range hosts {
+		if !IsSRVSpec(host) {
+			out = append(out, host)
+			continue
+		}
+		targets, err := ResolveSRV(host)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "SRV lookup failed for %s: %v\n", host, err)
+			targets = s.srvTargets[host]
+		}
+		srvTargets[host] = targets
+		out = append(out, targets...)
+	}
+	return out, srvTargets
+}
+
+// refreshSRV re-resolves srv:// specs and replaces the hosts when a target
+// appeared or disappeared. When a new target can't be probed, e.g. doesn't
+// resolve, the hosts and known targets stay as they are and the next cycle
+// tries again. Called on every DNS updater cycle.
+func (s *PingService) refreshSRV() {
+	s.mu.Lock()
+	changed := false
+	for spec, old := range s.srvTargets {
+		targets, err := ResolveSRV(spec)
+		if err != nil {
+			if DebugMode {
+				fmt.Fprintf(os.Stderr, "DEBUG: SRV refresh failed for %s: %v\n", spec, err)
+			}
+			continue
+		}
+		if !slices.Equal(old, targets) {
+			changed = true
+		}
+	}
+	specs := s.hostSpecs
+	s.mu.Unlock()
+
+	if changed {
+		if DebugMode {
+			fmt.Fprintf(os.Stderr, "DEBUG: SRV targets changed, replacing hosts\n")
+		}
+		if err := s.ReplaceHosts(specs); err != nil && DebugMode {
+			fmt.Fprintf(os.Stderr, "DEBUG: SRV targets changed, keeping the hosts: %v\n", err)
+		}
+	}
+}
+
+// Start starts all ping wrappers and the DNS updater
+func (s *PingService) Start() {
+	wrappers := s.repo.GetAll()
+	s.started.Store(0)
+
+	if DebugMode {
+		fmt.Fprintf(os.Stderr, "DEBUG: Starting %d ping wrappers (parallel DNS lookups, staggered start)\n", len(wrappers))
+	}
+
+	// Start wrappers in parallel goroutines to avoid blocking on DNS lookups
+	sem := make(chan struct{}, 20) // Allow 20 concurrent starts
+	var wg sync.WaitGroup
+
+	for i, pw := range wrappers {
+		wg.Add(1)
+		go func(idx int, pw PingWrapperInterface) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintf(os.Stderr, "PANIC starting wrapper %d (%s): %v\n", idx, pw.Host(), r)
+				}
+			}()
+
+			sem <- struct{}{}        // Acquire
+			defer func() { <-sem }() // Release
+
+			if DebugMode && idx > 0 && idx%50 == 0 {
+				fmt.Fprintf(os.Stderr, "DEBUG: Starting wrapper %d/%d\n", idx, len(wrappers))
+			}
+
+			pw.Start()
+			s.started.Add(1)
+		}(i, pw)
+
+		// Small delay to avoid overwhelming the system at startup
+		if i >= 10 && i < len(wrappers)-1 && i%10 == 0 {
+			time.Sleep(1 * time.Millisecond)
+		}
+	}
+
+	wg.Wait()
+
+	if DebugMode {
+		fmt.Fprintf(os.Stderr, "DEBUG: All %d wrappers started successfully\n", len(wrappers))
+		writeTrafficEstimate(os.Stderr, wrappers, s.options.Limiter)
+	}
+
+	s.dnsUpdater.Start()
+}
+
+// Pause stops issuing probes on every wrapper and freezes the host states
+// until Resume. Wrappers created while paused start paused.
+func (s *PingService) Pause() {
+	s.options.Gate.Pause()
+	now := time.Now().UnixNano()
+	for _, pw := range s.repo.GetAll() {
+		pw.Stats().Pause(now)
+	}
+}
+
+// Resume starts probing again, without counting the pause as outage or loss
+func (s *PingService) Resume() {
+	now := time.Now().UnixNano()
+	for _, pw := range s.repo.GetAll() {
+		pw.Stats().Resume(now)
+	}
+	s.options.Gate.Resume()
+}
+
+// Paused reports whether probing is paused
+func (s *PingService) Paused() bool {
+	return s.options.Gate.Paused()
+}
+
+// StartProgress returns how many wrappers the running Start() has started so far
+func (s *PingService) StartProgress() int64 {
+	return s.started.Load()
+}
+
+// Stop stops all ping wrappers and the DNS updater
+func (s *PingService) Stop() {
+	s.dnsUpdater.Stop()
+	for _, pw := range s.repo.GetAll() {
+		pw.Stop()
+	}
+}
+
+// ReplaceHosts replaces the current hosts with new ones, handling graceful
+// shutdown/startup. Hosts that remain keep their wrapper and stats. If a
+// host is invalid or doesn't resolve, the current hosts are kept and the
+// error returned.
+func (s *PingService) ReplaceHosts(hosts []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replaceHosts(hosts)
+}
+
+// ReplaceAnnotatedHosts is ReplaceHosts with the expect=up|down, alias and
+// group annotations of a host file or session, which are kept as they are
+// along with the hosts on failure
+func (s *PingService) ReplaceAnnotatedHosts(hosts []string, expect, alias, group map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.options
+	s.options.Expect, s.options.Alias, s.options.Group = expect, alias, group
+	if err := s.replaceHosts(hosts); err != nil {
+		s.options = prev
+		return err
+	}
+	return nil
+}
+
+// replaceHosts does ReplaceHosts. Caller must hold s.mu.
+func (s *PingService) replaceHosts(hosts []string) error {
+	expanded, srvTargets := s.expandSRV(hosts)
+	newWrappers, added, removed, err := reuseWrappers(s.repo.GetAll(), expanded, s.options, s.transitionWriter)
+	if err != nil {
+		return err
+	}
+
+	// Stop DNS updates while replacing hosts
+	s.dnsUpdater.Stop()
+
+	s.hostSpecs = hosts
+	s.srvTargets = srvTargets
+
+	// Update repository
+	s.repo.UpdateAll(newWrappers)
+	s.options.Limiter.SetHosts(len(newWrappers))
+
+	// Stop removed wrappers
+	for _, pw := range removed {
+		pw.Stop()
+	}
+
+	// Staggered start for added wrappers
+	for i, pw := range added {
+		pw.Start()
+		if i >= 10 && i < len(added)-1 {
+			time.Sleep(1 * time.Millisecond)
+		}
+	}
+
+	if DebugMode {
+		writeTrafficEstimate(os.Stderr, newWrappers, s.options.Limiter)
+	}
+
+	// Restart DNS updates for new hosts
+	s.dnsUpdater.Start()
+	return nil
+}
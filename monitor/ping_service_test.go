@@ -0,0 +1,22 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPingServiceStopTwice(t *testing.T) {
+	defer func(skip bool) { SkipDNS = skip }(SkipDNS)
+	SkipDNS = true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// The TUI's quit stops the service, then RunTUI's deferred Stop again
+	ps := NewPingService(NewMemoryHostRepository(), ProbeOptions{Size: 24}, &TransitionWriter{})
+	ps.InitHosts([]string{"127.0.0.1", "tcp://127.0.0.1:1", srv.URL, "dns://127.0.0.1:1"})
+	ps.Start()
+	ps.Stop()
+	ps.Stop()
+}
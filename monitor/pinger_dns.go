@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDNSQueryName is queried by dns:// targets that don't name one
+const defaultDNSQueryName = "example.com"
+
+// DNSPingWrapper probes a DNS resolver with an A query. Any answer counts as
+// a reply, including a negative one (NXDOMAIN), the response time as RTT.
+type DNSPingWrapper struct {
+	spec       string // as given, shown as the host
+	ip         *net.IPAddr
+	port       int
+	name       string // queried name
+	resolver   *net.Resolver
+	stats      *PWStats
+	stop       chan struct{} // closed by Stop
+	stopOnce   sync.Once     // lets Stop be called more than once
+	limiter    *RateLimiter
+	gate       *ProbeGate
+	startDelay time.Duration // phase offset before the first probe
+	interval   time.Duration // time between two probes
+	timeout    time.Duration // time a probe waits for its answer
+}
+
+func (w *DNSPingWrapper) Start() {
+	w.stats.SetHostRepr(w.spec)
+
+	// Every query goes to the resolver of the target, not the system's
+	addr := net.JoinHostPort(w.ip.IP.String(), strconv.Itoa(w.port))
+	var dialer net.Dialer
+	w.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+
+	w.stop = make(chan struct{})
+	go followPacing(w.stats, w.limiter, w.interval, w.stop)
+
+	go func(w *DNSPingWrapper, stop chan struct{}) {
+		select {
+		case <-time.After(w.startDelay):
+		case <-stop:
+			return
+		}
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			// While paused probes are skipped, not delayed. Probes run one
+			// at a time like http(s):// ones.
+			if !w.gate.Paused() {
+				if !w.limiter.Wait(stop) {
+					return
+				}
+				w.probe()
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}(w, w.stop)
+}
+
+func (w *DNSPingWrapper) probe() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	start := time.Now()
+	w.stats.RecordSend(start.UnixNano())
+	// Fully qualified so the resolv.conf search list is not tried
+	ips, err := w.resolver.LookupIP(ctx, "ip4", w.name+".")
+	rtt := time.Since(start)
+
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		addrs := make([]string, len(ips))
+		for i, ip := range ips {
+			addrs[i] = ip.String()
+		}
+		w.stats.SetDNSResult(strings.Join(addrs, ", "))
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		// The resolver answered, the name just doesn't exist
+		w.stats.SetDNSResult("not found (NXDOMAIN)")
+	case errors.As(err, &dnsErr) && dnsErr.IsTimeout, errors.Is(err, context.DeadlineExceeded):
+		w.stats.SetDNSResult(fmt.Sprintf("no answer within %s", w.timeout))
+		return
+	default:
+		// DNSError names the system's resolver as the server, leave it out
+		msg := err.Error()
+		if dnsErr != nil {
+			msg = dnsErr.Err
+		}
+		w.stats.SetDNSResult(msg)
+		w.stats.SetError(msg)
+		return
+	}
+	w.stats.SetError("")
+
+	if w.stats.RejectRTT(rtt) {
+		return
+	}
+	w.stats.RecordReply(time.Now().UnixNano(), rtt)
+}
+
+func (w *DNSPingWrapper) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+func (w *DNSPingWrapper) Host() string {
+	return fmt.Sprintf("%v (%v)", w.spec, net.JoinHostPort(w.ip.IP.String(), strconv.Itoa(w.port)))
+}
+
+func (w *DNSPingWrapper) CalcStats(timeout_threshold int64) PWStats {
+	w.stats.ComputeState(timeout_threshold)
+	return w.stats.Snapshot()
+}
+
+func (w *DNSPingWrapper) Stats() *PWStats {
+	return w.stats
+}
+
+func (w *DNSPingWrapper) ResetStats() {
+	w.stats.Reset()
+}
+
+func (w *DNSPingWrapper) SetHostRepr(h string) {
+	w.stats.SetHostRepr(h)
+}
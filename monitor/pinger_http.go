@@ -0,0 +1,147 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// httpBodyLimit caps how much of a response body is read before closing it
+const httpBodyLimit = 64 << 10
+
+// HTTPPingWrapper probes an http:// or https:// URL with a GET request. 2xx
+// and 3xx responses count as replies, the response time as RTT.
+type HTTPPingWrapper struct {
+	url        string
+	ip         *net.IPAddr
+	port       int
+	insecure   bool // skip TLS certificate verification
+	client     *http.Client
+	stats      *PWStats
+	stop       chan struct{} // closed by Stop
+	stopOnce   sync.Once     // lets Stop be called more than once
+	limiter    *RateLimiter
+	gate       *ProbeGate
+	startDelay time.Duration // phase offset before the first probe
+	interval   time.Duration // time between two probes
+	timeout    time.Duration // time a probe waits for its response
+}
+
+func (w *HTTPPingWrapper) Start() {
+	w.stats.SetHostRepr(w.url)
+
+	// Connect to the address resolved at startup so the probe targets the
+	// IP shown, while Host header and SNI still come from the URL
+	addr := net.JoinHostPort(w.ip.String(), strconv.Itoa(w.port))
+	var dialer net.Dialer
+	w.client = &http.Client{
+		Timeout: w.timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: w.insecure},
+			DisableKeepAlives: true,
+		},
+		// A redirect already proves the server is up
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	w.stop = make(chan struct{})
+	go followPacing(w.stats, w.limiter, w.interval, w.stop)
+
+	go func(w *HTTPPingWrapper, stop chan struct{}) {
+		select {
+		case <-time.After(w.startDelay):
+		case <-stop:
+			return
+		}
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			// While paused probes are skipped, not delayed. Probes run one
+			// at a time, a slow one delays the next instead of overlapping.
+			if !w.gate.Paused() {
+				if !w.limiter.Wait(stop) {
+					return
+				}
+				w.probe()
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}(w, w.stop)
+}
+
+func (w *HTTPPingWrapper) probe() {
+	start := time.Now()
+	w.stats.RecordSend(start.UnixNano())
+	resp, err := w.client.Get(w.url)
+	if err != nil {
+		w.stats.SetHTTPStatus(0, httpErrorMessage(err, w.timeout))
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, httpBodyLimit))
+	resp.Body.Close()
+	rtt := time.Since(start)
+	if resp.StatusCode >= 400 {
+		w.stats.SetHTTPStatus(resp.StatusCode, fmt.Sprintf("HTTP %d %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+		return
+	}
+	w.stats.SetHTTPStatus(resp.StatusCode, "")
+	if w.stats.RejectRTT(rtt) {
+		return
+	}
+	w.stats.RecordReply(time.Now().UnixNano(), rtt)
+}
+
+// httpErrorMessage shortens a failed request's error to its cause, without
+// the method and URL the client prefixes
+func httpErrorMessage(err error, timeout time.Duration) string {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err.Error()
+	}
+	if urlErr.Timeout() {
+		return fmt.Sprintf("no response within %s", timeout)
+	}
+	return urlErr.Err.Error()
+}
+
+func (w *HTTPPingWrapper) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+func (w *HTTPPingWrapper) Host() string {
+	return fmt.Sprintf("%v (%v)", w.url, w.ip.String())
+}
+
+func (w *HTTPPingWrapper) CalcStats(timeout_threshold int64) PWStats {
+	w.stats.ComputeState(timeout_threshold)
+	return w.stats.Snapshot()
+}
+
+func (w *HTTPPingWrapper) Stats() *PWStats {
+	return w.stats
+}
+
+func (w *HTTPPingWrapper) ResetStats() {
+	w.stats.Reset()
+}
+
+func (w *HTTPPingWrapper) SetHostRepr(h string) {
+	w.stats.SetHostRepr(h)
+}
@@ -0,0 +1,251 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+type ProbingWrapper struct {
+	host       string
+	ip         *net.IPAddr
+	hstring    string
+	size       int
+	stats      *PWStats
+	privileged bool
+	limiter    *RateLimiter
+	gate       *ProbeGate
+	startDelay time.Duration // phase offset before the first probe
+	interval   time.Duration // time between two probes
+	retries    int           // max retries on transient send errors
+	retrySeq   int           // sequence the current retries apply to
+	retryCount int
+	stop       chan struct{} // closed by Stop
+	stopOnce   sync.Once     // lets Stop be called more than once
+}
+
+// sendRetryBackoff is the first retry delay, doubled on each attempt
+const sendRetryBackoff = 5 * time.Millisecond
+
+// isTransientSendError reports errors caused by momentary local resource
+// pressure (full socket buffers), worth retrying rather than counting as loss.
+func isTransientSendError(err error) bool {
+	return errors.Is(err, syscall.ENOBUFS) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EINTR)
+}
+
+func (w *ProbingWrapper) Start() {
+	// Use host as initial display name (DNS lookup happens later via periodic updates)
+	displayHost := w.host
+
+	w.hstring = fmt.Sprintf("%s (%s)", displayHost, w.ip.String())
+
+	w.stats.SetHostRepr(displayHost)
+
+	w.stop = make(chan struct{})
+	go followPacing(w.stats, w.limiter, w.interval, w.stop)
+	go w.run(w.stop)
+}
+
+// run runs pingers until stop is closed. pro-bing sends on its own ticker
+// and can't be held between two probes, so pausing stops the pinger and
+// resuming starts a fresh one, as does a change of the interval -max-pps
+// stretches.
+func (w *ProbingWrapper) run(stop chan struct{}) {
+	select {
+	case <-time.After(w.startDelay):
+	case <-stop:
+		return
+	}
+	attempt := 0
+	for {
+		select {
+		case <-w.gate.Resumed():
+		case <-stop:
+			return
+		}
+		w.stats.RestartSeq()
+		changed := w.limiter.Changed()
+		interval := w.limiter.Interval(w.interval)
+		pinger := w.newPinger(interval, stop)
+		done := make(chan error, 1)
+		go func() {
+			done <- pinger.Run()
+		}()
+	running:
+		for {
+			select {
+			case err := <-done:
+				// Run only fails on setup or the first send, and a failed
+				// pinger can't be rerun: transient errors are retried with
+				// a fresh one
+				if err == nil {
+					return
+				}
+				if !isTransientSendError(err) || attempt >= w.retries {
+					log.Fatalf("%s", err)
+				}
+				w.stats.RecordSendRetry()
+				if DebugMode {
+					fmt.Fprintf(os.Stderr, "DEBUG: %s: %v on first send, retry %d/%d\n", w.host, err, attempt+1, w.retries)
+				}
+				select {
+				case <-time.After(sendRetryBackoff << attempt):
+				case <-stop:
+					return
+				}
+				attempt++
+				break running
+			case <-changed:
+				changed = w.limiter.Changed()
+				if w.limiter.Interval(w.interval) == interval {
+					continue
+				}
+				pinger.Stop()
+				<-done
+				w.stats.DropInFlight(time.Now().UnixNano())
+				attempt = 0
+				break running
+			case <-w.gate.Pausing():
+				pinger.Stop()
+				<-done
+				// Its reply can't be received anymore
+				w.stats.DropInFlight(time.Now().UnixNano())
+				attempt = 0
+				break running
+			case <-stop:
+				pinger.Stop()
+				<-done
+				return
+			}
+		}
+	}
+}
+
+func (w *ProbingWrapper) newPinger(interval time.Duration, stop chan struct{}) *probing.Pinger {
+	pinger, err := probing.NewPinger(w.ip.String())
+	if err != nil {
+		log.Fatalf("pinger initialization failed %s, %s", w.host, err)
+	}
+
+	pinger.RecordRtts = false
+	// Wait for a token before the first send only: the interval keeps the
+	// next ones within the budget, and waiting in OnSend would hold up
+	// pro-bing's loop and add the wait to the RTTs
+	pinger.OnSetup = func() { w.limiter.Wait(stop) }
+	pinger.OnSend = w.onSend
+	pinger.OnSendError = w.onSendError
+	// pinger.OnSend = pingwrapper.OnRecv
+	pinger.OnRecv = w.onRecv
+	pinger.OnDuplicateRecv = w.onDuplicateRecv
+	pinger.Size = w.size
+	pinger.Interval = interval
+	pinger.Debug = DebugMode
+	if runtime.GOOS == "linux" {
+		pinger.SetDoNotFragment(true)
+	}
+
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		pinger.SetPrivileged(true)
+	} else {
+		pinger.SetPrivileged(w.privileged)
+	}
+	return pinger
+}
+
+func (w *ProbingWrapper) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+func (w *ProbingWrapper) onSend(pkt *probing.Packet) {
+	w.stats.RecordSend(time.Now().UnixNano())
+	w.limiter.Take()
+}
+
+// onSendError backs off on transient errors. pro-bing retries ENOBUFS
+// itself once this returns, so the sleep paces those retries; other
+// transient errors skip the probe, which then counts as a miss.
+func (w *ProbingWrapper) onSendError(pkt *probing.Packet, err error) {
+	if !isTransientSendError(err) {
+		return
+	}
+	if pkt.Seq != w.retrySeq {
+		w.retrySeq = pkt.Seq
+		w.retryCount = 0
+	}
+	if w.retryCount >= w.retries {
+		if DebugMode {
+			fmt.Fprintf(os.Stderr, "DEBUG: %s: seq %d: %v, retries exhausted\n", w.host, pkt.Seq, err)
+		}
+		// Keep pacing pro-bing's own ENOBUFS loop instead of spinning
+		time.Sleep(sendRetryBackoff << w.retries)
+		return
+	}
+	w.stats.RecordSendRetry()
+	if DebugMode {
+		fmt.Fprintf(os.Stderr, "DEBUG: %s: seq %d: %v, retry %d/%d\n", w.host, pkt.Seq, err, w.retryCount+1, w.retries)
+	}
+	time.Sleep(sendRetryBackoff << w.retryCount)
+	w.retryCount++
+}
+
+func (w *ProbingWrapper) onRecv(pkt *probing.Packet) {
+	// p.lastread = fmt.Sprintf("%d bytes from %s (%s): icmp_seq=%d time=%v",
+	//	pkt.Nbytes, p.host, pkt.IPAddr, pkt.Seq, pkt.Rtt)
+	// fmt.Print(p.lastread)
+	w.stats.RecordSeq(pkt.Seq)
+	if w.stats.RejectRTT(pkt.Rtt) {
+		return
+	}
+	w.stats.RecordReply(time.Now().UnixNano(), pkt.Rtt)
+}
+
+func (w *ProbingWrapper) onDuplicateRecv(pkt *probing.Packet) {
+	// p.lastread = fmt.Sprintf("%d bytes from %s: icmp_seq=%d time=%v ttl=%v (DUP!)", pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Rtt, pkt.TTL)
+	w.stats.RecordDuplicate()
+}
+
+func (w *ProbingWrapper) Host() string {
+	return w.hstring
+}
+
+func (w *ProbingWrapper) CalcStats(timeout_threshold int64) PWStats {
+	w.stats.ComputeState(timeout_threshold)
+	return w.stats.Snapshot()
+}
+
+func (w *ProbingWrapper) Stats() *PWStats {
+	return w.stats
+}
+
+func (w *ProbingWrapper) ResetStats() {
+	w.stats.Reset()
+}
+
+var divs = []time.Duration{
+	time.Duration(1), time.Duration(10), time.Duration(100), time.Duration(1000)}
+
+func (w *ProbingWrapper) SetHostRepr(h string) {
+	w.stats.SetHostRepr(h)
+}
+
+func Round(d time.Duration, digits int) time.Duration {
+	switch {
+	case d > time.Second:
+		d = d.Round(time.Second / divs[digits])
+	case d > time.Millisecond:
+		d = d.Round(time.Millisecond / divs[digits])
+	case d > time.Microsecond:
+		d = d.Round(time.Microsecond / divs[digits])
+	}
+	return d
+}
@@ -1,4 +1,4 @@
-package main
+package monitor
 
 import (
 	"bufio"
@@ -22,6 +22,7 @@ type SystemPingWrapper struct {
 	stats        *PWStats
 	cmd          *exec.Cmd
 	ping_options string
+	gate         *ProbeGate
 }
 
 var time_extractor = regexp.MustCompile(`time[=<]([\d\.]+) *(.?s)`)
@@ -32,7 +33,6 @@ func (w *SystemPingWrapper) Start() {
 	displayHost := w.host
 	w.hstring = fmt.Sprintf("%s (%s)", displayHost, w.ip.String())
 	w.stats.SetHostRepr(displayHost)
-	w.stats.iprepr = w.ip.String()
 
 	var path string
 
@@ -67,22 +67,27 @@ func (w *SystemPingWrapper) Start() {
 	w.cmd = exec.Command(path, args...)
 	w.cmd.Env = append(w.cmd.Environ(), "LANG=C")
 
-	w.stats = &PWStats{
-		state: true,
-	}
+	w.stats.State = true
 	r, _ := w.cmd.StdoutPipe()
 	scanner := bufio.NewScanner(r)
 	go func() {
 		// Read line by line and process it
 		for scanner.Scan() {
 			line := scanner.Text()
+			// The ping process can't be paused, its replies are dropped instead
+			if w.gate.Paused() {
+				continue
+			}
 			extracted := extractor.FindAllStringSubmatch(line, -1)
 			if len(extracted) > 0 {
-				w.stats.lastrecv = time.Now().UnixNano()
-				w.stats.lastrtt_as_string = extracted[0][1] + extracted[0][2]
+				rtt, err := time.ParseDuration(extracted[0][1] + extracted[0][2])
+				if err == nil && w.stats.RejectRTT(rtt) {
+					continue
+				}
+				w.stats.RecordSystemReply(time.Now().UnixNano(), rtt, extracted[0][1]+extracted[0][2])
 			}
 		}
-		w.stats.error_message = fmt.Sprintf("%v exited code %v", w.cmd.String(), w.cmd.ProcessState.ExitCode())
+		w.stats.SetError(fmt.Sprintf("%v exited code %v", w.cmd.String(), w.cmd.ProcessState.ExitCode()))
 	}()
 	w.cmd.Start()
 }
@@ -97,13 +102,17 @@ func (w *SystemPingWrapper) Host() string {
 
 func (w *SystemPingWrapper) CalcStats(timeout_threshold int64) PWStats {
 	w.stats.ComputeState(timeout_threshold)
-	return *w.stats
+	return w.stats.Snapshot()
 }
 
 func (w *SystemPingWrapper) Stats() *PWStats {
 	return w.stats
 }
 
+func (w *SystemPingWrapper) ResetStats() {
+	w.stats.Reset()
+}
+
 func (w *SystemPingWrapper) SetHostRepr(h string) {
 	w.stats.SetHostRepr(h)
 }
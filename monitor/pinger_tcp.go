@@ -0,0 +1,128 @@
+//go:build !windows
+
+package monitor
+
+// inspired from https://github.com/cloverstd/tcping/blob/master/ping/tcp/tcp.go
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+type TCPPingWrapper struct {
+	host       string
+	ip         *net.IPAddr
+	hstring    string
+	port       int
+	str_tgt    string
+	stats      *PWStats
+	stop       chan struct{} // closed by Stop
+	stopOnce   sync.Once     // lets Stop be called more than once
+	loopTicker *time.Ticker
+	limiter    *RateLimiter
+	gate       *ProbeGate
+	startDelay time.Duration // phase offset before the first probe
+	interval   time.Duration // time between two probes
+	timeout    time.Duration // time a probe waits for the connection
+}
+
+func (w *TCPPingWrapper) Start() {
+	// Use host as initial display name (DNS lookup happens later via periodic updates)
+	displayHost := w.host
+	w.hstring = fmt.Sprintf("tcp://%v:%v (%v:%v)", displayHost, w.port, w.ip.String(), w.port)
+	w.stats.SetHostRepr(fmt.Sprintf("tcp://%v:%v", displayHost, w.port))
+
+	if strings.Contains(w.ip.IP.String(), ":") {
+		w.str_tgt = fmt.Sprintf("[%v]:%v", w.ip.String(), w.port)
+		w.hstring = fmt.Sprintf("tcp://%v:%v ([%v]:%v)", displayHost, w.port, w.ip.String(), w.port)
+	} else {
+		w.str_tgt = fmt.Sprintf("%v:%v", w.ip.String(), w.port)
+	}
+
+	w.stop = make(chan struct{})
+	w.loopTicker = time.NewTicker(w.interval)
+	go followPacing(w.stats, w.limiter, w.interval, w.stop)
+
+	go func(w *TCPPingWrapper, stop chan struct{}) {
+		if w.startDelay > 0 {
+			select {
+			case <-time.After(w.startDelay):
+			case <-stop:
+				return
+			}
+			// Drop the tick that accumulated while sleeping
+			w.loopTicker.Reset(w.interval)
+		}
+		for {
+			// While paused probes are skipped, not delayed
+			if !w.gate.Paused() {
+				if !w.limiter.Wait(stop) {
+					return
+				}
+				go func(t *TCPPingWrapper) {
+					t.spawnChecker()
+				}(w)
+			}
+			select {
+			case <-w.loopTicker.C:
+			case <-stop:
+				return
+			}
+		}
+	}(w, w.stop)
+
+}
+
+func (w *TCPPingWrapper) spawnChecker() {
+	checker := tcpshaker.NewChecker()
+
+	ctx, stopChecker := context.WithCancel(context.Background())
+	defer stopChecker()
+	go func() {
+		if err := checker.CheckingLoop(ctx); err != nil {
+			fmt.Println("checking loop stopped due to fatal error: ", err)
+		}
+	}()
+	<-checker.WaitReady()
+	start := time.Now()
+	w.stats.RecordSend(time.Now().UnixNano())
+	err := checker.CheckAddr(w.str_tgt, w.timeout)
+	rtt := time.Since(start)
+	if err == nil && !w.stats.RejectRTT(rtt) {
+		w.stats.RecordReply(time.Now().UnixNano(), rtt)
+	}
+}
+
+func (w *TCPPingWrapper) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		w.loopTicker.Stop()
+	})
+}
+
+func (w *TCPPingWrapper) Host() string {
+	return w.hstring
+}
+
+func (w *TCPPingWrapper) CalcStats(timeout_threshold int64) PWStats {
+	w.stats.ComputeState(timeout_threshold)
+	return w.stats.Snapshot()
+}
+
+func (w *TCPPingWrapper) Stats() *PWStats {
+	return w.stats
+}
+
+func (w *TCPPingWrapper) ResetStats() {
+	w.stats.Reset()
+}
+
+func (w *TCPPingWrapper) SetHostRepr(h string) {
+	w.stats.SetHostRepr(h)
+}
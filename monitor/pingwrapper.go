@@ -0,0 +1,343 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PingWrapperInterface interface {
+	Start()
+	Stop()
+	Host() string
+	CalcStats(int64) PWStats
+	Stats() *PWStats
+	SetHostRepr(string)
+	ResetStats()
+}
+
+// DefaultProbeInterval is the time between two probes of the same host
+// unless -interval or a profile changes it.
+const DefaultProbeInterval = time.Second
+
+// MinProbeInterval is the shortest -interval accepted
+const MinProbeInterval = 10 * time.Millisecond
+
+// DefaultProbeTimeout is how long a probe waits for its reply unless
+// -timeout changes it
+const DefaultProbeTimeout = time.Second
+
+// offlineAfter returns the silence after which a host is offline: misses
+// probes in a row, the last one given its full timeout when that is longer
+// than the interval
+func offlineAfter(interval, timeout time.Duration, misses int) int64 {
+	return int64(max(interval*time.Duration(misses), interval*time.Duration(misses-1)+timeout))
+}
+
+// DefaultOfflineAfter is the threshold passed to CalcStats, in ns. Wrappers
+// built with -misses override it with their own offline_after, so it only
+// applies to stats without one: -misses 2 at the default interval.
+const DefaultOfflineAfter = int64(2 * DefaultProbeInterval)
+
+// ProbeOptions configures the wrappers of a PingService. Interval and
+// Timeout default to DefaultProbeInterval and DefaultProbeTimeout, other
+// zero values turn their feature off.
+type ProbeOptions struct {
+	Privileged        bool              // raw ICMP sockets for pure Go ping
+	Size              int               // pure Go ICMP payload size
+	System            bool              // use the system's ping instead of pure Go ping
+	SystemPingOptions string            // extra arguments of the system's ping
+	Limiter           *RateLimiter      // -max-pps budget shared by all wrappers, nil = unlimited
+	Gate              *ProbeGate        // pauses probing of all wrappers, see PingService.Pause
+	MaxRTT            time.Duration     // replies slower than this count as lost
+	OnlineMaxRTT      time.Duration     // replies slower than this make the host degraded
+	JitterStart       bool              // random phase before the first probe
+	ICMPRetries       int               // retries of ICMP sends failing on a transient error
+	LossThreshold     float64           // loss percentage above which an online host is lossy
+	LossWindow        time.Duration     // recent time windowed loss covers (0 = since start)
+	HTTPInsecure      bool              // skip TLS certificate verification of https probes
+	Expect            map[string]string // host -> expected state ("up" or "down")
+	Alias             map[string]string // host -> display name from the host file
+	Group             map[string]string // host -> group from the host file
+	Interval          time.Duration     // time between two probes of a host
+	Timeout           time.Duration     // time a probe waits for its reply
+	Misses            int               // unanswered probes before a host is offline
+}
+
+var ReHostWithProto = regexp.MustCompile(`^(tcp|ip)([46])?://(\[?.+?\]?)(?::(\d+))?$`)
+
+// HostSpec is a parsed host argument.
+type HostSpec struct {
+	Proto  string // "tcp", "ip", "http", "https", "dns" or empty
+	Family string // "4", "6" or empty
+	Host   string
+	Port   int
+	URL    string // full URL for http(s) probing
+	Name   string // name queried by dns probing
+}
+
+// ParseHostSpec splits a host argument into protocol, address family, host
+// and port, validating the port for tcp probing.
+func ParseHostSpec(host string) (HostSpec, error) {
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return parseHTTPSpec(host)
+	}
+	if strings.HasPrefix(host, "dns://") {
+		return parseDNSSpec(host)
+	}
+
+	host_findings := ReHostWithProto.FindAllStringSubmatch(host, -1)
+
+	var spec HostSpec
+	var found_port string
+
+	if len(host_findings) > 0 {
+		spec.Proto = host_findings[0][1]
+		spec.Family = host_findings[0][2]
+		spec.Host = host_findings[0][3]
+		found_port = host_findings[0][4]
+	} else {
+		spec.Host = host
+	}
+
+	if spec.Proto == "tcp" {
+
+		if found_port == "" {
+			return spec, fmt.Errorf("%v: tcp probing requested but no port given", host)
+		}
+		port, err := strconv.Atoi(found_port)
+		if err != nil {
+			return spec, fmt.Errorf("%v: %v", host, err)
+		}
+		if port <= 0 || port > 65535 {
+			return spec, fmt.Errorf("%v: tcp probing port invalid: %v", host, port)
+		}
+		spec.Port = port
+	}
+	return spec, nil
+}
+
+// parseHTTPSpec parses an http:// or https:// URL, defaulting the port from
+// the scheme.
+func parseHTTPSpec(host string) (HostSpec, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return HostSpec{}, fmt.Errorf("%v: %v", host, err)
+	}
+	if u.Hostname() == "" {
+		return HostSpec{}, fmt.Errorf("%v: no host in URL", host)
+	}
+	spec := HostSpec{Proto: u.Scheme, Host: u.Hostname(), URL: host, Port: 80}
+	if u.Scheme == "https" {
+		spec.Port = 443
+	}
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return spec, fmt.Errorf("%v: http probing port invalid: %v", host, p)
+		}
+		spec.Port = port
+	}
+	return spec, nil
+}
+
+// parseDNSSpec parses a dns://resolver[:port][/name] target, defaulting the
+// port to 53 and the queried name to defaultDNSQueryName.
+func parseDNSSpec(host string) (HostSpec, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return HostSpec{}, fmt.Errorf("%v: %v", host, err)
+	}
+	if u.Hostname() == "" {
+		return HostSpec{}, fmt.Errorf("%v: no resolver in dns target", host)
+	}
+	spec := HostSpec{Proto: "dns", Host: u.Hostname(), Port: 53, Name: strings.Trim(u.Path, "/")}
+	if spec.Name == "" {
+		spec.Name = defaultDNSQueryName
+	}
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return spec, fmt.Errorf("%v: dns probing port invalid: %v", host, p)
+		}
+		spec.Port = port
+	}
+	return spec, nil
+}
+
+func NewPingWrapper(host string, options ProbeOptions, transition_writer *TransitionWriter) PingWrapperInterface {
+	pw, err := newPingWrapper(host, options, transition_writer)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return pw
+}
+
+// newPingWrapper is NewPingWrapper returning an invalid or unresolvable host
+// as error instead of exiting, for hosts replaced while running
+func newPingWrapper(host string, options ProbeOptions, transition_writer *TransitionWriter) (PingWrapperInterface, error) {
+	spec, err := ParseHostSpec(host)
+	if err != nil {
+		return nil, err
+	}
+	found_proto, found_ip_family, found_host, found_port_int := spec.Proto, spec.Family, spec.Host, spec.Port
+
+	ip, err := Resolve(found_host, found_ip_family)
+	if err != nil {
+		return nil, err
+	}
+	// IPRepr is known from here on so callers can report it before Start()
+	stats := &PWStats{pwStatsData: pwStatsData{
+		transition_writer: transition_writer,
+		IPRepr:            ip.IP.String(),
+		Target:            host,
+		MaxRTT:            options.MaxRTT,
+		OnlineMaxRTT:      options.OnlineMaxRTT,
+		loss_threshold:    options.LossThreshold,
+		Expect:            options.Expect[host],
+		Alias:             options.Alias[host],
+		Group:             options.Group[host],
+		rtt:               &rttRing{},
+	}}
+	interval := DefaultProbeInterval
+	if options.Interval > 0 {
+		interval = options.Interval
+	}
+	timeout := DefaultProbeTimeout
+	if options.Timeout > 0 {
+		timeout = options.Timeout
+	}
+	if options.Gate.Paused() {
+		stats.Pause(time.Now().UnixNano())
+	}
+	stats.probe_interval = interval
+	stats.probe_timeout = timeout
+	stats.LossWindow = options.LossWindow
+	if options.Misses > 0 {
+		// Paced wrappers follow -max-pps from Start on, see followPacing
+		stats.misses = options.Misses
+		stats.offline_after = offlineAfter(options.Limiter.Interval(interval), timeout, stats.misses)
+	}
+
+	// Random phase so hosts started together don't probe in lockstep
+	var startDelay time.Duration
+	if options.JitterStart {
+		startDelay = time.Duration(rand.Int64N(int64(interval)))
+	}
+
+	if found_proto == "http" || found_proto == "https" {
+		return &HTTPPingWrapper{
+			url:        spec.URL,
+			ip:         ip,
+			port:       found_port_int,
+			insecure:   options.HTTPInsecure,
+			stats:      stats,
+			limiter:    options.Limiter,
+			gate:       options.Gate,
+			startDelay: startDelay,
+			interval:   interval,
+			timeout:    timeout,
+		}, nil
+	} else if found_proto == "dns" {
+		stats.DNSName = spec.Name
+		return &DNSPingWrapper{
+			spec:       host,
+			ip:         ip,
+			port:       found_port_int,
+			name:       spec.Name,
+			stats:      stats,
+			limiter:    options.Limiter,
+			gate:       options.Gate,
+			startDelay: startDelay,
+			interval:   interval,
+			timeout:    timeout,
+		}, nil
+	} else if found_proto == "tcp" {
+		return &TCPPingWrapper{
+			host:       found_host,
+			ip:         ip,
+			port:       found_port_int,
+			stats:      stats,
+			limiter:    options.Limiter,
+			gate:       options.Gate,
+			startDelay: startDelay,
+			interval:   interval,
+			timeout:    timeout,
+		}, nil
+	} else if options.System {
+		// The system's ping keeps its own 1s cadence and timeout
+		stats.probe_interval = DefaultProbeInterval
+		stats.probe_timeout = 0
+		// The system's ping is given the address with its zone
+		stats.IPRepr = ip.String()
+		if stats.misses > 0 {
+			stats.offline_after = int64(DefaultProbeInterval) * int64(stats.misses)
+		}
+		return &SystemPingWrapper{
+			host:         host,
+			ip:           ip,
+			stats:        stats,
+			ping_options: options.SystemPingOptions,
+			gate:         options.Gate,
+		}, nil
+	} else {
+		return &ProbingWrapper{
+			host:       host,
+			ip:         ip,
+			privileged: options.Privileged,
+			size:       options.Size,
+			stats:      stats,
+			limiter:    options.Limiter,
+			gate:       options.Gate,
+			startDelay: startDelay,
+			interval:   interval,
+			retries:    options.ICMPRetries,
+			retrySeq:   -1,
+		}, nil
+	}
+}
+
+// reuseWrappers builds the wrappers for hosts, taking over the wrapper of a
+// host that was already probed under the same expect=/alias/group so its
+// stats survive. It returns all wrappers in the order of hosts, the new ones
+// that still need Start() and the old ones that are gone and need Stop().
+// A host that can't be probed fails it all, old stays untouched.
+func reuseWrappers(old []PingWrapperInterface, hosts []string, options ProbeOptions, transition_writer *TransitionWriter) (wrappers, added, removed []PingWrapperInterface, err error) {
+	byTarget := make(map[string]PingWrapperInterface, len(old))
+	for _, pw := range old {
+		stats := pw.Stats()
+		if _, dup := byTarget[stats.Target]; dup || stats.Expect != options.Expect[stats.Target] || stats.Alias != options.Alias[stats.Target] || stats.Group != options.Group[stats.Target] {
+			removed = append(removed, pw)
+			continue
+		}
+		byTarget[stats.Target] = pw
+	}
+
+	wrappers = make([]PingWrapperInterface, len(hosts))
+	for i, host := range hosts {
+		if pw, ok := byTarget[host]; ok {
+			wrappers[i] = pw
+			delete(byTarget, host)
+			continue
+		}
+		if wrappers[i], err = newPingWrapper(host, options, transition_writer); err != nil {
+			return nil, nil, nil, err
+		}
+		added = append(added, wrappers[i])
+	}
+	for _, pw := range byTarget {
+		removed = append(removed, pw)
+	}
+	return wrappers, added, removed, nil
+}
+
+func Resolve(host string, ip_family string) (*net.IPAddr, error) {
+	host = strings.Trim(host, "[]")
+	return net.ResolveIPAddr("ip"+ip_family, host)
+}
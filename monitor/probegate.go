@@ -0,0 +1,85 @@
+package monitor
+
+import "sync"
+
+// ProbeGate pauses probing of every wrapper sharing it, like RateLimiter
+// paces it. A nil gate never pauses.
+type ProbeGate struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{} // closed by Resume, replaced by Pause
+	pause  chan struct{} // closed by Pause, replaced by Resume
+}
+
+// Pause stops probes from being issued until Resume
+func (g *ProbeGate) Pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return
+	}
+	g.paused = true
+	g.resume = make(chan struct{})
+	if g.pause == nil {
+		g.pause = make(chan struct{})
+	}
+	close(g.pause)
+}
+
+// Resume lets probes be issued again and releases waiting wrappers
+func (g *ProbeGate) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return
+	}
+	g.paused = false
+	g.pause = nil
+	close(g.resume)
+}
+
+// Paused reports whether probing is paused, for loops that skip a probe
+// rather than block
+func (g *ProbeGate) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Resumed returns a channel closed while probing isn't paused, for loops
+// that wait before issuing probes
+func (g *ProbeGate) Resumed() <-chan struct{} {
+	if g == nil {
+		return closedChan()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return closedChan()
+	}
+	return g.resume
+}
+
+// Pausing returns a channel closed once probing pauses, for wrappers that
+// must stop a probe loop they can't hold between two probes. It never
+// closes for a nil gate.
+func (g *ProbeGate) Pausing() <-chan struct{} {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.pause == nil {
+		g.pause = make(chan struct{})
+	}
+	return g.pause
+}
+
+func closedChan() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}
@@ -0,0 +1,49 @@
+package monitor
+
+import "testing"
+
+func closed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestProbeGate(t *testing.T) {
+	var nilGate *ProbeGate
+	if nilGate.Paused() || !closed(nilGate.Resumed()) || nilGate.Pausing() != nil {
+		t.Error("nil gate pauses")
+	}
+
+	g := &ProbeGate{}
+	pausing := g.Pausing()
+	if g.Paused() || !closed(g.Resumed()) || closed(pausing) {
+		t.Fatal("new gate paused")
+	}
+
+	g.Pause()
+	resumed := g.Resumed()
+	if !g.Paused() || closed(resumed) || !closed(pausing) || !closed(g.Pausing()) {
+		t.Fatal("gate not paused by Pause")
+	}
+	// Pausing twice keeps the same wait
+	g.Pause()
+	if g.Resumed() != resumed {
+		t.Error("second Pause replaced the Resumed channel")
+	}
+
+	g.Resume()
+	if g.Paused() || !closed(resumed) || !closed(g.Resumed()) || closed(g.Pausing()) {
+		t.Fatal("gate not resumed by Resume")
+	}
+	g.Resume()
+
+	// A second pause closes the channel handed out since the resume
+	pausing = g.Pausing()
+	g.Pause()
+	if !closed(pausing) {
+		t.Error("Pausing not closed by the second Pause")
+	}
+}
@@ -0,0 +1,687 @@
+package monitor
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// PWStats holds the state and counters of a host. The probe goroutine
+// writes them through the locked methods below, readers on other goroutines
+// work on a Snapshot.
+type PWStats struct {
+	pwStatsData
+	hreprMu sync.RWMutex // protects hrepr for concurrent DNS updates
+	mu      sync.Mutex   // guards pwStatsData
+}
+
+// pwStatsData is PWStats without its locks, so that Snapshot can copy it
+type pwStatsData struct {
+	LastSent               int64
+	LastRecv               int64
+	LastRTT                time.Duration
+	LastRTTString          string
+	LastLossNano           int64
+	LastLossDuration       int64
+	LastSeenNano           int64
+	State                  bool
+	HasEverReceived        bool
+	state_initialized      bool
+	skip_next_up_highlight bool
+	LastUpTransition       int64
+	startup_time           int64
+	last_compute           int64
+	uptime_nano            int64
+	transition_writer      *TransitionWriter
+	ErrorMessage           string
+	hrepr                  string
+	IPRepr                 string
+	Target                 string        // host as given on the command line
+	SeqTracking            bool          // wrapper reports ICMP sequence numbers
+	highest_seq            int           // highest sequence received so far
+	SeqGaps                int64         // sequences skipped when a later one arrived
+	Reorders               int64         // replies arriving after a higher sequence
+	DupReplies             int64         // replies received more than once
+	MaxRTT                 time.Duration // replies slower than this count as lost (0 = disabled)
+	OverMaxRTT             int64         // replies rejected because of MaxRTT
+	OnlineMaxRTT           time.Duration // replies slower than this make the host degraded (0 = disabled)
+	offline_after          int64         // ns without reply before offline, overrides the caller's threshold (0 = caller's)
+	misses                 int           // unanswered probes offline_after allows for (0 = caller's threshold)
+	probe_interval         time.Duration // time between two probes
+	probe_timeout          time.Duration // replies slower than this are misses, like MaxRTT but not counted (0 = none)
+	Degraded               bool          // replying, but last RTT is at or above OnlineMaxRTT
+	SendRetries            int64         // sends retried after a transient error
+	PacketsSent            int64         // probes sent since start or reset
+	PacketsRecv            int64         // probes answered (within MaxRTT) since start or reset
+	loss_threshold         float64       // loss percentage above which an online host is lossy (0 = disabled)
+	LossWindow             time.Duration // recent time WindowLossPercent covers (0 = since start or reset)
+	loss_marks             []lossMark    // counter snapshots covering LossWindow, oldest first
+	rtt                    *rttRing      // latest replies, shared with the copies CalcStats returns
+	Jitter                 time.Duration // smoothed mean deviation of consecutive RTTs (RFC 3550)
+	jitter_prev            time.Duration // RTT of the previous reply, 0 = none since start or the last outage
+	miss_streak            int64         // probes unanswered since the last reply
+	MaxMissStreak          int64         // longest miss_streak since start or reset
+	HTTPStatus             int           // status code of the last http(s) probe (0 = no response)
+	DNSName                string        // name queried by dns:// probing
+	DNSResult              string        // addresses or error of the last dns:// probe
+	Expect                 string        // state declared in the host file: "up", "down" or empty
+	Alias                  string        // name given in the host file, shown instead of hrepr
+	Group                  string        // group given in the host file, filtered on with c in the TUI
+	Transitions            int64         // state changes since start or reset
+	paused_at              int64         // UnixNano probing was paused at, 0 = running
+}
+
+// RecordSend counts a probe sent at now. The previous probe is a miss if it
+// is still unanswered, which extends the current miss streak.
+func (p *PWStats) RecordSend(now int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.PacketsSent > 0 && p.LastSent > p.LastRecv {
+		p.miss_streak++
+		if p.miss_streak > p.MaxMissStreak {
+			p.MaxMissStreak = p.miss_streak
+		}
+	}
+	p.LastSent = now
+	p.PacketsSent++
+}
+
+// RecordReply counts a reply received at now, rtt after its probe was sent
+func (p *PWStats) RecordReply(now int64, rtt time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.HasEverReceived = true
+	p.PacketsRecv++
+	p.miss_streak = 0
+	p.LastRecv = now
+	p.LastRTT = rtt
+	p.addRTTSample(now, rtt)
+	p.LastRTTString = Round(rtt, 2).String()
+}
+
+// RecordSystemReply records a reply read from the system's ping output at
+// now, with its RTT as printed. rtt is 0 when the printed one can't be
+// parsed, and isn't sampled then. Sends aren't seen, so replies aren't
+// counted either.
+func (p *PWStats) RecordSystemReply(now int64, rtt time.Duration, printed string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.LastRecv = now
+	p.LastRTT = rtt
+	if rtt > 0 {
+		p.addRTTSample(now, rtt)
+	}
+	p.LastRTTString = printed
+}
+
+// RecordSendRetry counts a send retried after a transient error
+func (p *PWStats) RecordSendRetry() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.SendRetries++
+}
+
+// RecordDuplicate counts a reply received more than once
+func (p *PWStats) RecordDuplicate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.DupReplies++
+}
+
+// SetError sets the error shown for the host, "" once probes work again
+func (p *PWStats) SetError(msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ErrorMessage = msg
+}
+
+// SetHTTPStatus records the status code of the last http(s) probe, 0 without
+// a response, along with the error it amounts to
+func (p *PWStats) SetHTTPStatus(status int, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.HTTPStatus = status
+	p.ErrorMessage = msg
+}
+
+// SetDNSResult records the addresses or error of the last dns:// probe
+func (p *PWStats) SetDNSResult(result string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.DNSResult = result
+}
+
+// SetPacedInterval derives offline_after from the time between two probes
+// as -max-pps paces them, which may be longer than probe_interval
+func (p *PWStats) SetPacedInterval(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.misses > 0 {
+		p.offline_after = offlineAfter(interval, p.probe_timeout, p.misses)
+	}
+}
+
+// DropInFlight forgets the latest probe if it is unanswered and may still
+// be, for wrappers abandoning it when probing pauses, so it counts neither
+// as sent nor as a miss
+func (p *PWStats) DropInFlight(now int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.PacketsSent > 0 && p.LastSent > p.LastRecv && now-p.LastSent < int64(p.probe_timeout) {
+		p.PacketsSent--
+		p.LastSent = p.LastRecv
+	}
+}
+
+// RejectRTT reports whether a reply with the given RTT must be treated as a
+// miss because it exceeds MaxRTT, counting it if so, or the probe timeout.
+func (p *PWStats) RejectRTT(rtt time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.MaxRTT > 0 && rtt > p.MaxRTT {
+		p.OverMaxRTT++
+		return true
+	}
+	return p.probe_timeout > 0 && rtt > p.probe_timeout
+}
+
+// lossMark is a snapshot of the probe counters. The windowed loss is the
+// difference between the current counters and the oldest mark.
+type lossMark struct {
+	at   int64 // UnixNano of the snapshot
+	sent int64 // settledSent() at that time
+	recv int64
+}
+
+// lossMarksPerWindow is how many marks span LossWindow, so memory per host
+// does not grow with the window
+const lossMarksPerWindow = 60
+
+// markLoss snapshots the counters for WindowLossPercent, at most
+// lossMarksPerWindow times per window, and drops marks that left it.
+func (p *PWStats) markLoss(now int64) {
+	if p.LossWindow <= 0 {
+		return
+	}
+	marks := p.loss_marks
+	drop := 0
+	for drop < len(marks) && now-marks[drop].at > int64(p.LossWindow) {
+		drop++
+	}
+	marks = marks[drop:]
+	step := max(int64(p.LossWindow)/lossMarksPerWindow, int64(time.Second))
+	if n := len(marks); n > 0 && now-marks[n-1].at < step {
+		p.loss_marks = marks
+		return
+	}
+	p.loss_marks = append(marks, lossMark{now, p.settledSent(), p.PacketsRecv})
+}
+
+// RTTSample is one reply kept for statistics
+type RTTSample struct {
+	At  int64 // UnixNano of the reply
+	RTT time.Duration
+}
+
+// RTTHistorySize caps the samples kept per host (5 minutes at one probe per
+// second). The ring is allocated as replies come in, silent hosts cost nothing.
+const RTTHistorySize = 300
+
+// rttRing holds the latest replies of a host. The probe goroutine adds to it
+// while the display and the status server read it and /reset clears it, so
+// it is locked, and referenced rather than copied with the stats.
+type rttRing struct {
+	mu      sync.Mutex
+	samples []RTTSample // grown up to RTTHistorySize
+	next    int         // slot to overwrite once full
+}
+
+// addRTTSample records a reply in the RTT ring. Called with the lock held.
+func (p *PWStats) addRTTSample(at int64, rtt time.Duration) {
+	p.updateJitter(rtt)
+	r := p.rtt
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.samples) < RTTHistorySize {
+		r.samples = append(r.samples, RTTSample{at, rtt})
+		return
+	}
+	r.samples[r.next] = RTTSample{at, rtt}
+	r.next = (r.next + 1) % RTTHistorySize
+}
+
+// Pause freezes the state at now until Resume, see PingService.Pause
+func (p *PWStats) Pause(now int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused_at == 0 {
+		p.paused_at = now
+	}
+}
+
+// Resume cuts the time paused out of the stats: the timestamps their state,
+// uptime and windowed loss derive from move forward by it, so the silence
+// while paused counts neither as an outage nor as loss.
+func (p *PWStats) Resume(now int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused_at == 0 {
+		return
+	}
+	d := now - p.paused_at
+	for _, t := range []*int64{&p.LastSent, &p.LastRecv, &p.last_compute, &p.startup_time} {
+		// A probe in flight may have been answered while paused
+		if *t != 0 && *t < p.paused_at {
+			*t += d
+		}
+	}
+	// Copied, snapshots share the marks
+	marks := make([]lossMark, len(p.loss_marks))
+	for i, m := range p.loss_marks {
+		m.at += d
+		marks[i] = m
+	}
+	p.loss_marks = marks
+	p.paused_at = 0
+}
+
+// updateJitter folds the difference to the previous reply's RTT into the
+// jitter like RFC 3550 does for interarrival jitter. The first reply after
+// start or an outage only becomes the reference, leaving jitter at 0.
+func (p *PWStats) updateJitter(rtt time.Duration) {
+	if p.jitter_prev > 0 {
+		d := rtt - p.jitter_prev
+		if d < 0 {
+			d = -d
+		}
+		p.Jitter += (d - p.Jitter) / 16
+	}
+	p.jitter_prev = rtt
+}
+
+// RTTSampleCount returns how many replies the RTT statistics are based on.
+func (p *PWStats) RTTSampleCount() int {
+	r := p.rtt
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.samples)
+}
+
+// RTTStats returns the minimum, average, maximum and standard deviation of
+// the RTT samples. They cover the ring, not the time since the last
+// transition: outages add no samples, so the ring already reflects recent
+// replies and aggregates need no reset when a host comes back.
+func (p *PWStats) RTTStats() (minRTT, avg, maxRTT, stddev time.Duration) {
+	samples := p.RecentRTTSamples(RTTHistorySize)
+	if len(samples) == 0 {
+		return 0, 0, 0, 0
+	}
+	minRTT, maxRTT = samples[0].RTT, samples[0].RTT
+	var sum float64
+	for _, s := range samples {
+		minRTT = min(minRTT, s.RTT)
+		maxRTT = max(maxRTT, s.RTT)
+		sum += float64(s.RTT)
+	}
+	mean := sum / float64(len(samples))
+	var sq float64
+	for _, s := range samples {
+		d := float64(s.RTT) - mean
+		sq += d * d
+	}
+	return minRTT, time.Duration(mean), maxRTT, time.Duration(math.Sqrt(sq / float64(len(samples))))
+}
+
+// RecentRTTs returns up to n of the latest RTT samples, oldest first.
+func (p *PWStats) RecentRTTs(n int) []time.Duration {
+	samples := p.RecentRTTSamples(n)
+	out := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		out[i] = s.RTT
+	}
+	return out
+}
+
+// RecentRTTSamples returns up to n of the latest RTT samples with the time
+// of their reply, oldest first. Stats not built by a wrapper have no ring and
+// no samples.
+func (p *PWStats) RecentRTTSamples(n int) []RTTSample {
+	r := p.rtt
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := max(min(n, len(r.samples)), 0)
+	out := make([]RTTSample, count)
+	// Once the ring is full, next is the oldest sample, otherwise samples
+	// are in order
+	end := len(r.samples)
+	if end == RTTHistorySize {
+		end = r.next + RTTHistorySize
+	}
+	for i := range count {
+		out[i] = r.samples[(end-count+i)%len(r.samples)]
+	}
+	return out
+}
+
+// RTTSampleSpan returns the time covered by the RTT samples, from the oldest
+// one until now.
+func (p *PWStats) RTTSampleSpan(now int64) time.Duration {
+	samples := p.RecentRTTSamples(RTTHistorySize)
+	if len(samples) == 0 {
+		return 0
+	}
+	return time.Duration(now - samples[0].At)
+}
+
+// seqWindow is how far back (in sequence numbers) a late reply is still
+// treated as reordered; anything older is considered a counter wrap.
+const seqWindow = 1024
+
+// RecordSeq updates gap/reorder counters from a received ICMP sequence.
+// Only the highest sequence is kept, so memory use is constant per host.
+func (p *PWStats) RecordSeq(seq int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.SeqTracking {
+		p.SeqTracking = true
+		p.highest_seq = seq
+		return
+	}
+	diff := seq - p.highest_seq
+	switch {
+	case diff > 0 && diff < seqWindow:
+		p.SeqGaps += int64(diff - 1)
+		p.highest_seq = seq
+	case diff < 0 && diff > -seqWindow:
+		// Late reply filling an earlier gap
+		p.Reorders++
+		if p.SeqGaps > 0 {
+			p.SeqGaps--
+		}
+	case diff != 0:
+		// Sequence wrapped (65535 -> 0) or jumped too far to compare
+		p.highest_seq = seq
+	}
+}
+
+// RestartSeq forgets the sequence seen so far, for a new pinger numbering
+// its probes from 0 again
+func (p *PWStats) RestartSeq() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.SeqTracking = false
+}
+
+// Reset clears loss history and counters to start a new measurement window.
+// Current state and last reply are kept so no transition is triggered.
+func (p *PWStats) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now().UnixNano()
+	p.LastLossNano = 0
+	p.LastLossDuration = 0
+	p.LastUpTransition = 0
+	p.startup_time = now
+	p.last_compute = now
+	p.uptime_nano = 0
+	p.SeqGaps = 0
+	p.Reorders = 0
+	p.DupReplies = 0
+	p.OverMaxRTT = 0
+	p.SendRetries = 0
+	p.PacketsSent = 0
+	p.PacketsRecv = 0
+	p.miss_streak = 0
+	p.MaxMissStreak = 0
+	p.Transitions = 0
+	if r := p.rtt; r != nil {
+		r.mu.Lock()
+		r.samples, r.next = nil, 0
+		r.mu.Unlock()
+	}
+	p.loss_marks = nil
+	p.Jitter = 0
+	p.jitter_prev = 0
+}
+
+// settledSent returns the probes sent, without the latest one while it may
+// still be in flight.
+func (p *PWStats) settledSent() int64 {
+	sent := p.PacketsSent
+	if p.LastSent > p.LastRecv && sent > p.PacketsRecv {
+		sent--
+	}
+	return sent
+}
+
+// lossRatio returns the percentage of sent probes not received
+func lossRatio(sent, recv int64) float64 {
+	if sent <= 0 {
+		return 0
+	}
+	lost := sent - recv
+	if lost < 0 {
+		lost = 0
+	}
+	return float64(lost) * 100 / float64(sent)
+}
+
+// LossPercent returns the share of probes without a reply since start or
+// reset. The latest probe is left out while it may still be in flight.
+func (p *PWStats) LossPercent() float64 {
+	return lossRatio(p.settledSent(), p.PacketsRecv)
+}
+
+// WindowLossPercent returns the share of probes without a reply over the
+// last LossWindow, so past outages age out. Without a window, or before
+// the first mark, it is LossPercent.
+func (p *PWStats) WindowLossPercent() float64 {
+	if p.LossWindow <= 0 || len(p.loss_marks) == 0 {
+		return p.LossPercent()
+	}
+	oldest := p.loss_marks[0]
+	return lossRatio(p.settledSent()-oldest.sent, p.PacketsRecv-oldest.recv)
+}
+
+// MeetsExpectation reports whether the host is in the state declared with
+// expect=. A degraded host still replies, so it violates expect=down.
+func (p *PWStats) MeetsExpectation() bool {
+	switch p.Expect {
+	case "up":
+		return p.State && p.ErrorMessage == ""
+	case "down":
+		return !p.State && !p.Degraded
+	}
+	return true
+}
+
+// Alert reports a host violating its declared expectation.
+func (p *PWStats) Alert() bool {
+	return p.Expect != "" && !p.MeetsExpectation()
+}
+
+// Lossy reports an online host whose recent loss exceeds loss_threshold.
+func (p *PWStats) Lossy() bool {
+	return p.loss_threshold > 0 && p.State && p.ErrorMessage == "" && p.WindowLossPercent() > p.loss_threshold
+}
+
+// ComputeState updates the state from the time since the last reply and
+// passes a change to the transition writer. The sinks run after the stats
+// are unlocked, so a slow one doesn't hold up Snapshot or the recorders.
+func (p *PWStats) ComputeState(timeout_threshold int64) {
+	if rec := p.computeState(timeout_threshold); rec != nil {
+		p.transition_writer.WriteTransition(*rec)
+	}
+}
+
+// computeState does ComputeState under the lock, returning the transition
+// to write if there is one
+func (p *PWStats) computeState(timeout_threshold int64) *TransitionRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A paused host keeps the state it had, the missing replies are no outage
+	if p.paused_at != 0 {
+		return nil
+	}
+	now := time.Now().UnixNano()
+	if p.startup_time == 0 {
+		p.startup_time = now
+	}
+	if p.last_compute == 0 {
+		p.last_compute = now
+	}
+	p.markLoss(now)
+
+	prevState := p.State
+	prevSeen := p.state_initialized
+
+	// Set from the probe interval, timeout and misses, see offlineAfter
+	if p.offline_after > 0 {
+		timeout_threshold = p.offline_after
+	}
+	p.LastSeenNano = now - p.LastRecv
+	new_state := p.LastSeenNano < timeout_threshold
+	// An outage ends the jitter series, the RTT before it is no reference
+	if !new_state {
+		p.Jitter = 0
+		p.jitter_prev = 0
+	}
+	// A slow but replying host is degraded: offline for filtering and
+	// transitions, but reported distinctly from a silent host
+	p.Degraded = false
+	if new_state && p.OnlineMaxRTT > 0 && p.LastRTT >= p.OnlineMaxRTT {
+		new_state = false
+		p.Degraded = true
+	}
+	// TODO: Algo to review completely
+
+	if !prevSeen {
+		// First observation initializes baseline without marking transitions or highlights
+		p.state_initialized = true
+		p.skip_next_up_highlight = true
+		p.State = new_state
+		p.last_compute = now
+		return nil
+	}
+
+	// accumulate uptime only while state was online since last compute
+	if prevState {
+		p.uptime_nano += now - p.last_compute
+	}
+
+	if !prevState && new_state {
+		// Host came back online (down→up transition)
+		if p.skip_next_up_highlight {
+			// This is the first transition after startup - don't highlight it
+			p.skip_next_up_highlight = false
+		} else {
+			// Normal transition - highlight it blue for 20 seconds
+			p.LastUpTransition = now
+		}
+		// Always record the loss event (timestamp and duration)
+		p.LastLossNano = now
+		// Calculate outage duration: from last successful receive until now
+		p.LastLossDuration = now - p.LastRecv
+	}
+	// The first reply after startup is not an outage ending, and the
+	// monitored time for uptime starts there
+	startupUp := !prevState && new_state && p.uptime_nano == 0 && now-p.startup_time < timeout_threshold
+	if startupUp {
+		p.startup_time = now
+	}
+	if p.State != new_state && !startupUp {
+		p.Transitions++
+	}
+	var rec *TransitionRecord
+	if p.State != new_state && p.transition_writer != nil {
+		transition := "up to down"
+		if new_state {
+			transition = "down to up"
+		}
+		rec = &TransitionRecord{
+			Timestamp:  time.Unix(0, now).String(),
+			UnixNano:   now,
+			Host:       p.GetHostRepr(),
+			Ip:         p.IPRepr,
+			Transition: transition,
+			State:      new_state,
+			Target:     p.Target,
+		}
+	}
+
+	p.State = new_state
+	p.last_compute = now
+	return rec
+}
+
+func (p *PWStats) OnlineUptime(now int64) time.Duration {
+	total := p.uptime_nano
+	if p.State {
+		total += now - p.last_compute
+	}
+	if total < 0 {
+		total = 0
+	}
+	return time.Duration(total)
+}
+
+// UptimePercent returns the share of the time observed since startup the
+// host was online, false before the first observation
+func (p *PWStats) UptimePercent(now int64) (float64, bool) {
+	monitored := now - p.startup_time
+	if p.startup_time == 0 || monitored <= 0 {
+		return 0, false
+	}
+	return min(100, 100*float64(p.OnlineUptime(now))/float64(monitored)), true
+}
+
+// Snapshot returns a copy of the stats taken under their locks, for readers
+// on other goroutines than the probe's
+func (p *PWStats) Snapshot() PWStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hreprMu.RLock()
+	defer p.hreprMu.RUnlock()
+	return PWStats{pwStatsData: p.pwStatsData}
+}
+
+// PlaceholderStats returns offline stats naming host, for a host whose
+// stats weren't computed yet
+func PlaceholderStats(host string) PWStats {
+	return PWStats{pwStatsData: pwStatsData{hrepr: host, IPRepr: host}}
+}
+
+// GetHostRepr returns the host representation (display name) thread-safely.
+// An alias from the host file takes precedence over the resolved name.
+func (p *PWStats) GetHostRepr() string {
+	if p.Alias != "" {
+		return p.Alias
+	}
+	p.hreprMu.RLock()
+	defer p.hreprMu.RUnlock()
+	return p.hrepr
+}
+
+// DisplayName returns the host representation, followed by the target as
+// given when they differ and withTarget is set.
+func (p *PWStats) DisplayName(withTarget bool) string {
+	name := p.GetHostRepr()
+	if withTarget && p.Target != "" && name != p.Target {
+		return fmt.Sprintf("%s (%s)", name, p.Target)
+	}
+	return name
+}
+
+// SetHostRepr sets the host representation (display name) thread-safely
+func (p *PWStats) SetHostRepr(hrepr string) {
+	p.hreprMu.Lock()
+	defer p.hreprMu.Unlock()
+	p.hrepr = hrepr
+}
@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by all wrappers to cap the aggregate
+// probe rate. Probes wait for a token instead of being dropped, so a tight
+// budget paces sends rather than creating artificial loss. Wrappers that
+// can't wait before each send, ICMP's, stretch their interval instead so
+// that all hosts together stay within the budget, see Interval.
+type RateLimiter struct {
+	rate    float64 // tokens added per second
+	burst   float64 // bucket capacity
+	tokens  float64
+	last    time.Time
+	hosts   int           // hosts sharing the budget
+	changed chan struct{} // closed and replaced when Interval changes
+	mu      sync.Mutex
+}
+
+// NewRateLimiter creates a limiter allowing pps probes per second.
+// Returns nil when pps <= 0 (unlimited); a nil limiter never blocks.
+func NewRateLimiter(pps int) *RateLimiter {
+	if pps <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		rate:    float64(pps),
+		burst:   float64(pps),
+		tokens:  float64(pps),
+		last:    time.Now(),
+		changed: make(chan struct{}),
+	}
+}
+
+// SetHosts sets how many hosts share the budget
+func (l *RateLimiter) SetHosts(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n == l.hosts {
+		return
+	}
+	l.hosts = n
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Interval returns the time between two probes of a host configured to
+// probe every interval, stretched so the hosts probing together don't
+// exceed the budget.
+func (l *RateLimiter) Interval(interval time.Duration) time.Duration {
+	if l == nil {
+		return interval
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return max(interval, time.Duration(float64(l.hosts)/l.rate*float64(time.Second)))
+}
+
+// Changed returns a channel closed when Interval may return another value.
+// It never closes for a nil limiter.
+func (l *RateLimiter) Changed() <-chan struct{} {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.changed
+}
+
+// followPacing keeps the offline threshold of stats in step with the time
+// between probes of interval as the limiter paces them, until stop is
+// closed. Without it a tight budget spaces probes further apart than the
+// threshold and hosts go offline between two of them.
+func followPacing(stats *PWStats, l *RateLimiter, interval time.Duration, stop <-chan struct{}) {
+	for {
+		changed := l.Changed()
+		stats.SetPacedInterval(l.Interval(interval))
+		if l == nil {
+			return
+		}
+		select {
+		case <-changed:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Take consumes a token without waiting, for probes paced by Interval, so
+// the probes waiting for one share what is left of the budget. The bucket
+// may go into debt, down to one burst.
+func (l *RateLimiter) Take() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens = max(l.tokens-1, -l.burst)
+}
+
+// refill adds the tokens earned since the last call. Caller must hold l.mu.
+func (l *RateLimiter) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// Wait blocks until a token is available and consumes it, or until stop is
+// closed, reporting whether it got one. A nil stop never closes.
+func (l *RateLimiter) Wait(stop <-chan struct{}) bool {
+	if l == nil {
+		return true
+	}
+	for {
+		l.mu.Lock()
+		l.refill()
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return true
+		}
+		// Time until the next whole token becomes available
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		select {
+		case <-time.After(wait):
+		case <-stop:
+			return false
+		}
+	}
+}
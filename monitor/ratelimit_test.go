@@ -0,0 +1,145 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterInterval(t *testing.T) {
+	tests := []struct {
+		pps      int
+		hosts    int
+		interval time.Duration
+		want     time.Duration
+	}{
+		// Unlimited, nil limiter
+		{0, 1000, time.Second, time.Second},
+		// Within the budget, the interval is kept
+		{10, 0, time.Second, time.Second},
+		{10, 5, time.Second, time.Second},
+		{10, 10, time.Second, time.Second},
+		// Over it, stretched so the hosts together send pps
+		{10, 50, time.Second, 5 * time.Second},
+		{100, 50, 100 * time.Millisecond, 500 * time.Millisecond},
+		{4, 1, 100 * time.Millisecond, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		l := NewRateLimiter(tt.pps)
+		l.SetHosts(tt.hosts)
+		if got := l.Interval(tt.interval); got != tt.want {
+			t.Errorf("%d pps, %d hosts: Interval(%s) = %s, want %s", tt.pps, tt.hosts, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterSetHosts(t *testing.T) {
+	l := NewRateLimiter(10)
+	l.SetHosts(5)
+	changed := l.Changed()
+	l.SetHosts(5)
+	select {
+	case <-changed:
+		t.Fatal("Changed closed by SetHosts with the same count")
+	default:
+	}
+	l.SetHosts(50)
+	select {
+	case <-changed:
+	default:
+		t.Fatal("Changed not closed by SetHosts with another count")
+	}
+	if l.Changed() == changed {
+		t.Error("Changed not replaced after closing")
+	}
+
+	var nilLimiter *RateLimiter
+	nilLimiter.SetHosts(5)
+	if nilLimiter.Changed() != nil {
+		t.Error("nil limiter has a Changed channel")
+	}
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	l := NewRateLimiter(10)
+	for range 5 {
+		l.Take()
+	}
+	if l.tokens < 4.9 || l.tokens > 5.1 {
+		t.Errorf("tokens = %.2f after 5 of 10, want 5", l.tokens)
+	}
+	// Debt is capped at one burst
+	for range 100 {
+		l.Take()
+	}
+	if l.tokens != -l.burst {
+		t.Errorf("tokens = %.2f after 105 of 10, want %.0f", l.tokens, -l.burst)
+	}
+
+	var nilLimiter *RateLimiter
+	nilLimiter.Take()
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(10)
+	if !l.Wait(nil) {
+		t.Fatal("Wait with tokens left = false")
+	}
+
+	// In debt, the next token is seconds away: stop ends the wait
+	l.tokens = -l.burst
+	stop := make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(stop) })
+	start := time.Now()
+	if l.Wait(stop) {
+		t.Fatal("Wait in debt = true before the next token")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait returned %s after stop", elapsed)
+	}
+
+	var nilLimiter *RateLimiter
+	if !nilLimiter.Wait(stop) {
+		t.Error("nil limiter Wait = false")
+	}
+}
+
+func TestFollowPacing(t *testing.T) {
+	// 254 hosts at 50 pps probe each host about every 5s, well past the
+	// 2s the 1s interval, timeout and 2 misses allow
+	l := NewRateLimiter(50)
+	stats := &PWStats{pwStatsData: pwStatsData{probe_timeout: time.Second, misses: 2}}
+	stats.offline_after = offlineAfter(time.Second, time.Second, 2)
+	stop := make(chan struct{})
+	defer close(stop)
+	go followPacing(stats, l, time.Second, stop)
+
+	l.SetHosts(254)
+	want := offlineAfter(l.Interval(time.Second), time.Second, 2)
+	deadline := time.Now().Add(time.Second)
+	for stats.Snapshot().offline_after != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("offline_after = %s, want %s", time.Duration(stats.Snapshot().offline_after), time.Duration(want))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A host answering the probe before last is online between two probes
+	now := time.Now().UnixNano()
+	stats.LastRecv = now - int64(3*time.Second)
+	stats.State, stats.state_initialized = true, true
+	stats.ComputeState(int64(2 * time.Second))
+	if !stats.Snapshot().State {
+		t.Error("host offline between two paced probes")
+	}
+
+	// Fewer hosts, the interval is back within the budget
+	l.SetHosts(10)
+	want = offlineAfter(time.Second, time.Second, 2)
+	deadline = time.Now().Add(time.Second)
+	for stats.Snapshot().offline_after != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("offline_after = %s after SetHosts(10), want %s", time.Duration(stats.Snapshot().offline_after), time.Duration(want))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
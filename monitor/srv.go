@@ -0,0 +1,39 @@
+package monitor
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+const srvPrefix = "srv://"
+
+func IsSRVSpec(host string) bool {
+	return strings.HasPrefix(host, srvPrefix)
+}
+
+// ResolveSRV looks up an srv://_service._proto.name spec and returns one
+// tcp://target:port host per record, sorted for stable comparison.
+func ResolveSRV(spec string) ([]string, error) {
+	name := strings.TrimPrefix(spec, srvPrefix)
+	if name == "" {
+		return nil, fmt.Errorf("%v: empty SRV name", spec)
+	}
+
+	_, records, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]string, 0, len(records))
+	for _, r := range records {
+		target := strings.TrimSuffix(r.Target, ".")
+		if strings.Contains(target, ":") {
+			target = "[" + target + "]"
+		}
+		targets = append(targets, fmt.Sprintf("tcp://%s:%d", target, r.Port))
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Rough wire sizes of one probe's request, IPv4 headers included. Replies
+// roughly mirror them.
+const (
+	icmpHeaderBytes = 28  // IPv4 20 + ICMP 8, add 20 for IPv6
+	systemPingBytes = 84  // system's ping default: 56 bytes payload
+	tcpProbeBytes   = 100 // SYN with options and the closing RST
+	httpProbeBytes  = 600 // handshake, GET and teardown, without TLS
+)
+
+// probeLoad estimates the requests one wrapper sends: bytes per probe and
+// probes per second
+func probeLoad(w PingWrapperInterface) (bytes int, pps float64) {
+	interval := w.Stats().probe_interval
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	pps = float64(time.Second) / float64(interval)
+	switch w := w.(type) {
+	case *ProbingWrapper:
+		bytes = w.size + icmpHeaderBytes
+		if w.ip.IP.To4() == nil {
+			bytes += 20
+		}
+		return bytes, pps
+	case *SystemPingWrapper:
+		return systemPingBytes, pps
+	case *HTTPPingWrapper:
+		return httpProbeBytes, pps
+	default:
+		return tcpProbeBytes, pps
+	}
+}
+
+// writeTrafficEstimate prints the probe traffic the wrappers generate, in
+// packets per second and kbit/s, capped by the -max-pps limiter. It is
+// computed from the configuration only, nothing is measured.
+func writeTrafficEstimate(out io.Writer, wrappers []PingWrapperInterface, limiter *RateLimiter) {
+	var pps, bps float64
+	for _, w := range wrappers {
+		bytes, rate := probeLoad(w)
+		pps += rate
+		bps += float64(bytes) * 8 * rate
+	}
+	if limiter != nil && pps > limiter.rate {
+		bps *= limiter.rate / pps
+		pps = limiter.rate
+	}
+	fmt.Fprintf(out, "DEBUG: Probe traffic estimate for %d hosts: %.1f pps, %.1f kbps (requests, replies add about the same)\n", len(wrappers), pps, bps/1000)
+}
@@ -0,0 +1,212 @@
+package monitor
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TransitionWriter struct {
+	fh                 *os.File
+	writer             *bufio.Writer
+	lock               sync.Mutex
+	writer_initialized bool
+	csv                bool // -log-format csv: one row per transition instead of JSON lines
+	filename           string
+	max_size           int64            // rotate before the file grows past this many bytes (0 = never)
+	size               int64            // bytes in the current file
+	preamble           string           // -log-header record, repeated at the top of every rotated file
+	sinks              []TransitionSink // -webhook, -db, also notified without a log file
+}
+
+// TransitionSink receives every transition besides the log. Send must not
+// block, so a slow sink never holds up the stats computation; Close delivers
+// what is still queued and releases the sink.
+type TransitionSink interface {
+	Send(rec TransitionRecord)
+	Close()
+}
+
+// csvLogColumns is the header line of a -log-format csv transition log
+const csvLogColumns = "timestamp,unixnano,host,ip,transition,state\n"
+
+// TransitionRecord is one state change in the transition log
+type TransitionRecord struct {
+	Timestamp  string
+	UnixNano   int64
+	Host       string
+	Ip         string
+	Transition string // "down to up" or "up to down"
+	State      bool
+	Target     string `json:"-"` // host as given, unlike Host not changed by DNS updates
+}
+
+// LogHeader is an optional first record describing the run, so a transition
+// log can be interpreted later without knowing the command line used.
+type LogHeader struct {
+	Type      string
+	Version   string
+	Timestamp string
+	UnixNano  int64
+	Hosts     map[string]string // host as given -> resolved ip
+	Config    map[string]string
+}
+
+// csvComment formats the header as # lines for a CSV log, which has no
+// room for a record of another shape
+func (h *LogHeader) csvComment() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# mping %s started %s\n", h.Version, time.Unix(0, h.UnixNano).Format(time.RFC3339Nano))
+	for _, host := range slices.Sorted(maps.Keys(h.Hosts)) {
+		fmt.Fprintf(&sb, "# host %s %s\n", host, h.Hosts[host])
+	}
+	for _, key := range slices.Sorted(maps.Keys(h.Config)) {
+		fmt.Fprintf(&sb, "# config %s=%s\n", key, h.Config[key])
+	}
+	return sb.String()
+}
+
+// Init opens the log file for appending in the given format, json or csv,
+// and starts the periodic flush. With maxSize above 0 the log is rotated
+// before it grows past maxSize bytes. If header is not nil it is written as
+// the first record of this run in a JSON log, as # lines in a CSV log,
+// before the column names of a new one.
+func (w *TransitionWriter) Init(filename string, format string, maxSize int64, quitFlag *bool, header *LogHeader) {
+	w.filename = filename
+	w.max_size = maxSize
+	w.csv = format == "csv"
+	if header != nil && w.csv {
+		w.preamble = header.csvComment()
+	} else if header != nil {
+		jsonString, _ := json.Marshal(header)
+		w.preamble = string(jsonString) + "\n"
+	}
+	if err := w.open(w.preamble); err != nil {
+		log.Fatal(err)
+	}
+	w.writer_initialized = true
+	go func(w *TransitionWriter) {
+		for !*quitFlag {
+			w.lock.Lock()
+			if !w.writer_initialized {
+				w.lock.Unlock()
+				return
+			}
+			w.writer.Flush()
+			w.lock.Unlock()
+			time.Sleep(500 * time.Millisecond)
+		}
+	}(w)
+}
+
+// open opens the log for appending and writes preamble. A new or empty CSV
+// log continues with its column names.
+func (w *TransitionWriter) open(preamble string) error {
+	fh, err := os.OpenFile(w.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return err
+	}
+	w.fh = fh
+	w.writer = bufio.NewWriter(fh)
+	w.size = info.Size()
+	if w.csv && w.size == 0 {
+		preamble += csvLogColumns
+	}
+	n, _ := w.writer.WriteString(preamble)
+	w.size += int64(n)
+	return nil
+}
+
+// rotate renames the current log with a timestamp suffix and opens a new
+// one, starting with the -log-header record again. If the rename fails, it
+// is reported once and rotation is disabled for the rest of the run. Called
+// with the lock held.
+func (w *TransitionWriter) rotate() error {
+	w.writer.Flush()
+	w.fh.Close()
+	rotated := w.filename + "." + time.Now().Format("20060102-150405.000")
+	if err := os.Rename(w.filename, rotated); err != nil {
+		// Keep appending to the current file rather than losing lines
+		fmt.Fprintf(os.Stderr, "transition log rotation failed, no longer rotating: %v\n", err)
+		w.max_size = 0
+		return w.open("")
+	}
+	return w.open(w.preamble)
+}
+
+// WriteTransition logs a state change in the log's format. The line is
+// built first so concurrent writers never interleave.
+func (w *TransitionWriter) WriteTransition(rec TransitionRecord) {
+	for _, s := range w.sinks {
+		s.Send(rec)
+	}
+	var sb strings.Builder
+	if w.csv {
+		cw := csv.NewWriter(&sb)
+		cw.Write([]string{
+			time.Unix(0, rec.UnixNano).Format(time.RFC3339Nano),
+			strconv.FormatInt(rec.UnixNano, 10),
+			rec.Host,
+			rec.Ip,
+			rec.Transition,
+			strconv.FormatBool(rec.State),
+		})
+		cw.Flush()
+	} else {
+		jsonString, _ := json.Marshal(rec)
+		sb.Write(jsonString)
+		sb.WriteString("\n")
+	}
+	w.WriteString(sb.String())
+}
+
+// AddSink passes every transition to s, whether or not a log is written.
+// Must be called before probing starts.
+func (w *TransitionWriter) AddSink(s TransitionSink) {
+	w.sinks = append(w.sinks, s)
+}
+
+// WriteString appends raw text to the log, atomically with regard to other
+// writes, rotating the log first when st would take it past max_size.
+func (w *TransitionWriter) WriteString(st string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.writer_initialized {
+		return
+	}
+	if w.max_size > 0 && w.size > 0 && w.size+int64(len(st)) > w.max_size {
+		if err := w.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "transition log disabled: %v\n", err)
+			w.writer_initialized = false
+			return
+		}
+	}
+	n, _ := w.writer.WriteString(st)
+	w.size += int64(n)
+}
+
+// Close flushes and closes the log. It is safe to call more than once and
+// writes after it are dropped.
+func (w *TransitionWriter) Close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.writer_initialized {
+		w.writer.Flush()
+		w.fh.Close()
+		w.writer_initialized = false
+	}
+}
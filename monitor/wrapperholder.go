@@ -1,4 +1,4 @@
-package main
+package monitor
 
 import (
 	"fmt"
@@ -9,13 +9,13 @@ import (
 
 type WrapperHolder struct {
 	ping_wrappers     []PingWrapperInterface
-	options           Options
+	options           ProbeOptions
 	transition_writer *TransitionWriter
 	mu                sync.RWMutex
 	dnsUpdater        *DNSUpdater
 }
 
-func (w *WrapperHolder) InitHosts(hosts []string, options Options, transition_writer *TransitionWriter) {
+func (w *WrapperHolder) InitHosts(hosts []string, options ProbeOptions, transition_writer *TransitionWriter) {
 	w.options = options
 	w.transition_writer = transition_writer
 	w.dnsUpdater = NewDNSUpdater(w.Wrappers)
@@ -31,33 +31,34 @@ func (w *WrapperHolder) setHosts(hosts []string) {
 	}
 }
 
-func (w *WrapperHolder) ReplaceHosts(hosts []string) {
-	// Stop DNS updates while replacing hosts
-	w.dnsUpdater.Stop()
-
+func (w *WrapperHolder) ReplaceHosts(hosts []string) error {
 	w.mu.Lock()
-	old := w.ping_wrappers
-	w.ping_wrappers = make([]PingWrapperInterface, len(hosts))
-	for i, host := range hosts {
-		w.ping_wrappers[i] = NewPingWrapper(host, w.options, w.transition_writer)
+	wrappers, added, removed, err := reuseWrappers(w.ping_wrappers, hosts, w.options, w.transition_writer)
+	if err != nil {
+		w.mu.Unlock()
+		return err
 	}
-	newWrappers := w.ping_wrappers
+	w.ping_wrappers = wrappers
 	w.mu.Unlock()
 
-	for _, pw := range old {
+	// Stop DNS updates while replacing hosts
+	w.dnsUpdater.Stop()
+
+	for _, pw := range removed {
 		pw.Stop()
 	}
 
-	// Staggered start for new wrappers
-	for i, pw := range newWrappers {
+	// Staggered start for added wrappers
+	for i, pw := range added {
 		pw.Start()
-		if i >= 10 && i < len(newWrappers)-1 {
+		if i >= 10 && i < len(added)-1 {
 			time.Sleep(1 * time.Millisecond)
 		}
 	}
 
 	// Restart DNS updates for new hosts
 	w.dnsUpdater.Start()
+	return nil
 }
 
 func (w *WrapperHolder) Wrappers() []PingWrapperInterface {
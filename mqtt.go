@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mqttQueueSize  = 256              // transitions waiting to be published, more are dropped
+	mqttTimeout    = 5 * time.Second  // connect and per write
+	mqttKeepAlive  = 60 * time.Second // announced to the broker, pinged at half of it
+	mqttMinBackoff = time.Second      // first reconnect delay, doubling up to mqttMaxBackoff
+	mqttMaxBackoff = 30 * time.Second
+)
+
+// MQTT 3.1.1 control packet types, shifted into the fixed header
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttPingreq    = 0xc0
+	mqttDisconnect = 0xe0
+)
+
+// MQTTPublisher publishes the state of every host that had a transition,
+// "online" or "offline", as a retained message to <prefix>/<host>/status.
+// Only the publish side of MQTT 3.1.1 is spoken, at QoS 0. It runs in its
+// own goroutine and reconnects with backoff when the broker drops, sending
+// the latest state of every host again, so probing is never held up and the
+// retained states survive a broker restart.
+type MQTTPublisher struct {
+	broker   *url.URL
+	prefix   string
+	clientID string
+	queue    chan monitor.TransitionRecord
+	done     chan struct{}
+	dropped  int
+	mu       sync.Mutex // guards closing queue against Send, and dropped
+	closed   bool
+}
+
+// NewMQTTPublisher starts publishing to the broker, an mqtt:// (or tcp://)
+// or mqtts:// (or ssl://) URL with optional user info
+func NewMQTTPublisher(broker string, prefix string) (*MQTTPublisher, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	p := &MQTTPublisher{
+		broker:   u,
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		clientID: fmt.Sprintf("mping-%s-%d", hostname, os.Getpid()),
+		queue:    make(chan monitor.TransitionRecord, mqttQueueSize),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// Send queues a transition without blocking
+func (p *MQTTPublisher) Send(rec monitor.TransitionRecord) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- rec:
+	default:
+		p.dropped++
+	}
+}
+
+// Close publishes the queued transitions if connected, waiting at most one
+// write timeout, and disconnects. Transitions sent after Close are dropped.
+func (p *MQTTPublisher) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	dropped := p.dropped
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-time.After(mqttTimeout):
+	}
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "mqtt: %d transitions dropped, the broker didn't keep up\n", dropped)
+	}
+}
+
+// topic returns the status topic of a host. MQTT topic levels can't hold
+// the wildcards + and #, and a / would add levels, so those become _.
+func (p *MQTTPublisher) topic(rec monitor.TransitionRecord) string {
+	host := rec.Target
+	if host == "" {
+		host = rec.Host
+	}
+	host = strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(host)
+	if p.prefix == "" {
+		return host + "/status"
+	}
+	return p.prefix + "/" + host + "/status"
+}
+
+func (p *MQTTPublisher) run() {
+	defer close(p.done)
+	states := make(map[string]string) // topic -> latest payload
+	pending := make(map[string]bool)  // topics not published on the current connection
+	var conn net.Conn
+	var dead chan struct{} // closed when the broker closes conn
+	backoff := mqttMinBackoff
+	var retry <-chan time.Time
+	ping := time.NewTicker(mqttKeepAlive / 2)
+	defer ping.Stop()
+
+	disconnect := func(err error) {
+		if monitor.DebugMode {
+			fmt.Fprintf(os.Stderr, "DEBUG: mqtt connection to %s lost: %v\n", p.broker.Host, err)
+		}
+		conn.Close()
+		conn = nil
+		retry = time.After(backoff)
+	}
+	connect := func() {
+		c, err := p.dial()
+		if err != nil {
+			if monitor.DebugMode {
+				fmt.Fprintf(os.Stderr, "DEBUG: mqtt connection to %s failed, retrying in %s: %v\n", p.broker.Host, backoff, err)
+			}
+			retry = time.After(backoff)
+			backoff = min(backoff*2, mqttMaxBackoff)
+			return
+		}
+		conn, retry, backoff = c, nil, mqttMinBackoff
+		dead = make(chan struct{})
+		go func(c net.Conn, dead chan struct{}) {
+			// Nothing is subscribed, only PINGRESP arrives
+			io.Copy(io.Discard, c)
+			close(dead)
+		}(c, dead)
+		for topic := range states {
+			pending[topic] = true
+		}
+	}
+	publish := func() {
+		for topic := range pending {
+			if err := p.write(conn, mqttPublishPacket(topic, states[topic])); err != nil {
+				disconnect(err)
+				return
+			}
+			delete(pending, topic)
+		}
+	}
+
+	connect()
+	for {
+		if conn != nil {
+			publish()
+		}
+		select {
+		case rec, ok := <-p.queue:
+			if !ok {
+				if conn != nil {
+					publish()
+				}
+				// publish may have lost the connection
+				if conn != nil {
+					p.write(conn, []byte{mqttDisconnect, 0})
+					conn.Close()
+				}
+				return
+			}
+			payload := "offline"
+			if rec.State {
+				payload = "online"
+			}
+			topic := p.topic(rec)
+			states[topic] = payload
+			pending[topic] = true
+		case <-retry:
+			connect()
+		case <-ping.C:
+			if conn != nil {
+				if err := p.write(conn, []byte{mqttPingreq, 0}); err != nil {
+					disconnect(err)
+				}
+			}
+		case <-dead:
+			if conn != nil {
+				disconnect(io.EOF)
+			}
+			dead = nil
+		}
+	}
+}
+
+// dial connects and completes the MQTT handshake
+func (p *MQTTPublisher) dial() (net.Conn, error) {
+	secure := p.broker.Scheme == "mqtts" || p.broker.Scheme == "ssl"
+	addr := p.broker.Host
+	if p.broker.Port() == "" {
+		port := "1883"
+		if secure {
+			port = "8883"
+		}
+		addr = net.JoinHostPort(p.broker.Hostname(), port)
+	}
+	dialer := &net.Dialer{Timeout: mqttTimeout}
+	var conn net.Conn
+	var err error
+	if secure {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: p.broker.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.write(conn, mqttConnectPacket(p.clientID, p.broker.User)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ack := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(mqttTimeout))
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+	if ack[0] != mqttConnack || ack[1] != 2 {
+		conn.Close()
+		return nil, errors.New("unexpected reply to CONNECT")
+	}
+	if ack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused by the broker (return code %d)", ack[3])
+	}
+	return conn, nil
+}
+
+func (p *MQTTPublisher) write(conn net.Conn, packet []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(mqttTimeout))
+	_, err := conn.Write(packet)
+	return err
+}
+
+// mqttConnectPacket builds a CONNECT with a clean session and the user and
+// password of the broker URL, if any
+func mqttConnectPacket(clientID string, user *url.Userinfo) []byte {
+	var body bytes.Buffer
+	mqttWriteString(&body, "MQTT")
+	body.WriteByte(4) // protocol level 3.1.1
+	flags := byte(0x02)
+	if user != nil {
+		flags |= 0x80
+		if _, ok := user.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	body.WriteByte(flags)
+	keepAlive := uint16(mqttKeepAlive / time.Second)
+	body.Write([]byte{byte(keepAlive >> 8), byte(keepAlive)})
+	mqttWriteString(&body, clientID)
+	if user != nil {
+		mqttWriteString(&body, user.Username())
+		if password, ok := user.Password(); ok {
+			mqttWriteString(&body, password)
+		}
+	}
+	return mqttPacket(mqttConnect, body.Bytes())
+}
+
+// mqttPublishPacket builds a retained QoS 0 PUBLISH
+func mqttPublishPacket(topic, payload string) []byte {
+	var body bytes.Buffer
+	mqttWriteString(&body, topic)
+	body.WriteString(payload)
+	return mqttPacket(mqttPublish|0x01, body.Bytes())
+}
+
+// mqttPacket prepends the fixed header, the remaining length being encoded
+// 7 bits per byte
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func mqttWriteString(b *bytes.Buffer, s string) {
+	b.Write([]byte{byte(len(s) >> 8), byte(len(s))})
+	b.WriteString(s)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Nagios plugin states, also the exit status of -once-format nagios
+const (
+	NagiosOK       = 0
+	NagiosWarning  = 1
+	NagiosCritical = 2
+	NagiosUnknown  = 3 // the check itself failed, e.g. on invalid flags
+)
+
+var nagiosStateNames = map[int]string{NagiosOK: "OK", NagiosWarning: "WARNING", NagiosCritical: "CRITICAL", NagiosUnknown: "UNKNOWN"}
+
+// DownThreshold is a number of hosts down, or a share of all hosts with a
+// trailing % (-nagios-warn, -nagios-crit)
+type DownThreshold struct {
+	Value   float64
+	Percent bool
+}
+
+func (t *DownThreshold) String() string {
+	if t.Percent {
+		return strconv.FormatFloat(t.Value, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatFloat(t.Value, 'f', -1, 64)
+}
+
+func (t *DownThreshold) Set(v string) error {
+	num, percent := strings.CutSuffix(strings.TrimSpace(v), "%")
+	value, err := strconv.ParseFloat(num, 64)
+	if err != nil || value < 0 || (percent && value > 100) || (!percent && value != float64(int(value))) {
+		return fmt.Errorf("%q is neither a host count nor a percentage", v)
+	}
+	*t = DownThreshold{value, percent}
+	return nil
+}
+
+// Reached reports whether down of total hosts meet the threshold. A zero
+// threshold is never reached, so it disables its state.
+func (t DownThreshold) Reached(down, total int) bool {
+	if t.Value == 0 || down == 0 {
+		return false
+	}
+	if t.Percent {
+		return float64(down)*100 >= t.Value*float64(total)
+	}
+	return float64(down) >= t.Value
+}
+
+// NagiosState returns the plugin state of the summary for the thresholds
+func (s OnceSummary) NagiosState(warn, crit DownThreshold) int {
+	down := s.Total - s.Online
+	switch {
+	case crit.Reached(down, s.Total):
+		return NagiosCritical
+	case warn.Reached(down, s.Total):
+		return NagiosWarning
+	default:
+		return NagiosOK
+	}
+}
+
+// writeNagios writes the plugin output: the status line with performance
+// data, then the hosts down as long output, one per line
+func writeNagios(w io.Writer, s OnceSummary, warn, crit DownThreshold) {
+	down := s.Total - s.Online
+	fmt.Fprintf(w, "PING %s - %d up, %d down | up=%d down=%d\n",
+		nagiosStateNames[s.NagiosState(warn, crit)], s.Online, down, s.Online, down)
+	for _, host := range s.Down {
+		fmt.Fprintln(w, host)
+	}
+}
+
+// exitNagiosUnknown prints msg as an UNKNOWN status line and exits with that
+// state, so a check that couldn't run isn't taken for the hosts' state
+func exitNagiosUnknown(msg string) {
+	fmt.Printf("PING %s - %s\n", nagiosStateNames[NagiosUnknown], msg)
+	os.Exit(NagiosUnknown)
+}
+
+// nagiosArgs reports whether the command line asks for -once-format nagios,
+// for errors found while parsing it. The last -once-format given wins.
+func nagiosArgs(args []string) bool {
+	nagios := false
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "once-format" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		nagios = value == "nagios"
+	}
+	return nagios
+}
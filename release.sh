@@ -16,6 +16,8 @@ COMMIT_HASH=${COMMIT_HASH:-00000000}
 DIRTY=$(git diff --quiet 2>/dev/null || echo '-dirty')
 BUILD_TIMESTAMP=$(date -u '+%Y-%m-%dT%H:%M:%SZ')
 BUILDER=$(go version)
+# Release binaries include the -db transition database, TAGS= leaves it out
+TAGS=${TAGS-sqlite}
 HOST_GOOS=$(go env GOOS)
 HOST_GOARCH=$(go env GOARCH)
 
@@ -36,6 +38,7 @@ echo  "   Version=${VERSION}"
 echo  "   CommitHash=${COMMIT_HASH}${DIRTY}"
 echo  "   BuildTimestamp=${BUILD_TIMESTAMP}"
 echo  "   Builder=${BUILDER}"
+echo  "   Tags=${TAGS}"
 
 #echo "[*] go get"
 #go get .
@@ -51,7 +54,7 @@ for DIST in $TARGETS; do
   SUFFIX=""
   [ "$GOOS" = "windows" ] && SUFFIX=".exe"
   TARGET=${BINBASE}-${GOOS}-${GOARCH}
-  env CGO_ENABLED=0 GOOS=$GOOS GOARCH=$GOARCH go build -ldflags="${LDFLAGS[*]}" -mod vendor -o dist/${TARGET}${SUFFIX}
+  env CGO_ENABLED=0 GOOS=$GOOS GOARCH=$GOARCH go build -tags "${TAGS}" -ldflags="${LDFLAGS[*]}" -mod vendor -o dist/${TARGET}${SUFFIX}
   if [ "$GOOS" = "linux" ] && [ "$GOARCH" = "amd64" ]; then
     echo "[-]    - build .deb"
     PKG_STAGING="dist/deb-staging"
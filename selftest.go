@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+// RunSelfTest checks the ICMP modes and web address this install can use and
+// prints an environment summary. Returns the process exit code: non-zero if
+// 127.0.0.1 can't be pinged in any mode or the web address can't be bound.
+func RunSelfTest(out io.Writer, webAddr string) int {
+	fmt.Fprint(out, VersionString())
+	fmt.Fprintf(out, "OS: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS != "windows" {
+		fmt.Fprintf(out, "User: uid %d", os.Getuid())
+		if os.Getuid() == 0 {
+			fmt.Fprint(out, " (root, privileged ICMP is used by default)")
+		}
+		fmt.Fprintln(out)
+	}
+	if runtime.GOOS == "linux" {
+		if b, err := os.ReadFile("/proc/sys/net/ipv4/ping_group_range"); err == nil {
+			fmt.Fprintf(out, "net.ipv4.ping_group_range: %s\n", strings.TrimSpace(string(b)))
+		}
+	}
+	fmt.Fprintln(out)
+
+	failed := false
+	report := func(status, what string, err error) {
+		if err != nil {
+			fmt.Fprintf(out, "[%-4s] %s: %v\n", status, what, err)
+		} else {
+			fmt.Fprintf(out, "[%-4s] %s\n", status, what)
+		}
+	}
+
+	targets := []string{"127.0.0.1"}
+	if hasIPv6Loopback() {
+		targets = append(targets, "::1")
+	} else {
+		report("SKIP", "ICMP ::1", fmt.Errorf("no IPv6 loopback address"))
+	}
+	for _, target := range targets {
+		anyMode := false
+		for _, privileged := range []bool{true, false} {
+			mode := "unprivileged (UDP)"
+			if privileged {
+				mode = "privileged (raw)"
+			}
+			what := fmt.Sprintf("ICMP %s %s", target, mode)
+			if err := probeLoopback(target, privileged); err != nil {
+				report("FAIL", what, err)
+			} else {
+				report("OK", what, nil)
+				anyMode = true
+			}
+		}
+		// Only the IPv4 loopback is required, IPv6 may be disabled
+		if !anyMode && target == "127.0.0.1" {
+			failed = true
+		}
+	}
+
+	if webAddr != "" {
+		what := fmt.Sprintf("web address %s", webAddr)
+		if l, err := net.Listen("tcp", webAddr); err != nil {
+			report("FAIL", what, err)
+			failed = true
+		} else {
+			l.Close()
+			report("OK", what, nil)
+		}
+	}
+
+	fmt.Fprintln(out)
+	if failed {
+		fmt.Fprintln(out, "Self-test failed. Hints: run with -privileged as root or with cap_net_raw, allow unprivileged ping with sysctl net.ipv4.ping_group_range, use -s for the system's ping, or pick another -web-addr/-web-port.")
+		return 1
+	}
+	fmt.Fprintln(out, "Self-test passed")
+	return 0
+}
+
+// probeLoopback sends one echo request the way ProbingWrapper sets up its pinger
+func probeLoopback(target string, privileged bool) error {
+	pinger, err := probing.NewPinger(target)
+	if err != nil {
+		return err
+	}
+	pinger.Count = 1
+	pinger.Timeout = time.Second
+	pinger.SetPrivileged(privileged)
+	if runtime.GOOS == "linux" {
+		pinger.SetDoNotFragment(true)
+	}
+	if err := pinger.Run(); err != nil {
+		return err
+	}
+	if pinger.Statistics().PacketsRecv == 0 {
+		return fmt.Errorf("no reply")
+	}
+	return nil
+}
+
+func hasIPv6Loopback() bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(net.IPv6loopback) {
+			return true
+		}
+	}
+	return false
+}
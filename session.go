@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"os"
+	"path/filepath"
+)
+
+// Session is the host set saved with -session-file: every host as given to
+// the editor or command line, with the state attached to it in the TUI or
+// host file. Unlike a host file it round-trips hidden hosts.
+type Session struct {
+	Hosts []SessionHost `json:"hosts"`
+}
+
+// SessionHost is one host of a Session
+type SessionHost struct {
+	Host   string `json:"host"`             // as given: name, IP, tcp://, http(s)://, srv://
+	Expect string `json:"expect,omitempty"` // up or down, see expect= in host files
+	Alias  string `json:"alias,omitempty"`  // name displayed instead of the host, see host files
+	Group  string `json:"group,omitempty"`  // group filtered on in the TUI, see host files
+	Hidden bool   `json:"hidden,omitempty"` // hidden in the TUI list (DEL)
+}
+
+// NewSession captures the current host set. Per-host state is looked up on
+// the wrappers probing each spec; hidden is keyed by wrapper Host() like the
+// TUI's hidden set.
+func NewSession(specs []string, wrappers []monitor.PingWrapperInterface, hidden map[string]bool) *Session {
+	byTarget := make(map[string]monitor.PingWrapperInterface, len(wrappers))
+	for _, w := range wrappers {
+		byTarget[w.Stats().Target] = w
+	}
+	s := &Session{Hosts: make([]SessionHost, 0, len(specs))}
+	for _, spec := range specs {
+		h := SessionHost{Host: spec}
+		if w, ok := byTarget[spec]; ok {
+			h.Expect = w.Stats().Expect
+			h.Alias = w.Stats().Alias
+			h.Group = w.Stats().Group
+			h.Hidden = hidden[w.Host()]
+		}
+		s.Hosts = append(s.Hosts, h)
+	}
+	return s
+}
+
+// LoadSession reads a session file written by Save
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, h := range s.Hosts {
+		if h.Host == "" {
+			return nil, fmt.Errorf("%s: host entry without host", path)
+		}
+		if h.Expect != "" && h.Expect != "up" && h.Expect != "down" {
+			return nil, fmt.Errorf("%s: %s: expect must be up or down, got %q", path, h.Host, h.Expect)
+		}
+	}
+	return &s, nil
+}
+
+// Save writes the session to path
+func (s *Session) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// writeFileAtomic writes data to path through a temporary file so an
+// interrupted save doesn't leave a truncated file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mping-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Specs returns the session's hosts, their expectations, aliases and groups
+// and the hosts that are hidden
+func (s *Session) Specs() (hosts []string, expect, alias, group map[string]string, hidden map[string]bool) {
+	expect = make(map[string]string)
+	alias = make(map[string]string)
+	group = make(map[string]string)
+	hidden = make(map[string]bool)
+	for _, h := range s.Hosts {
+		hosts = append(hosts, h.Host)
+		if h.Expect != "" {
+			expect[h.Host] = h.Expect
+		}
+		if h.Alias != "" {
+			alias[h.Host] = h.Alias
+		}
+		if h.Group != "" {
+			group[h.Host] = h.Group
+		}
+		if h.Hidden {
+			hidden[h.Host] = true
+		}
+	}
+	return hosts, expect, alias, group, hidden
+}
+
+// hiddenWrapperKeys maps hidden host specs to the wrapper Host() keys the TUI
+// hides by
+func hiddenWrapperKeys(wrappers []monitor.PingWrapperInterface, hiddenSpecs map[string]bool) map[string]bool {
+	keys := make(map[string]bool)
+	for _, w := range wrappers {
+		if hiddenSpecs[w.Stats().Target] {
+			keys[w.Host()] = true
+		}
+	}
+	return keys
+}
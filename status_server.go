@@ -3,49 +3,171 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/babs/multiping/monitor"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
-// HostStatus represents the public status information for a host.
-type HostStatus struct {
+// hostStatusCamel is HostStatus with camelCase keys for -json-case camel.
+// It must keep HostStatus' fields so one converts to the other.
+type hostStatusCamel struct {
 	Host             string `json:"host"`
 	IP               string `json:"ip"`
 	Online           bool   `json:"online"`
+	State            string `json:"state"`
+	Lossy            bool   `json:"lossy"`
 	RTT              string `json:"rtt"`
-	LastReply        string `json:"last_reply"`
-	LastLossAgo      string `json:"last_loss_ago,omitempty"`
-	LastLossDuration string `json:"last_loss_duration,omitempty"`
+	LastReply        string `json:"lastReply"`
+	LastLossAgo      string `json:"lastLossAgo,omitempty"`
+	LastLossDuration string `json:"lastLossDuration,omitempty"`
 	Error            string `json:"error,omitempty"`
+	SeqGaps          int64  `json:"seqGaps"`
+	Reorders         int64  `json:"reorders"`
+	DupReplies       int64  `json:"dupReplies"`
+	MaxMisses        int64  `json:"maxConsecutiveMisses"`
+	Loss             string `json:"loss"`
+	Jitter           string `json:"jitter"`
+	Uptime           string `json:"uptime"`
+	Expected         string `json:"expected,omitempty"`
+	MeetsExpectation bool   `json:"meetsExpectation"`
+
+	LastRecvNano int64 `json:"-"`
+	LastLossNano int64 `json:"-"`
+}
+
+// HostDetail is the status of a single host served by /json/host, with its
+// counters and RTT history.
+type HostDetail struct {
+	monitor.HostStatus
+	OnlineUptime float64   `json:"online_uptime_seconds"`
+	PacketsSent  int64     `json:"packets_sent"`
+	PacketsRecv  int64     `json:"packets_recv"`
+	LossTotal    float64   `json:"loss_total_percent"` // since start or reset, Loss covers -loss-window
+	RTTMin       float64   `json:"rtt_min_ms"`
+	RTTAvg       float64   `json:"rtt_avg_ms"`
+	RTTMax       float64   `json:"rtt_max_ms"`
+	RTTStddev    float64   `json:"rtt_stddev_ms"`
+	RTTHistory   []float64 `json:"rtt_history_ms"` // latest replies, oldest first, as in the detail view's sparkline
+}
+
+// hostDetailCamel is HostDetail with camelCase keys for -json-case camel
+type hostDetailCamel struct {
+	hostStatusCamel
+	OnlineUptime float64   `json:"onlineUptimeSeconds"`
+	PacketsSent  int64     `json:"packetsSent"`
+	PacketsRecv  int64     `json:"packetsRecv"`
+	LossTotal    float64   `json:"lossTotalPercent"`
+	RTTMin       float64   `json:"rttMinMs"`
+	RTTAvg       float64   `json:"rttAvgMs"`
+	RTTMax       float64   `json:"rttMaxMs"`
+	RTTStddev    float64   `json:"rttStddevMs"`
+	RTTHistory   []float64 `json:"rttHistoryMs"`
+}
+
+// RTTHistorySample is one reply in the RTT history served by /json/history
+type RTTHistorySample struct {
+	Timestamp string  `json:"timestamp"` // RFC 3339 with nanoseconds
+	UnixNano  int64   `json:"unixnano"`
+	RTT       float64 `json:"rtt_ms"`
+}
+
+// rttHistorySampleCamel is RTTHistorySample with camelCase keys for -json-case camel
+type rttHistorySampleCamel struct {
+	Timestamp string  `json:"timestamp"`
+	UnixNano  int64   `json:"unixnano"`
+	RTT       float64 `json:"rttMs"`
+}
+
+// camelStatuses converts statuses for -json-case camel
+func camelStatuses(statuses []monitor.HostStatus) []hostStatusCamel {
+	out := make([]hostStatusCamel, len(statuses))
+	for i, st := range statuses {
+		out[i] = hostStatusCamel(st)
+	}
+	return out
 }
 
 type ServerView struct {
-	Filter FilterMode
-	Sort   SortMode
-	Hidden map[string]bool
-	Cols   []int
+	Filter  FilterMode
+	Sort    SortMode
+	Reverse bool // sort order reversed, see reverseSorted
+	Hidden  map[string]bool
+	Cols    []int  // visible columns in display order
+	AbsTime bool   // text view shows wall-clock timestamps instead of "ago"
+	Search  string // lowercase substring hosts must contain, set per request by ?host=
 }
 
-type StatsProvider func(PingWrapperInterface) PWStats
+type StatsProvider func(monitor.PingWrapperInterface) monitor.PWStats
 
 type StatusServer struct {
-	repo          HostRepository
+	repo          monitor.HostRepository
 	srv           *http.Server
 	statsProvider StatsProvider
 	view          ServerView
 	viewMu        sync.RWMutex
+	token         string           // required by mutating endpoints; empty disables them
+	onRate        func(UpdateRate) // applies a rate set with POST /config/rate, guarded by viewMu
+	jsonCamel     bool             // /json keys in camelCase instead of snake_case
+	user          string           // basic auth required on every endpoint; empty disables it
+	password      string
+	rttBands      RTTBands
+	done          chan struct{} // closed by Stop to end /events streams
+}
+
+// StatusServerOptions configures StartStatusServer
+type StatusServerOptions struct {
+	Addr      string // host:port to listen on, see webListenAddr (empty = disabled)
+	Token     string // required by mutating endpoints; empty disables them
+	JSONCamel bool   // /json keys in camelCase instead of snake_case
+	User      string // basic auth user and password for every endpoint (empty = none)
+	Password  string
+	CertFile  string // PEM certificate and key to serve HTTPS (empty = plain HTTP)
+	KeyFile   string
+	RTTBands  RTTBands // RTT coloring of /live, like the TUI's
 }
 
-func StartStatusServer(repo HostRepository, provider StatsProvider, initialView ServerView, port int) (*StatusServer, error) {
-	if port <= 0 {
+// webListenAddr returns the host:port the status server listens on, from
+// -web-addr as a bare host or host:port and -web-port. An empty address
+// means the server is disabled.
+func webListenAddr(addr string, port int) (string, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// Bare host, IPv6 literals with or without brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		portStr = strconv.Itoa(port)
+	}
+	if host == "" {
+		return "", fmt.Errorf("-web-addr %q has no host, use 0.0.0.0 to listen on all interfaces", addr)
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return "", fmt.Errorf("-web-addr %q is neither a host nor host:port", addr)
+	}
+	p, err := strconv.Atoi(portStr)
+	if err != nil || p < 0 || p > 65535 {
+		return "", fmt.Errorf("-web-addr %q: invalid port %q", addr, portStr)
+	}
+	if p == 0 {
+		return "", nil
+	}
+	return net.JoinHostPort(host, portStr), nil
+}
+
+// StartStatusServer serves the status page on opts.Addr. It does nothing
+// when the address is empty.
+func StartStatusServer(repo monitor.HostRepository, provider StatsProvider, initialView ServerView, opts StatusServerOptions) (*StatusServer, error) {
+	if opts.Addr == "" {
 		return nil, nil
 	}
 
@@ -53,39 +175,78 @@ func StartStatusServer(repo HostRepository, provider StatsProvider, initialView
 		repo:          repo,
 		statsProvider: provider,
 		view:          initialView,
+		token:         opts.Token,
+		jsonCamel:     opts.JSONCamel,
+		user:          opts.User,
+		password:      opts.Password,
+		rttBands:      opts.RTTBands,
+		done:          make(chan struct{}),
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", server.textHandler)
 	mux.HandleFunc("/json", server.jsonHandler)
+	mux.HandleFunc("/json/host", server.hostHandler)
+	mux.HandleFunc("/json/history", server.historyHandler)
 	mux.HandleFunc("/live", server.htmlHandler)
+	mux.HandleFunc("/metrics", server.metricsHandler)
+	mux.HandleFunc("/events", server.eventsHandler)
+	mux.HandleFunc("/reset", server.resetHandler)
+	mux.HandleFunc("/config/rate", server.rateHandler)
+
+	var handler http.Handler = mux
+	if server.user != "" {
+		handler = server.basicAuth(mux)
+	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", port))
+	// Loaded before listening so a bad certificate fails at startup, not
+	// on the first request
+	var tlsConfig *tls.Config
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading -web-cert/-web-key: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	listener, err := net.Listen("tcp", opts.Addr)
 	if err != nil {
 		return nil, err
 	}
 
 	server.srv = &http.Server{
 		Addr:              listener.Addr().String(),
-		Handler:           mux,
+		Handler:           handler,
 		ReadHeaderTimeout: 2 * time.Second,
 		// Very aggressive timeouts to prevent goroutine leaks
-		IdleTimeout:       5 * time.Second,
-		ReadTimeout:       3 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		MaxHeaderBytes:    1 << 20, // 1 MB
+		IdleTimeout:    5 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20, // 1 MB
+		TLSConfig:      tlsConfig,
 	}
 	// Disable keep-alives completely to prevent lingering connReader goroutines
 	server.srv.SetKeepAlivesEnabled(false)
 
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
 	go func() {
-		err := server.srv.Serve(listener)
+		var err error
+		if tlsConfig != nil {
+			// Certificate already in TLSConfig
+			err = server.srv.ServeTLS(listener, "", "")
+		} else {
+			err = server.srv.Serve(listener)
+		}
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			fmt.Fprintf(os.Stderr, "status server error: %v\n", err)
 		}
 	}()
 
-	fmt.Fprintf(os.Stderr, "Status server listening on http://%s (/: text, /json: JSON)\n", server.srv.Addr)
+	fmt.Fprintf(os.Stderr, "Status server listening on %s://%s (/: text, /json: JSON)\n", scheme, server.srv.Addr)
 
 	return server, nil
 }
@@ -94,29 +255,426 @@ func (s *StatusServer) Stop() {
 	if s == nil || s.srv == nil {
 		return
 	}
+	// Shutdown doesn't wait for streams to end on their own
+	close(s.done)
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 	_ = s.srv.Shutdown(ctx)
 }
 
-func (s *StatusServer) jsonHandler(w http.ResponseWriter, _ *http.Request) {
-	statuses := s.collectStatuses()
+// Values of the filter= and sort= query parameters, reverse= takes a boolean
+var (
+	filterParams = map[string]FilterMode{"all": FilterAll, "smart": FilterSmart, "online": FilterOnline, "offline": FilterOffline}
+	sortParams   = map[string]SortMode{"name": SortByName, "status": SortByStatus, "rtt": SortByRTT, "last-seen": SortByLastSeen, "ip": SortByIP, "uptime": SortByUptime}
+)
+
+// requestView returns the current view with the request's ?filter=, ?sort=,
+// ?reverse= and ?host= applied. Parameters not given, and filter or sort
+// values it doesn't know, keep the TUI's setting. Only a reverse= that isn't
+// a boolean is an error.
+func (s *StatusServer) requestView(r *http.Request) (ServerView, error) {
+	view := s.snapshotView()
+	q := r.URL.Query()
+	if mode, ok := filterParams[q.Get("filter")]; ok {
+		view.Filter = mode
+	}
+	if mode, ok := sortParams[q.Get("sort")]; ok {
+		view.Sort = mode
+	}
+	if v := q.Get("reverse"); v != "" {
+		reverse, err := strconv.ParseBool(v)
+		if err != nil {
+			return view, fmt.Errorf("invalid reverse %q, accepted: true, false", v)
+		}
+		view.Reverse = reverse
+	}
+	view.Search = strings.ToLower(q.Get("host"))
+	return view, nil
+}
+
+// camelKeys reports whether JSON keys are camelCase for the request: as set
+// with -json-case unless it asks for ?case=snake or ?case=camel.
+func (s *StatusServer) camelKeys(r *http.Request) bool {
+	switch r.URL.Query().Get("case") {
+	case "snake":
+		return false
+	case "camel":
+		return true
+	}
+	return s.jsonCamel
+}
+
+// statusBody returns the statuses as served by /json and /events, see
+// requestView and camelKeys.
+func (s *StatusServer) statusBody(r *http.Request) (any, error) {
+	view, err := s.requestView(r)
+	if err != nil {
+		return nil, err
+	}
+	statuses := s.collectStatuses(view)
+	if s.camelKeys(r) {
+		return camelStatuses(statuses), nil
+	}
+	return statuses, nil
+}
+
+// newHostDetail builds the detail of a wrapper from its computed stats
+func newHostDetail(wrapper monitor.PingWrapperInterface, stats *monitor.PWStats, now time.Time) HostDetail {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	minRTT, avg, maxRTT, stddev := stats.RTTStats()
+	history := make([]float64, 0, sparklineSamples)
+	for _, rtt := range stats.RecentRTTs(sparklineSamples) {
+		history = append(history, ms(rtt))
+	}
+	return HostDetail{
+		HostStatus:   monitor.NewHostStatus(wrapper, stats, now),
+		OnlineUptime: stats.OnlineUptime(now.UnixNano()).Seconds(),
+		PacketsSent:  stats.PacketsSent,
+		PacketsRecv:  stats.PacketsRecv,
+		LossTotal:    stats.LossPercent(),
+		RTTMin:       ms(minRTT),
+		RTTAvg:       ms(avg),
+		RTTMax:       ms(maxRTT),
+		RTTStddev:    ms(stddev),
+		RTTHistory:   history,
+	}
+}
+
+// hostHandler serves the detail of the host given by ?name=, matched against
+// the host as given, its display name, its IP or the TUI's host key. Hidden
+// hosts and the view's filter don't apply.
+func (s *StatusServer) hostHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing ?name=<host>", http.StatusBadRequest)
+		return
+	}
+	wrapper, stats, ok := s.findHost(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("host %q not found", name), http.StatusNotFound)
+		return
+	}
+	var body any = newHostDetail(wrapper, stats, time.Now())
+	if s.camelKeys(r) {
+		d := body.(HostDetail)
+		body = hostDetailCamel{hostStatusCamel(d.HostStatus), d.OnlineUptime, d.PacketsSent, d.PacketsRecv, d.LossTotal,
+			d.RTTMin, d.RTTAvg, d.RTTMax, d.RTTStddev, d.RTTHistory}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Connection", "close")
-	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		http.Error(w, "failed to encode status", http.StatusInternalServerError)
 	}
 }
 
+// findHost returns the wrapper and stats of the host matching name like
+// hostHandler does
+func (s *StatusServer) findHost(name string) (monitor.PingWrapperInterface, *monitor.PWStats, bool) {
+	for _, wrapper := range s.repo.GetAll() {
+		stats := s.statsProvider(wrapper)
+		if name == stats.Target || name == stats.GetHostRepr() || name == stats.IPRepr || name == wrapper.Host() {
+			return wrapper, &stats, true
+		}
+	}
+	return nil, nil, false
+}
+
+// historyHandler serves the latest ?n= replies (default and at most
+// RTTHistorySize) of the host given by ?host=, matched like /json/host,
+// oldest first.
+func (s *StatusServer) historyHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	name := q.Get("host")
+	if name == "" {
+		http.Error(w, "missing ?host=<host>", http.StatusBadRequest)
+		return
+	}
+	n := monitor.RTTHistorySize
+	if v := q.Get("n"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid n %q, expected a positive number", v), http.StatusBadRequest)
+			return
+		}
+	}
+	_, stats, ok := s.findHost(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("host %q not found", name), http.StatusNotFound)
+		return
+	}
+	samples := stats.RecentRTTSamples(n)
+	history := make([]RTTHistorySample, len(samples))
+	for i, sample := range samples {
+		history[i] = RTTHistorySample{
+			Timestamp: time.Unix(0, sample.At).Format(time.RFC3339Nano),
+			UnixNano:  sample.At,
+			RTT:       float64(sample.RTT) / float64(time.Millisecond),
+		}
+	}
+	var body any = history
+	if s.camelKeys(r) {
+		camel := make([]rttHistorySampleCamel, len(history))
+		for i, h := range history {
+			camel[i] = rttHistorySampleCamel(h)
+		}
+		body = camel
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "close")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, "failed to encode history", http.StatusInternalServerError)
+	}
+}
+
+// jsonHandler serves all statuses.
+func (s *StatusServer) jsonHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := s.statusBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "close")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, "failed to encode status", http.StatusInternalServerError)
+	}
+}
+
+const (
+	eventsTick      = 250 * time.Millisecond // how often /events checks for changes
+	eventsKeepalive = 15 * time.Second       // comment sent when nothing changed, so proxies keep the stream
+)
+
+// eventsHandler streams the statuses of /json as Server-Sent Events, one
+// event whenever they change.
+func (s *StatusServer) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.requestView(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc := http.NewResponseController(w)
+	// The server's WriteTimeout would cut the stream
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+
+	ticker := time.NewTicker(eventsTick)
+	defer ticker.Stop()
+	var last []byte
+	lastWrite := time.Now()
+	for {
+		body, err := s.statusBody(r)
+		if err != nil {
+			return
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return
+		}
+		var msg string
+		if !bytes.Equal(data, last) {
+			msg = "data: " + string(data) + "\n\n"
+			last = data
+		} else if time.Since(lastWrite) >= eventsKeepalive {
+			msg = ": keepalive\n\n"
+		}
+		if msg != "" {
+			if _, err := io.WriteString(w, msg); err != nil || rc.Flush() != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// metricsHandler serves per-host gauges in the Prometheus text format. Every
+// host is listed, whatever the TUI's filter or hidden hosts.
+func (s *StatusServer) metricsHandler(w http.ResponseWriter, _ *http.Request) {
+	type hostMetrics struct {
+		labels   string
+		up       int
+		rtt      float64 // seconds, -1 without reply
+		lastLoss float64 // Unix seconds, 0 without loss
+	}
+	wrappers := s.repo.GetAll()
+	hosts := make([]hostMetrics, 0, len(wrappers))
+	for _, wrapper := range wrappers {
+		stats := s.statsProvider(wrapper)
+		host := stats.GetHostRepr()
+		if host == "" {
+			host = wrapper.Host()
+		}
+		m := hostMetrics{
+			labels:   fmt.Sprintf(`host="%s",ip="%s"`, promLabelValue(host), promLabelValue(stats.IPRepr)),
+			rtt:      -1,
+			lastLoss: float64(stats.LastLossNano) / 1e9,
+		}
+		if stats.State && stats.ErrorMessage == "" {
+			m.up = 1
+		}
+		if stats.LastRecv > 0 {
+			m.rtt = stats.LastRTT.Seconds()
+		}
+		hosts = append(hosts, m)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].labels < hosts[j].labels })
+
+	var b strings.Builder
+	b.WriteString("# HELP mping_host_up Whether the host replies (1) or not (0).\n# TYPE mping_host_up gauge\n")
+	for _, h := range hosts {
+		fmt.Fprintf(&b, "mping_host_up{%s} %d\n", h.labels, h.up)
+	}
+	b.WriteString("# HELP mping_host_rtt_seconds Round-trip time of the last reply.\n# TYPE mping_host_rtt_seconds gauge\n")
+	for _, h := range hosts {
+		if h.rtt >= 0 {
+			fmt.Fprintf(&b, "mping_host_rtt_seconds{%s} %g\n", h.labels, h.rtt)
+		}
+	}
+	b.WriteString("# HELP mping_host_last_loss_timestamp_seconds Unix time the host last came back after an outage.\n# TYPE mping_host_last_loss_timestamp_seconds gauge\n")
+	for _, h := range hosts {
+		if h.lastLoss > 0 {
+			fmt.Fprintf(&b, "mping_host_last_loss_timestamp_seconds{%s} %.3f\n", h.labels, h.lastLoss)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "close")
+	io.WriteString(w, b.String())
+}
+
+// promLabelValue escapes a Prometheus label value: backslash, double quote
+// and line feed
+func promLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// basicAuth requires the -web-user/-web-pass credentials on every request
+// before passing it to next. A request carrying the -web-token instead
+// passes too, as its Authorization header has no room for both.
+func (s *StatusServer) basicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.validToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, password, ok := r.BasicAuth()
+		// Both compared so the time taken tells nothing about which was wrong
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="multiping", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized checks the -web-token of a mutating request, given as
+// "Authorization: Bearer <token>" only, never in the URL where access logs
+// and browser history would keep it. Writes the error response.
+func (s *StatusServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.token == "" {
+		http.Error(w, "disabled, start with -web-token to enable", http.StatusForbidden)
+		return false
+	}
+	if !s.validToken(r) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// validToken reports whether r carries the -web-token as bearer token
+func (s *StatusServer) validToken(r *http.Request) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) == 1
+}
+
+// resetHandler clears the stats of every host, keeping them running.
+func (s *StatusServer) resetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+	wrappers := s.repo.GetAll()
+	for _, wrapper := range wrappers {
+		wrapper.ResetStats()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Connection", "close")
+	json.NewEncoder(w).Encode(struct {
+		Reset int `json:"reset"`
+	}{len(wrappers)})
+}
+
+// SetRateHandler sets the function applying a rate from POST /config/rate.
+func (s *StatusServer) SetRateHandler(fn func(UpdateRate)) {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+	s.onRate = fn
+}
+
+// rateHandler changes the stats refresh rate to one of the rates of the
+// TUI's rate key, given as rate=<duration>.
+func (s *StatusServer) rateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+	d, err := time.ParseDuration(r.FormValue("rate"))
+	rate, ok := updateRateFor(d)
+	if err != nil || !ok {
+		http.Error(w, "rate must be one of 100ms, 1s, 5s, 30s", http.StatusBadRequest)
+		return
+	}
+	s.viewMu.RLock()
+	onRate := s.onRate
+	s.viewMu.RUnlock()
+	if onRate == nil {
+		http.Error(w, "not available yet", http.StatusServiceUnavailable)
+		return
+	}
+	onRate(rate)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Connection", "close")
+	json.NewEncoder(w).Encode(struct {
+		Rate string `json:"rate"`
+	}{updateRateDuration(rate).String()})
+}
+
 func (s *StatusServer) textHandler(w http.ResponseWriter, _ *http.Request) {
-	statuses := s.collectStatuses()
+	statuses := s.collectStatuses(s.snapshotView())
 	cols := s.columnsFromView()
+	abs := s.snapshotView().AbsTime
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Connection", "close")
 	for _, st := range statuses {
-		fmt.Fprintln(w, s.renderColumns(st, cols))
+		fmt.Fprintln(w, s.renderColumns(st, cols, abs))
 	}
 }
 
@@ -203,6 +761,11 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
       top: 0;
       z-index: 10;
       border-bottom: 1px solid rgba(240, 246, 252, 0.1);
+      cursor: pointer;
+      user-select: none;
+    }
+    th:hover, th.sorted {
+      color: var(--text-primary);
     }
     tbody tr {
       border-bottom: 1px solid rgba(240, 246, 252, 0.05);
@@ -237,6 +800,18 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
       background: rgba(63, 185, 80, 0.15);
       color: var(--green);
     }
+    .status-badge.lossy {
+      background: rgba(251, 146, 60, 0.15);
+      color: #FB923C;
+    }
+    .status-badge.degraded {
+      background: rgba(226, 185, 61, 0.15);
+      color: var(--yellow);
+    }
+    .status-badge.alert {
+      background: #B91C1C;
+      color: #FFFFFF;
+    }
     .status-badge.offline {
       background: rgba(248, 81, 73, 0.15);
       color: var(--red);
@@ -276,6 +851,11 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
     .rtt-bar .bar-empty {
       background: rgba(139, 148, 158, 0.2);
     }
+    .rtt-value.good { color: var(--green); }
+    .rtt-value.warn { color: var(--yellow); }
+    .rtt-value.crit { color: var(--red); }
+    .rtt-bar.warn .bar-filled, .rtt-bar.warn .bar-partial { background: var(--yellow); }
+    .rtt-bar.crit .bar-filled, .rtt-bar.crit .bar-partial { background: var(--red); }
     @keyframes pulse {
       0%%, 100%% { opacity: 1; }
       50%% { opacity: 0.6; }
@@ -352,7 +932,7 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
 <body>
   <header>
     <h1>🌐 MultiPingTUI Live Status</h1>
-    <p class="muted">Auto-refreshes every second · <code>/json</code> for JSON · <code>/</code> for text</p>
+    <p class="muted">Live updates as hosts change (polled every second if the stream is unavailable) · click a column to sort · <code>/json</code> for JSON · <code>/</code> for text</p>
   </header>
 
   <div class="container">
@@ -371,31 +951,113 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
 
   <script>
     const columns = %s;
-    const columnNames = {1:'Status', 2:'Name', 3:'IP Address', 4:'RTT', 5:'Last Reply', 6:'Last Loss'};
+    // -rtt-warn and -rtt-crit in ms, 0 disables a band
+    const rttWarn = %d, rttCrit = %d;
+    const columnNames = {1:'Status', 2:'Name', 3:'IP Address', 4:'RTT', 5:'Last Reply', 6:'Last Loss', 7:'Loss', 8:'Jitter', 9:'Uptime'};
     const tbody = document.querySelector('#status tbody');
-    document.querySelector('#status thead tr').innerHTML = columns.map(c => '<th>' + columnNames[c] + '</th>').join('');
+    const headRow = document.querySelector('#status thead tr');
     const updatedEl = document.querySelector('#updated span:last-child');
     const REFRESH_MS = 1000;
 
+    // Client-side sort; null keeps the server (TUI) order
+    let sortCol = null;
+    let sortAsc = true;
+    let lastData = [];
+
+    function renderHeader() {
+      headRow.innerHTML = '';
+      columns.forEach((c) => {
+        const th = document.createElement('th');
+        th.textContent = columnNames[c] + (sortCol === c ? (sortAsc ? ' ▲' : ' ▼') : '');
+        if (sortCol === c) th.className = 'sorted';
+        th.addEventListener('click', () => {
+          if (sortCol === c) {
+            sortAsc = !sortAsc;
+          } else {
+            sortCol = c;
+            sortAsc = true;
+          }
+          renderHeader();
+          renderRows(lastData);
+        });
+        headRow.appendChild(th);
+      });
+    }
+
+    // parseAgo converts Go duration strings like "1m3s ago" to seconds
+    function parseAgo(str) {
+      if (!str || str === 'never' || str === '-') return Infinity;
+      let total = 0;
+      const re = /([\d.]+)(h|ms|µs|ns|m|s)/g;
+      let m;
+      while ((m = re.exec(str)) !== null) {
+        const v = parseFloat(m[1]);
+        total += {h: 3600, m: 60, s: 1, ms: 1e-3, 'µs': 1e-6, ns: 1e-9}[m[2]] * v;
+      }
+      return total;
+    }
+
+    function ipSortKey(ip) {
+      if (!ip) return '~';
+      const v4 = ip.split('.');
+      if (v4.length === 4) return v4.map(o => o.padStart(3, '0')).join('.');
+      return '~' + ip;
+    }
+
+    function sortKey(row, col) {
+      switch (col) {
+        case 1: return row.online ? 0 : (row.state === 'degraded' ? 1 : 2);
+        case 2: return (row.host || '').toLowerCase();
+        case 3: return ipSortKey(row.ip);
+        case 4: { const v = row.online ? parseRTT(row.rtt) : null; return v === null ? Infinity : v; }
+        case 5: return parseAgo(row.last_reply);
+        case 6: return row.last_loss_ago ? parseAgo(row.last_loss_ago) : Infinity;
+        case 7: return row.loss && row.loss !== '-' ? parseFloat(row.loss) : Infinity;
+        case 8: { const v = parseRTT(row.jitter); return v === null ? Infinity : v; }
+        case 9: return row.uptime && row.uptime !== '-' ? parseFloat(row.uptime) : Infinity;
+        default: return 0;
+      }
+    }
+
+    function sortRows(data) {
+      if (sortCol === null) return data;
+      const dir = sortAsc ? 1 : -1;
+      return data.slice().sort((a, b) => {
+        const ka = sortKey(a, sortCol);
+        const kb = sortKey(b, sortCol);
+        if (ka < kb) return -dir;
+        if (ka > kb) return dir;
+        return 0;
+      });
+    }
+
     function parseRTT(rttStr) {
       if (!rttStr || rttStr === '-') return null;
-      const match = rttStr.match(/^([\d.]+)(ms|µs|s)$/);
+      const match = rttStr.match(/^([\d.]+)(ms|µs|ns|s)$/);
       if (!match) return null;
       let value = parseFloat(match[1]);
       const unit = match[2];
       if (unit === 's') value *= 1000;
       if (unit === 'µs') value /= 1000;
+      if (unit === 'ns') value /= 1e6;
       return value;
     }
 
+    function rttBand(rttMs) {
+      if (rttCrit > 0 && rttMs >= rttCrit) return 'crit';
+      if (rttWarn > 0 && rttMs >= rttWarn) return 'warn';
+      return 'good';
+    }
+
     function createRTTBar(rttMs) {
       if (rttMs === null) return '';
 
-      const maxRTT = 200;
+      // Full at the red band
+      const maxRTT = rttCrit > 0 ? rttCrit : 200;
       const bars = 12;
       const filledCount = Math.min(bars, Math.ceil((rttMs / maxRTT) * bars));
 
-      let html = '<div class="rtt-bar">';
+      let html = '<div class="rtt-bar ' + rttBand(rttMs) + '">';
       for (let i = 0; i < bars; i++) {
         if (i < filledCount - 2) {
           html += '<span class="bar-filled"></span>';
@@ -414,27 +1076,35 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
       updatedEl.textContent = text + ' · ' + now.toLocaleTimeString();
     }
 
-    async function refresh() {
-      try {
-        const res = await fetch('/json', {cache:'no-store', headers:{'Cache-Control':'no-cache','Pragma':'no-cache'}});
-        const data = await res.json();
+    function renderRows(data) {
         tbody.innerHTML = '';
 
-        for (const row of data) {
+        for (const row of sortRows(data)) {
           const tr = document.createElement('tr');
           if (!row.online) {
             tr.className = 'offline-row';
           }
 
+          const degraded = row.state === 'degraded';
+          const alert = row.meets_expectation === false
+            ? ' <span class="status-badge alert" title="expected ' + row.expected + '">! Alert</span>'
+            : '';
           const colValues = {
-            1: row.online
-              ? '<div class="status-cell"><span class="status-badge online">● Online</span></div>'
-              : '<div class="status-cell"><span class="status-badge offline">○ Offline</span></div>',
+            1: (row.online
+              ? (row.lossy
+                ? '<div class="status-cell"><span class="status-badge lossy">● Lossy</span></div>'
+                : '<div class="status-cell"><span class="status-badge online">● Online</span></div>')
+              : degraded
+                ? '<div class="status-cell"><span class="status-badge degraded">◐ Degraded</span></div>'
+                : '<div class="status-cell"><span class="status-badge offline">○ Offline</span></div>').replace('</div>', alert + '</div>'),
             2: row.host || '-',
             3: row.ip || '-',
-            4: row.online ? (row.rtt || '-') : '-',
+            4: row.online || degraded ? (row.rtt || '-') : '-',
             5: row.last_reply || '-',
-            6: row.last_loss_ago ? row.last_loss_ago + ' (' + row.last_loss_duration + ')' : '-'
+            6: row.last_loss_ago ? row.last_loss_ago + ' (' + row.last_loss_duration + ')' : '-',
+            7: row.loss || '-',
+            8: row.jitter || '-',
+            9: row.uptime || '-'
           };
 
           columns.forEach((col) => {
@@ -449,8 +1119,10 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
             } else if (col === 3) {
               td.className = 'ip-cell';
               td.textContent = val;
-            } else if (col === 4 && row.online && val !== '-') {
-              td.innerHTML = '<div class="rtt-cell"><span class="rtt-value">' + val + '</span>' + createRTTBar(parseRTT(val)) + '</div>';
+            } else if (col === 4 && (row.online || degraded) && val !== '-') {
+              const rttMs = parseRTT(val);
+              const band = rttMs === null ? '' : ' ' + rttBand(rttMs);
+              td.innerHTML = '<div class="rtt-cell"><span class="rtt-value' + band + '">' + val + '</span>' + createRTTBar(rttMs) + '</div>';
             } else {
               td.textContent = val;
             }
@@ -458,6 +1130,13 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
           });
           tbody.appendChild(tr);
         }
+    }
+
+    async function refresh() {
+      try {
+        const res = await fetch('/json?case=snake', {cache:'no-store', headers:{'Cache-Control':'no-cache','Pragma':'no-cache'}});
+        lastData = await res.json();
+        renderRows(lastData);
         renderUpdated('Connected');
       } catch (err) {
         tbody.innerHTML = '<tr><td colspan="' + columns.length + '" style="color: var(--red); text-align: center; padding: 24px;">⚠ Error loading data</td></tr>';
@@ -465,56 +1144,48 @@ func (s *StatusServer) htmlHandler(w http.ResponseWriter, _ *http.Request) {
       }
     }
 
-    refresh();
-    setInterval(refresh, REFRESH_MS);
+    // Polling fallback for browsers without EventSource or when the
+    // stream breaks
+    let polling = null;
+    function startPolling() {
+      if (polling !== null) return;
+      refresh();
+      polling = setInterval(refresh, REFRESH_MS);
+    }
+
+    function startEvents() {
+      if (!window.EventSource) {
+        startPolling();
+        return;
+      }
+      const events = new EventSource('/events?case=snake');
+      events.onmessage = (e) => {
+        lastData = JSON.parse(e.data);
+        renderRows(lastData);
+        renderUpdated('Live');
+      };
+      events.onerror = () => {
+        events.close();
+        startPolling();
+      };
+    }
+
+    renderHeader();
+    startEvents();
   </script>
 </body>
-</html>`, s.renderHTMLHeader(cols), marshalColumns(cols))
+</html>`, s.renderHTMLHeader(cols), marshalColumns(cols), s.rttBands.Warn.Milliseconds(), s.rttBands.Crit.Milliseconds())
 }
 
-func (s *StatusServer) collectStatuses() []HostStatus {
+func (s *StatusServer) collectStatuses(view ServerView) []monitor.HostStatus {
 	wrappers := s.repo.GetAll()
-	view := s.snapshotView()
 	filtered := s.filterAndSort(wrappers, view)
-	statuses := make([]HostStatus, 0, len(filtered))
+	statuses := make([]monitor.HostStatus, 0, len(filtered))
 	now := time.Now()
 
 	for _, wrapper := range filtered {
 		stats := s.statsProvider(wrapper)
-
-		host := stats.GetHostRepr()
-		if host == "" {
-			host = wrapper.Host()
-		}
-
-		ip := stats.iprepr
-		online := stats.state && stats.error_message == ""
-		rtt := "-"
-		if online && stats.lastrtt_as_string != "" {
-			rtt = stats.lastrtt_as_string
-		}
-
-		lastReply := "never"
-		if stats.lastrecv > 0 {
-			lastReply = fmt.Sprintf("%s ago", time.Duration(stats.last_seen_nano).Round(time.Second))
-		}
-
-		var lastLossAgo, lastLossDuration string
-		if stats.last_loss_nano > 0 {
-			lastLossAgo = fmt.Sprintf("%s ago", time.Duration(now.UnixNano()-stats.last_loss_nano).Round(time.Second))
-			lastLossDuration = time.Duration(stats.last_loss_duration).Round(time.Second / 10).String()
-		}
-
-		statuses = append(statuses, HostStatus{
-			Host:             host,
-			IP:               ip,
-			Online:           online,
-			RTT:              rtt,
-			LastReply:        lastReply,
-			LastLossAgo:      lastLossAgo,
-			LastLossDuration: lastLossDuration,
-			Error:            stats.error_message,
-		})
+		statuses = append(statuses, monitor.NewHostStatus(wrapper, &stats, now))
 	}
 
 	return statuses
@@ -530,10 +1201,12 @@ func (s *StatusServer) snapshotView() ServerView {
 	s.viewMu.RLock()
 	defer s.viewMu.RUnlock()
 	copied := ServerView{
-		Filter: s.view.Filter,
-		Sort:   s.view.Sort,
-		Hidden: make(map[string]bool, len(s.view.Hidden)),
-		Cols:   append([]int{}, s.view.Cols...),
+		Filter:  s.view.Filter,
+		Sort:    s.view.Sort,
+		Reverse: s.view.Reverse,
+		Hidden:  make(map[string]bool, len(s.view.Hidden)),
+		Cols:    append([]int{}, s.view.Cols...),
+		AbsTime: s.view.AbsTime,
 	}
 	for k, v := range s.view.Hidden {
 		copied.Hidden[k] = v
@@ -541,44 +1214,61 @@ func (s *StatusServer) snapshotView() ServerView {
 	return copied
 }
 
+// columnsFromView returns the visible columns in the TUI's display order
 func (s *StatusServer) columnsFromView() []int {
 	cols := s.snapshotView().Cols
 	if len(cols) == 0 {
 		return []int{1, 2, 3, 4, 5, 6}
 	}
-	out := append([]int{}, cols...)
-	sort.Ints(out)
-	return out
+	return cols
 }
 
-func (s *StatusServer) renderColumns(st HostStatus, columns []int) string {
+func (s *StatusServer) renderColumns(st monitor.HostStatus, columns []int, absolute bool) string {
+	now := time.Now().UnixNano()
 	var parts []string
 	for _, c := range columns {
 		switch c {
 		case 1:
 			if st.Online {
 				parts = append(parts, "✓")
+			} else if st.State == "degraded" {
+				parts = append(parts, "~")
 			} else {
 				parts = append(parts, "✗")
 			}
+			if !st.MeetsExpectation {
+				parts[len(parts)-1] += "!"
+			}
 		case 2:
 			parts = append(parts, st.Host)
 		case 3:
 			parts = append(parts, st.IP)
 		case 4:
-			if st.Online {
+			if st.Online || st.State == "degraded" {
 				parts = append(parts, st.RTT)
 			} else {
 				parts = append(parts, "-")
 			}
 		case 5:
-			parts = append(parts, st.LastReply)
+			if absolute && st.LastRecvNano > 0 {
+				parts = append(parts, formatTimestamp(st.LastRecvNano, now, time.Second, true))
+			} else {
+				parts = append(parts, st.LastReply)
+			}
 		case 6:
-			if st.LastLossAgo != "" {
+			if absolute && st.LastLossNano > 0 {
+				parts = append(parts, fmt.Sprintf("%s (%s)", formatTimestamp(st.LastLossNano, now, time.Second, true), st.LastLossDuration))
+			} else if st.LastLossAgo != "" {
 				parts = append(parts, fmt.Sprintf("%s (%s)", st.LastLossAgo, st.LastLossDuration))
 			} else {
 				parts = append(parts, "-")
 			}
+		case 7:
+			parts = append(parts, st.Loss)
+		case 8:
+			parts = append(parts, st.Jitter)
+		case 9:
+			parts = append(parts, st.Uptime)
 		}
 	}
 	return strings.Join(parts, " | ")
@@ -587,7 +1277,7 @@ func (s *StatusServer) renderColumns(st HostStatus, columns []int) string {
 func (s *StatusServer) renderHTMLHeader(columns []int) string {
 	var b strings.Builder
 	for _, c := range columns {
-		name := map[int]string{1: "St", 2: "Name", 3: "IP", 4: "RTT", 5: "Last Reply", 6: "Last Loss"}[c]
+		name := map[int]string{1: "St", 2: "Name", 3: "IP", 4: "RTT", 5: "Last Reply", 6: "Last Loss", 7: "Loss", 8: "Jitter", 9: "Uptime"}[c]
 		fmt.Fprintf(&b, "<th>%s</th>", name)
 	}
 	return b.String()
@@ -598,8 +1288,8 @@ func marshalColumns(cols []int) string {
 	return string(data)
 }
 
-func (s *StatusServer) filterAndSort(wrappers []PingWrapperInterface, view ServerView) []PingWrapperInterface {
-	var filtered []PingWrapperInterface
+func (s *StatusServer) filterAndSort(wrappers []monitor.PingWrapperInterface, view ServerView) []monitor.PingWrapperInterface {
+	var filtered []monitor.PingWrapperInterface
 
 	for _, wrapper := range wrappers {
 		if view.Hidden[wrapper.Host()] {
@@ -607,8 +1297,11 @@ func (s *StatusServer) filterAndSort(wrappers []PingWrapperInterface, view Serve
 		}
 
 		stats := s.statsProvider(wrapper)
-		isOnline := stats.state && stats.error_message == ""
-		seen := stats.has_ever_received
+		if view.Search != "" && !matchesSearch(wrapper, &stats, view.Search) {
+			continue
+		}
+		isOnline := stats.State && stats.ErrorMessage == ""
+		seen := stats.HasEverReceived
 
 		switch view.Filter {
 		case FilterAll:
@@ -633,8 +1326,8 @@ func (s *StatusServer) filterAndSort(wrappers []PingWrapperInterface, view Serve
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := s.statsProvider(filtered[i])
 			statsJ := s.statsProvider(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 			if onlineI != onlineJ {
 				return onlineI
 			}
@@ -652,8 +1345,8 @@ func (s *StatusServer) filterAndSort(wrappers []PingWrapperInterface, view Serve
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := s.statsProvider(filtered[i])
 			statsJ := s.statsProvider(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 			if onlineI != onlineJ {
 				return onlineI
 			}
@@ -663,41 +1356,41 @@ func (s *StatusServer) filterAndSort(wrappers []PingWrapperInterface, view Serve
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := s.statsProvider(filtered[i])
 			statsJ := s.statsProvider(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 			if onlineI != onlineJ {
 				return onlineI
 			}
-			return statsI.lastrtt < statsJ.lastrtt
+			return statsI.LastRTT < statsJ.LastRTT
 		})
 	case SortByLastSeen:
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := s.statsProvider(filtered[i])
 			statsJ := s.statsProvider(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 			if onlineI != onlineJ {
 				return !onlineI
 			}
 			if !onlineI && !onlineJ {
-				if statsI.lastrecv == 0 && statsJ.lastrecv == 0 {
+				if statsI.LastRecv == 0 && statsJ.LastRecv == 0 {
 					return filtered[i].Host() < filtered[j].Host()
 				}
-				if statsI.lastrecv == 0 {
+				if statsI.LastRecv == 0 {
 					return false
 				}
-				if statsJ.lastrecv == 0 {
+				if statsJ.LastRecv == 0 {
 					return true
 				}
-				return statsI.last_loss_nano > statsJ.last_loss_nano
+				return statsI.LastLossNano > statsJ.LastLossNano
 			}
-			hasLossI := statsI.last_loss_nano > 0
-			hasLossJ := statsJ.last_loss_nano > 0
+			hasLossI := statsI.LastLossNano > 0
+			hasLossJ := statsJ.LastLossNano > 0
 			if hasLossI != hasLossJ {
 				return hasLossI
 			}
 			if hasLossI && hasLossJ {
-				return statsI.last_loss_nano > statsJ.last_loss_nano
+				return statsI.LastLossNano > statsJ.LastLossNano
 			}
 			nameI := statsI.GetHostRepr()
 			nameJ := statsJ.GetHostRepr()
@@ -713,8 +1406,8 @@ func (s *StatusServer) filterAndSort(wrappers []PingWrapperInterface, view Serve
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := s.statsProvider(filtered[i])
 			statsJ := s.statsProvider(filtered[j])
-			keyI := ipKey(statsI.iprepr)
-			keyJ := ipKey(statsJ.iprepr)
+			keyI := ipKey(statsI.IPRepr)
+			keyJ := ipKey(statsJ.IPRepr)
 			if keyI != nil && keyJ != nil && !bytes.Equal(keyI, keyJ) {
 				return bytes.Compare(keyI, keyJ) < 0
 			}
@@ -726,6 +1419,24 @@ func (s *StatusServer) filterAndSort(wrappers []PingWrapperInterface, view Serve
 			}
 			return filtered[i].Host() < filtered[j].Host()
 		})
+	case SortByUptime:
+		now := time.Now().UnixNano()
+		sort.Slice(filtered, func(i, j int) bool {
+			statsI := s.statsProvider(filtered[i])
+			statsJ := s.statsProvider(filtered[j])
+			uptimeI, okI := statsI.UptimePercent(now)
+			uptimeJ, okJ := statsJ.UptimePercent(now)
+			if okI != okJ {
+				return okI
+			}
+			if uptimeI != uptimeJ {
+				return uptimeI < uptimeJ
+			}
+			return filtered[i].Host() < filtered[j].Host()
+		})
+	}
+	if view.Reverse {
+		reverseSorted(filtered, view.Sort, s.statsProvider)
 	}
 
 	return filtered
@@ -1,129 +1,275 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
 	"os"
 	"runtime"
-	"sync"
+	"slices"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/babs/multiping/monitor"
 	probing "github.com/prometheus-community/pro-bing"
 	"github.com/pterm/pterm"
 )
 
+// MaxCIDRAddresses is the largest prefix ExpandCIDR expands, in addresses
+// (-max-cidr, 0 = no limit). It keeps an IPv6 /64 from exhausting memory.
+var MaxCIDRAddresses = 65536
+
+// ErrCIDRTooLarge is returned by ExpandCIDR for prefixes over MaxCIDRAddresses
+var ErrCIDRTooLarge = errors.New("CIDR too large")
+
 // ExpandCIDR takes a CIDR string (e.g. "192.168.1.0/24") and returns a list of all IPs in that subnet.
-// It returns nil if the string is not a valid CIDR.
-func ExpandCIDR(cidr string) ([]string, error) {
-	ip, ipnet, err := net.ParseCIDR(cidr)
+// It returns nil if the string is not a valid CIDR. The first and last
+// (network and broadcast) addresses are left out unless includeNetwork is
+// set; /31 (RFC 3021), IPv6 /127 and single-address prefixes keep them all.
+// Prefixes with more than MaxCIDRAddresses addresses fail with ErrCIDRTooLarge.
+func ExpandCIDR(cidr string, includeNetwork bool) ([]string, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
 	if err != nil {
 		return nil, err
 	}
+	if ones, bits := ipnet.Mask.Size(); MaxCIDRAddresses > 0 && (bits-ones >= 63 || 1<<(bits-ones) > MaxCIDRAddresses) {
+		return nil, fmt.Errorf("%w: %s has 2^%d addresses, more than -max-cidr %d", ErrCIDRTooLarge, cidr, bits-ones, MaxCIDRAddresses)
+	}
 
 	var ips []string
-	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
+	// ipnet.IP is the masked network address; every step works on a copy
+	for ip := ipnet.IP; ipnet.Contains(ip); ip = nextIP(ip) {
 		ips = append(ips, ip.String())
 	}
 
-	// Remove network and broadcast addresses if applicable (simple heuristic)
-	if len(ips) > 2 {
+	// Remove network and broadcast addresses. Two-address prefixes are
+	// point-to-point links (/31, IPv6 /127) where both are usable.
+	ones, bits := ipnet.Mask.Size()
+	if !includeNetwork && bits-ones > 1 {
 		ips = ips[1 : len(ips)-1]
 	}
 	return ips, nil
 }
 
+// ParseExcludes converts IPs and CIDRs to networks (single IPs become /32 or
+// /128). Anything else is kept as a literal host name to match exactly.
+func ParseExcludes(specs []string) ([]*net.IPNet, []string) {
+	var nets []*net.IPNet
+	var names []string
+	for _, spec := range specs {
+		if _, ipnet, err := net.ParseCIDR(spec); err == nil {
+			nets = append(nets, ipnet)
+			continue
+		}
+		if ip := net.ParseIP(strings.Trim(spec, "[]")); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		names = append(names, spec)
+	}
+	return nets, names
+}
+
+// ExcludeHosts drops hosts whose address falls in one of the excluded networks
+// or whose host string matches an excluded name. Returns the kept hosts and
+// the number removed.
+func ExcludeHosts(hosts []string, excludes []string) ([]string, int) {
+	if len(excludes) == 0 {
+		return hosts, 0
+	}
+	nets, names := ParseExcludes(excludes)
+
+	kept := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if slices.Contains(names, host) || containsIP(nets, hostIP(host)) {
+			continue
+		}
+		kept = append(kept, host)
+	}
+	return kept, len(hosts) - len(kept)
+}
+
+// ShuffleHosts randomizes the order of hosts in place so adjacent addresses
+// don't start probing together. A non-zero seed makes the order reproducible.
+func ShuffleHosts(hosts []string, seed uint64) {
+	r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	if seed != 0 {
+		r = rand.New(rand.NewPCG(seed, seed))
+	}
+	r.Shuffle(len(hosts), func(i, j int) {
+		hosts[i], hosts[j] = hosts[j], hosts[i]
+	})
+}
+
+// hostIP extracts the literal IP of a host string (bare, ip://, tcp://...).
+// Returns nil for host names.
+func hostIP(host string) net.IP {
+	if m := monitor.ReHostWithProto.FindStringSubmatch(host); m != nil {
+		host = m[3]
+	}
+	return net.ParseIP(strings.Trim(host, "[]"))
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 type OnceResult struct {
 	IP       string
 	Hostname string
 	Status   string
+	Online   bool
+	RTT      string  // average RTT, "-" when offline or errored
+	Loss     float64 // fraction of probes lost, 1 when errored
 }
 
-func RunPingOnce(hosts []string, onlyOnline, onlyOffline bool, logFile string) {
-	fmt.Printf("Pinging %d targets...\n", len(hosts))
-
-	var wg sync.WaitGroup
-	results := make(chan OnceResult, len(hosts))
-
-	// Limit concurrency to avoid file descriptor limits
-	sem := make(chan struct{}, 100)
+// OnceOptions controls filtering and output of RunPingOnce
+type OnceOptions struct {
+	OnlyOnline   bool
+	OnlyOffline  bool
+	LogFile      string
+	Template     *template.Template // when set, replaces the table with one rendered line per result
+	Count        int                // probes per host, online if any is answered
+	Timeout      time.Duration      // overall deadline, unfinished hosts are reported as timed out (0 = none)
+	ProbeTimeout time.Duration      // wait for the reply to the last probe (0 = 1s)
+	Stream       bool               // print each result as it arrives instead of all at the end
+	Sort         string             // order of the printed and logged results: ip, name or status
+	Format       string             // "table", or "nagios" for one plugin status line
+	NagiosWarn   DownThreshold      // hosts down for WARNING with Format nagios
+	NagiosCrit   DownThreshold      // hosts down for CRITICAL with Format nagios
+}
 
-	for _, host := range hosts {
-		wg.Add(1)
-		go func(target string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+// OnceSummary counts the results of RunPingOnce before -only-online and
+// -only-offline filter them
+type OnceSummary struct {
+	Total  int
+	Online int
+	Down   []string // hosts offline or timed out, in the order of the results
+}
 
-			// Simple heuristic: if it looks like an IP, use it directly, otherwise let pinger resolve it
-			// But pro-bing handles resolution.
-			// However, for our "ping once" mode, we want to be robust.
+// ExitCode returns the process status for -fail-on: 0 when every host is
+// up, 1 when some and 2 when all are down. With "all" only every host down
+// fails, with "none" nothing does.
+func (s OnceSummary) ExitCode(failOn string) int {
+	switch {
+	case s.Online == s.Total || failOn == "none":
+		return 0
+	case s.Online == 0:
+		return 2
+	case failOn == "all":
+		return 0
+	default:
+		return 1
+	}
+}
 
-			pinger, err := probing.NewPinger(target)
-			if err != nil {
-				if !onlyOnline {
-					results <- OnceResult{IP: target, Hostname: "-", Status: fmt.Sprintf("Error (%v)", err)}
-				}
-				return
-			}
+// RunPingOnce probes every host and prints the results, returning how many
+// were online
+func RunPingOnce(hosts []string, opts OnceOptions) OnceSummary {
+	onlyOnline, onlyOffline, logFile := opts.OnlyOnline, opts.OnlyOffline, opts.LogFile
 
-			pinger.Count = 1
-			pinger.Timeout = 1 * time.Second
-			pinger.SetPrivileged(true) // Try privileged first
-			if runtime.GOOS == "linux" {
-				pinger.SetDoNotFragment(true)
-			}
+	if opts.Template != nil || opts.Format == "nagios" {
+		// Keep stdout clean for the templated or plugin output
+		fmt.Fprintf(os.Stderr, "Pinging %d targets...\n", len(hosts))
+	} else {
+		fmt.Printf("Pinging %d targets...\n", len(hosts))
+	}
 
-			// Fallback for unprivileged if needed
-			if runtime.GOOS != "windows" && os.Getuid() != 0 {
-				pinger.SetPrivileged(false)
-			}
+	type indexedResult struct {
+		i   int
+		res OnceResult
+	}
+	// Buffered for every host so pings still running after the deadline
+	// never block
+	results := make(chan indexedResult, len(hosts))
 
-			err = pinger.Run()
-			if err != nil {
-				if !onlyOnline {
-					results <- OnceResult{IP: target, Hostname: "-", Status: fmt.Sprintf("Error (%v)", err)}
-				}
-				return
-			}
+	// Limit concurrency to avoid file descriptor limits
+	sem := make(chan struct{}, 100)
 
-			// Get resolved IP address
-			ipAddrObj := pinger.IPAddr()
-			ipAddr := ipAddrObj.String()
+	for i, host := range hosts {
+		go func(i int, target string) {
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-			// Perform reverse DNS lookup (with timeout)
-			hostname := "-"
-			if !SkipDNS {
-				hostname = hostDisplayName(target, ipAddrObj)
-			}
-			// If hostname is same as IP, show "-" for cleaner output
-			if hostname == ipAddr || hostname == target {
-				hostname = "-"
-			}
+			results <- indexedResult{i, pingOnce(target, !monitor.SkipDNS, opts.Count, opts.ProbeTimeout)}
+		}(i, host)
+	}
 
-			if pinger.Statistics().PacketsRecv > 0 {
-				if !onlyOffline {
-					results <- OnceResult{IP: ipAddr, Hostname: hostname, Status: "Online"}
-				}
-			} else {
-				if !onlyOnline {
-					results <- OnceResult{IP: ipAddr, Hostname: hostname, Status: "Offline"}
-				}
-			}
-		}(host)
+	var deadline <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
 	}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	shown := func(res OnceResult) bool {
+		return !(res.Online && onlyOffline) && !(!res.Online && onlyOnline)
+	}
+	// With -stream results are printed here as they arrive. Only this
+	// goroutine prints, so lines never interleave.
+	printing := opts.Stream && opts.Format != "nagios"
+	stream := func(res OnceResult) {
+		if printing && shown(res) {
+			printing = printOnceResult(res, opts.Template)
+		}
+	}
+	if printing && opts.Template == nil {
+		printOnceHeader()
+	}
 
-	// Collect and sort results for consistent output
+	// Collect results until all arrived or the deadline passed
 	var resultList []OnceResult
-	for res := range results {
-		resultList = append(resultList, res)
+	finished := make([]bool, len(hosts))
+collect:
+	for received := 0; received < len(hosts); received++ {
+		select {
+		case r := <-results:
+			finished[r.i] = true
+			resultList = append(resultList, r.res)
+			stream(r.res)
+		case <-deadline:
+			break collect
+		}
+	}
+	for i, host := range hosts {
+		if !finished[i] {
+			res := OnceResult{IP: host, Hostname: "-", Status: "Timeout", RTT: "-", Loss: 1}
+			resultList = append(resultList, res)
+			stream(res)
+		}
+	}
+	sortOnceResults(resultList, opts.Sort)
+
+	summary := OnceSummary{Total: len(resultList)}
+	for _, res := range resultList {
+		if res.Online {
+			summary.Online++
+		} else {
+			summary.Down = append(summary.Down, res.IP)
+		}
 	}
 
+	resultList = slices.DeleteFunc(resultList, func(res OnceResult) bool {
+		return !shown(res)
+	})
+
 	// Write to log file if specified
 	if logFile != "" {
 		f, err := os.Create(logFile)
@@ -134,10 +280,12 @@ func RunPingOnce(hosts []string, onlyOnline, onlyOffline bool, logFile string) {
 
 			// Create JSON output structure for Ansible compatibility
 			type HostEntry struct {
-				IP       string `json:"ip"`
-				Hostname string `json:"hostname"`
-				Status   string `json:"status"`
-				Online   bool   `json:"online"`
+				IP       string  `json:"ip"`
+				Hostname string  `json:"hostname"`
+				Status   string  `json:"status"`
+				Online   bool    `json:"online"`
+				RTT      string  `json:"rtt"`
+				Loss     float64 `json:"loss"`
 			}
 
 			type JSONOutput struct {
@@ -156,7 +304,7 @@ func RunPingOnce(hosts []string, onlyOnline, onlyOffline bool, logFile string) {
 
 			// Convert results to structured format
 			for _, res := range resultList {
-				online := res.Status == "Online"
+				online := res.Online
 				if online {
 					output.Online++
 				} else {
@@ -173,6 +321,8 @@ func RunPingOnce(hosts []string, onlyOnline, onlyOffline bool, logFile string) {
 					Hostname: hostname,
 					Status:   res.Status,
 					Online:   online,
+					RTT:      res.RTT,
+					Loss:     res.Loss,
 				})
 			}
 
@@ -188,47 +338,198 @@ func RunPingOnce(hosts []string, onlyOnline, onlyOffline bool, logFile string) {
 		}
 	}
 
-	// Print header with color
+	if opts.Format == "nagios" {
+		writeNagios(os.Stdout, summary, opts.NagiosWarn, opts.NagiosCrit)
+		return summary
+	}
+	if opts.Stream {
+		return summary
+	}
+	if opts.Template == nil {
+		printOnceHeader()
+	}
+	for _, res := range resultList {
+		if !printOnceResult(res, opts.Template) {
+			break
+		}
+	}
+	return summary
+}
+
+// onceSorts are the -once-sort modes
+var onceSorts = []string{"ip", "name", "status"}
+
+// sortOnceResults orders results by address, by hostname ("name", hosts
+// without one after) or online first ("status"), ties by address. Targets
+// that are no IP, e.g. names that failed to resolve, come last by name.
+func sortOnceResults(results []OnceResult, mode string) {
+	slices.SortStableFunc(results, func(a, b OnceResult) int {
+		keyA, keyB := ipKey(a.IP), ipKey(b.IP)
+		if (keyA == nil) != (keyB == nil) {
+			if keyA == nil {
+				return 1
+			}
+			return -1
+		}
+		if keyA == nil {
+			return strings.Compare(a.IP, b.IP)
+		}
+		switch mode {
+		case "name":
+			if (a.Hostname == "-") != (b.Hostname == "-") {
+				if a.Hostname == "-" {
+					return 1
+				}
+				return -1
+			}
+			if c := strings.Compare(a.Hostname, b.Hostname); c != 0 {
+				return c
+			}
+		case "status":
+			if a.Online != b.Online {
+				if a.Online {
+					return -1
+				}
+				return 1
+			}
+		}
+		return bytes.Compare(keyA, keyB)
+	})
+}
+
+// printOnceHeader prints the header of the once mode table
+func printOnceHeader() {
 	headerStyle := pterm.NewStyle(pterm.FgLightCyan, pterm.Bold)
 	headerStyle.Printf("%-15s", "IP Address")
 	fmt.Print(" │ ")
 	headerStyle.Printf("%-40s", "Hostname")
 	fmt.Print(" │ ")
+	headerStyle.Printf("%-10s", "RTT")
+	fmt.Print(" │ ")
 	headerStyle.Println("Status")
 
-	pterm.Println(pterm.LightBlue("────────────────┼──────────────────────────────────────────┼──────────"))
-
-	// Print results with colors
-	for _, res := range resultList {
-		// Color IP address in cyan
-		pterm.FgCyan.Printf("%-15s", res.IP)
-		fmt.Print(" │ ")
+	pterm.Println(pterm.LightBlue("────────────────┼──────────────────────────────────────────┼────────────┼──────────"))
+}
 
-		// Color hostname (or show gray dash if none)
-		if res.Hostname == "-" {
-			pterm.FgGray.Printf("%-40s", res.Hostname)
-		} else {
-			pterm.FgLightBlue.Printf("%-40s", res.Hostname)
+// printOnceResult prints one result as a table row, or rendered by tmpl if
+// set. It returns false once the template fails, later results are not
+// printed then.
+func printOnceResult(res OnceResult, tmpl *template.Template) bool {
+	if tmpl != nil {
+		if err := tmpl.Execute(os.Stdout, res); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering template: %v\n", err)
+			return false
 		}
-		fmt.Print(" │ ")
+		fmt.Println()
+		return true
+	}
+
+	// Color IP address in cyan
+	pterm.FgCyan.Printf("%-15s", res.IP)
+	fmt.Print(" │ ")
+
+	// Color hostname (or show gray dash if none)
+	if res.Hostname == "-" {
+		pterm.FgGray.Printf("%-40s", res.Hostname)
+	} else {
+		pterm.FgLightBlue.Printf("%-40s", res.Hostname)
+	}
+	fmt.Print(" │ ")
+
+	if res.Online {
+		fmt.Printf("%-10s", res.RTT)
+	} else {
+		pterm.FgGray.Printf("%-10s", res.RTT)
+	}
+	fmt.Print(" │ ")
+
+	// Color status based on state
+	switch {
+	case res.Status == "Online":
+		pterm.FgGreen.Println("✓ Online")
+	case res.Status == "Offline":
+		pterm.FgRed.Println("✗ Offline")
+	default:
+		pterm.FgYellow.Println("⚠ " + res.Status)
+	}
+	return true
+}
+
+// pingOnce sends count ICMP probes to target, one second apart, and waits
+// up to timeout for the last reply. With lookupName the result carries the
+// reverse DNS name, "-" otherwise.
+func pingOnce(target string, lookupName bool, count int, timeout time.Duration) OnceResult {
+	if count < 1 {
+		count = 1
+	}
+	if timeout <= 0 {
+		timeout = monitor.DefaultProbeTimeout
+	}
+
+	// Simple heuristic: if it looks like an IP, use it directly, otherwise let pinger resolve it
+	// But pro-bing handles resolution.
+	// However, for our "ping once" mode, we want to be robust.
 
-		// Color status based on state
-		switch {
-		case res.Status == "Online":
-			pterm.FgGreen.Println("✓ Online")
-		case res.Status == "Offline":
-			pterm.FgRed.Println("✗ Offline")
-		default:
-			pterm.FgYellow.Println("⚠ " + res.Status)
+	pinger, err := probing.NewPinger(target)
+	if err != nil {
+		return OnceResult{IP: target, Hostname: "-", Status: fmt.Sprintf("Error (%v)", err), RTT: "-", Loss: 1}
+	}
+
+	pinger.Count = count
+	pinger.Timeout = time.Duration(count-1)*pinger.Interval + timeout
+	pinger.SetPrivileged(true) // Try privileged first
+	if runtime.GOOS == "linux" {
+		pinger.SetDoNotFragment(true)
+	}
+
+	// Fallback for unprivileged if needed
+	if runtime.GOOS != "windows" && os.Getuid() != 0 {
+		pinger.SetPrivileged(false)
+	}
+
+	err = pinger.Run()
+	if err != nil {
+		return OnceResult{IP: target, Hostname: "-", Status: fmt.Sprintf("Error (%v)", err), RTT: "-", Loss: 1}
+	}
+
+	// Get resolved IP address
+	ipAddrObj := pinger.IPAddr()
+	ipAddr := ipAddrObj.String()
+
+	// Perform reverse DNS lookup (with timeout)
+	hostname := "-"
+	if lookupName {
+		hostname = monitor.HostDisplayName(target, ipAddrObj)
+	}
+	// If hostname is same as IP, show "-" for cleaner output
+	if hostname == ipAddr || hostname == target {
+		hostname = "-"
+	}
+
+	stats := pinger.Statistics()
+	loss := 1.0
+	if stats.PacketsSent > 0 {
+		loss = float64(stats.PacketsSent-stats.PacketsRecv) / float64(stats.PacketsSent)
+	}
+	if stats.PacketsRecv > 0 {
+		status := "Online"
+		if count > 1 && loss > 0 {
+			status = fmt.Sprintf("Online (%.0f%% loss)", loss*100)
 		}
+		return OnceResult{IP: ipAddr, Hostname: hostname, Status: status, Online: true, RTT: monitor.Round(stats.AvgRtt, 2).String(), Loss: loss}
 	}
+	return OnceResult{IP: ipAddr, Hostname: hostname, Status: "Offline", RTT: "-", Loss: loss}
 }
 
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
+// nextIP returns a copy of ip incremented by one, leaving ip untouched.
+// The last address of the family wraps around to the first.
+func nextIP(ip net.IP) net.IP {
+	next := slices.Clone(ip)
+	for j := len(next) - 1; j >= 0; j-- {
+		next[j]++
+		if next[j] > 0 {
 			break
 		}
 	}
+	return next
 }
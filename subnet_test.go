@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestExpandCIDR(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		include bool // -include-network
+		first   string
+		last    string
+		count   int
+	}{
+		// Single host
+		{"10.0.0.5/32", false, "10.0.0.5", "10.0.0.5", 1},
+		{"10.0.0.5/32", true, "10.0.0.5", "10.0.0.5", 1},
+		// Point-to-point link, both addresses usable (RFC 3021)
+		{"10.0.0.0/31", false, "10.0.0.0", "10.0.0.1", 2},
+		{"10.0.0.0/31", true, "10.0.0.0", "10.0.0.1", 2},
+		// Network and broadcast left out unless included
+		{"10.0.0.0/30", false, "10.0.0.1", "10.0.0.2", 2},
+		{"10.0.0.0/30", true, "10.0.0.0", "10.0.0.3", 4},
+		{"192.168.1.0/24", false, "192.168.1.1", "192.168.1.254", 254},
+		{"192.168.1.0/24", true, "192.168.1.0", "192.168.1.255", 256},
+	}
+	for _, tt := range tests {
+		ips, err := ExpandCIDR(tt.cidr, tt.include)
+		if err != nil {
+			t.Errorf("ExpandCIDR(%q, %v): %v", tt.cidr, tt.include, err)
+			continue
+		}
+		if len(ips) != tt.count {
+			t.Errorf("ExpandCIDR(%q, %v) = %d addresses, want %d", tt.cidr, tt.include, len(ips), tt.count)
+			continue
+		}
+		if ips[0] != tt.first || ips[len(ips)-1] != tt.last {
+			t.Errorf("ExpandCIDR(%q, %v) = %s .. %s, want %s .. %s", tt.cidr, tt.include, ips[0], ips[len(ips)-1], tt.first, tt.last)
+		}
+	}
+}
+
+func TestExpandCIDRTooLarge(t *testing.T) {
+	for _, cidr := range []string{"2001:db8::/64", "2001:db8::/32", "10.0.0.0/8"} {
+		ips, err := ExpandCIDR(cidr, false)
+		if !errors.Is(err, ErrCIDRTooLarge) || ips != nil {
+			t.Errorf("ExpandCIDR(%q) = %d addresses, %v, want ErrCIDRTooLarge", cidr, len(ips), err)
+		}
+	}
+
+	ips, err := ExpandCIDR("2001:db8::/120", false)
+	if err != nil {
+		t.Fatalf("ExpandCIDR(2001:db8::/120): %v", err)
+	}
+	if len(ips) != 254 {
+		t.Fatalf("ExpandCIDR(2001:db8::/120) = %d addresses, want 254", len(ips))
+	}
+	if ips[0] != "2001:db8::1" || ips[253] != "2001:db8::fe" {
+		t.Errorf("ExpandCIDR(2001:db8::/120) = %s .. %s, want 2001:db8::1 .. 2001:db8::fe", ips[0], ips[253])
+	}
+
+	// The cap is -max-cidr, in addresses
+	defer func(max int) { MaxCIDRAddresses = max }(MaxCIDRAddresses)
+	MaxCIDRAddresses = 256
+	if _, err := ExpandCIDR("10.0.0.0/24", false); err != nil {
+		t.Errorf("ExpandCIDR(10.0.0.0/24) with a cap of 256: %v", err)
+	}
+	if _, err := ExpandCIDR("10.0.0.0/23", false); !errors.Is(err, ErrCIDRTooLarge) {
+		t.Errorf("ExpandCIDR(10.0.0.0/23) with a cap of 256: %v, want ErrCIDRTooLarge", err)
+	}
+}
+
+func TestNextIP(t *testing.T) {
+	ip := net.ParseIP("192.168.0.255").To4()
+	next := nextIP(ip)
+	if got := ip.String(); got != "192.168.0.255" {
+		t.Errorf("nextIP changed its argument to %s", got)
+	}
+	if got := next.String(); got != "192.168.1.0" {
+		t.Errorf("nextIP(192.168.0.255) = %s, want 192.168.1.0", got)
+	}
+
+	// Every address is built from its own copy, earlier ones stay as listed
+	ips, err := ExpandCIDR("192.168.0.0/30", true)
+	if err != nil {
+		t.Fatalf("ExpandCIDR(192.168.0.0/30): %v", err)
+	}
+	want := []string{"192.168.0.0", "192.168.0.1", "192.168.0.2", "192.168.0.3"}
+	if !slices.Equal(ips, want) {
+		t.Errorf("ExpandCIDR(192.168.0.0/30) = %v, want %v", ips, want)
+	}
+	ips, _ = ExpandCIDR("192.168.0.0/30", false)
+	if want := want[1:3]; !slices.Equal(ips, want) {
+		t.Errorf("ExpandCIDR(192.168.0.0/30) = %v, want %v", ips, want)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"io"
+	"sort"
+	"time"
+)
+
+// summaryWorstHosts is how many hosts the session summary lists
+const summaryWorstHosts = 5
+
+// WriteSessionSummary prints the end-of-session report of a -duration run:
+// overall uptime and transitions, then the hosts with the lowest uptime.
+func WriteSessionSummary(out io.Writer, wrappers []monitor.PingWrapperInterface, elapsed time.Duration) {
+	type hostSummary struct {
+		name        string
+		uptime      float64 // percent of the time monitored
+		transitions int64
+		loss        float64
+	}
+
+	now := time.Now().UnixNano()
+	hosts := make([]hostSummary, 0, len(wrappers))
+	var transitions int64
+	var uptimeSum float64
+	for _, w := range wrappers {
+		stats := w.Stats().Snapshot()
+		uptime, _ := stats.UptimePercent(now)
+		name := stats.GetHostRepr()
+		if name == "" {
+			name = w.Host()
+		}
+		hosts = append(hosts, hostSummary{name, uptime, stats.Transitions, stats.LossPercent()})
+		transitions += stats.Transitions
+		uptimeSum += uptime
+	}
+
+	fmt.Fprintf(out, "Session summary after %s: %d hosts, %d transitions", elapsed.Round(time.Second), len(hosts), transitions)
+	if len(hosts) > 0 {
+		fmt.Fprintf(out, ", average uptime %.1f%%", uptimeSum/float64(len(hosts)))
+	}
+	fmt.Fprintln(out)
+
+	sort.SliceStable(hosts, func(i, j int) bool {
+		if hosts[i].uptime != hosts[j].uptime {
+			return hosts[i].uptime < hosts[j].uptime
+		}
+		return hosts[i].transitions > hosts[j].transitions
+	})
+	var worst []hostSummary
+	for _, h := range hosts {
+		// Sorted by uptime, so the rest was up all along (100.0% as printed)
+		if len(worst) == summaryWorstHosts || (h.uptime >= 99.95 && h.transitions == 0) {
+			break
+		}
+		worst = append(worst, h)
+	}
+	if len(worst) == 0 {
+		fmt.Fprintln(out, "All hosts were up the whole time")
+		return
+	}
+	fmt.Fprintln(out, "Worst hosts:")
+	for _, h := range worst {
+		fmt.Fprintf(out, "  %-40s uptime %5.1f%%  %3d transitions  loss %5.1f%%\n", h.name, h.uptime, h.transitions, h.loss)
+	}
+}
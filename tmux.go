@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RunTmux probes each host once and prints "online/total" for a tmux
+// status line, waiting up to timeout (-timeout) for each reply. With color
+// the count is wrapped in tmux style markup. Returns 0 when every host is
+// up, 1 otherwise.
+func RunTmux(out io.Writer, hosts []string, color bool, timeout time.Duration) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	online := 0
+
+	// Same concurrency limit as RunPingOnce
+	sem := make(chan struct{}, 100)
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// No reverse lookups, this is polled every few seconds
+			if pingOnce(target, false, 1, timeout).Online {
+				mu.Lock()
+				online++
+				mu.Unlock()
+			}
+		}(host)
+	}
+	wg.Wait()
+
+	status := fmt.Sprintf("%d/%d", online, len(hosts))
+	allUp := online == len(hosts)
+	if color {
+		fg := "red"
+		if allUp {
+			fg = "green"
+		}
+		status = fmt.Sprintf("#[fg=%s]%s#[default]", fg, status)
+	}
+	fmt.Fprintln(out, status)
+
+	if allUp {
+		return 0
+	}
+	return 1
+}
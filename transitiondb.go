@@ -0,0 +1,161 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/babs/multiping/monitor"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+const (
+	dbQueueSize = 4096 // transitions waiting to be written, more are dropped
+	dbBatchSize = 256  // transitions written per transaction at most
+)
+
+// dbSchema sets up the single connection and creates the -db table on first
+// use. WAL lets the database be queried while mping writes to it. Timestamps
+// are RFC 3339 in UTC, state is 1 for online and 0 for offline.
+const dbSchema = `
+PRAGMA busy_timeout = 5000;
+PRAGMA journal_mode = wal;
+CREATE TABLE IF NOT EXISTS transitions (
+	id         INTEGER PRIMARY KEY,
+	timestamp  TEXT    NOT NULL,
+	unixnano   INTEGER NOT NULL,
+	host       TEXT    NOT NULL,
+	ip         TEXT    NOT NULL,
+	transition TEXT    NOT NULL,
+	state      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS transitions_host_time ON transitions (host, unixnano);
+`
+
+// TransitionDB records transitions in a SQLite database from its own
+// goroutine. Transitions queued while a write is in progress are written
+// together in one transaction; those arriving while the queue is full are
+// dropped and counted.
+type TransitionDB struct {
+	db      *sql.DB
+	queue   chan monitor.TransitionRecord
+	done    chan struct{}
+	dropped atomic.Int64
+	failed  atomic.Int64
+	mu      sync.Mutex // guards closing queue against Send
+	closed  bool
+}
+
+// OpenTransitionDB opens or creates the database at path and starts the
+// writer
+func OpenTransitionDB(path string) (*TransitionDB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// One connection keeps the pragmas and SQLite allows one writer at a
+	// time anyway
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(dbSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database %s: %w", path, err)
+	}
+	d := &TransitionDB{
+		db:    db,
+		queue: make(chan monitor.TransitionRecord, dbQueueSize),
+		done:  make(chan struct{}),
+	}
+	go d.run()
+	return d, nil
+}
+
+// Send queues a transition without blocking
+func (d *TransitionDB) Send(rec monitor.TransitionRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.queue <- rec:
+	default:
+		d.dropped.Add(1)
+	}
+}
+
+// Close writes the queued transitions and closes the database. Transitions
+// sent after Close are dropped.
+func (d *TransitionDB) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.queue)
+	d.mu.Unlock()
+
+	<-d.done
+	if err := d.db.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "database: %v\n", err)
+	}
+	if n := d.dropped.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "database: %d transitions dropped, the disk didn't keep up\n", n)
+	}
+	if n := d.failed.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "database: %d transitions could not be written\n", n)
+	}
+}
+
+func (d *TransitionDB) run() {
+	defer close(d.done)
+	batch := make([]monitor.TransitionRecord, 0, dbBatchSize)
+	for rec := range d.queue {
+		batch = append(batch[:0], rec)
+	drain:
+		for len(batch) < dbBatchSize {
+			select {
+			case rec, ok := <-d.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, rec)
+			default:
+				break drain
+			}
+		}
+		if err := d.insert(batch); err != nil {
+			d.failed.Add(int64(len(batch)))
+			if monitor.DebugMode {
+				fmt.Fprintf(os.Stderr, "DEBUG: database write of %d transitions failed: %v\n", len(batch), err)
+			}
+		}
+	}
+}
+
+// insert writes the batch in one transaction
+func (d *TransitionDB) insert(batch []monitor.TransitionRecord) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("INSERT INTO transitions (timestamp, unixnano, host, ip, transition, state) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, rec := range batch {
+		timestamp := time.Unix(0, rec.UnixNano).UTC().Format(time.RFC3339Nano)
+		if _, err := stmt.Exec(timestamp, rec.UnixNano, rec.Host, rec.Ip, rec.Transition, rec.State); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
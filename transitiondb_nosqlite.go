@@ -0,0 +1,21 @@
+//go:build !sqlite
+
+package main
+
+import (
+	"errors"
+	"github.com/babs/multiping/monitor"
+)
+
+// TransitionDB stands in for the SQLite recorder of -db, which is only
+// built with -tags sqlite: the SQLite engine adds about 6 MB to the binary.
+type TransitionDB struct{}
+
+// OpenTransitionDB fails, this build can't record to a database
+func OpenTransitionDB(path string) (*TransitionDB, error) {
+	return nil, errors.New("-db: mping was built without sqlite, rebuild with -tags sqlite")
+}
+
+func (d *TransitionDB) Send(rec monitor.TransitionRecord) {}
+
+func (d *TransitionDB) Close() {}
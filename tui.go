@@ -1,12 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
+	"net/http"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/babs/multiping/monitor"
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"os"
@@ -32,6 +39,7 @@ const (
 	SortByRTT
 	SortByLastSeen
 	SortByIP
+	SortByUptime
 )
 
 // UpdateRate represents the refresh rate
@@ -46,46 +54,159 @@ const (
 
 // TUIModel is the bubbletea model for the TUI
 type TUIModel struct {
-	ps             *PingService
-	repo           HostRepository
-	header         HeaderModel
-	footer         FooterModel
-	hostList       HostListModel
-	quitting       bool
-	transitionWriter *TransitionWriter
+	ps               *monitor.PingService
+	repo             monitor.HostRepository
+	header           HeaderModel
+	footer           FooterModel
+	hostList         HostListModel
+	quitting         bool
+	transitionWriter *monitor.TransitionWriter
 	editingHosts     bool
-	hostInput        string
+	hostInput        textarea.Model
 	statusMessage    string
-	statsCache       map[string]PWStats // cache stats per wrapper to avoid recalculation
-	statsCacheTime   time.Time          // when stats were last calculated
-	lastTickTime     time.Time          // when last tick happened
-	statusServer     *StatusServer      // optional web status server
+	statsCache       map[string]hostStats // cache stats per wrapper to avoid recalculation
+	statsCacheTime   time.Time            // when stats were last calculated
+	lastTickTime     time.Time            // when last tick happened
+	statusServer     *StatusServer        // optional web status server
+	detailScroll     int                  // first visible line of the detail view
+	emptyRevert      time.Duration        // reset the filter to All after matching nothing this long (0 = never)
+	emptySince       time.Time            // when the current filter started matching nothing
+	blurred          bool                 // terminal reported losing focus
+	inline           bool                 // render in the normal screen instead of the alternate screen
+	includeNetwork   bool                 // CIDRs typed in the host editor keep network/broadcast
+	sessionFile      string               // -session-file, empty if not given
+	viewStateFile    string               // view saved on quit, empty with -no-view-state
+	viewStateErr     error                // saving the view on quit failed, reported after the TUI
+	duration         time.Duration        // -duration, quit once it elapsed
+	bell             bool                 // -bell, ring when a host goes offline
+	bellRung         map[string]time.Time // when -bell last rang per host
+	bellPending      bool                 // a host went offline since the last tick
+	influx           *InfluxExporter      // fed the stats on every update, nil without -influx-url
+	listTop          int                  // screen line of the first list row, -1 while no rows are shown
+	height           int                  // terminal height
+	showHelp         bool                 // the help overlay (?) is shown
+	helpScroll       int                  // first visible line of the help overlay
+	sessionDirty     bool                 // hosts or hidden hosts changed since the -session-file was saved or loaded
+	confirmQuit      bool                 // asking "Quit? (y/n)" before quitting
+	movingColumns    bool                 // o was pressed, digits move columns instead of toggling them
 }
 
-func NewTUIModel(ps *PingService, repo HostRepository, tw *TransitionWriter, initialFilter FilterMode) *TUIModel {
+// bellInterval is how often -bell rings at most for the same host, so a
+// flapping host doesn't ring on every transition
+const bellInterval = 30 * time.Second
+
+// blurredTick is the UI tick and minimum stats interval while the terminal is
+// not focused. Terminals without focus reporting never blur.
+const blurredTick = time.Second
+
+// TUIOptions carries command line settings into the TUI
+type TUIOptions struct {
+	InitialFilter   FilterMode
+	WebAddr         string        // host:port of the status server, empty = disabled
+	LastReplyOnline bool          // show last reply age for online hosts too
+	StartupTimeout  time.Duration // max time to wait for all wrappers to start
+	WebToken        string        // enables and guards mutating web endpoints
+	WebUser         string        // basic auth for the status server (empty = none)
+	WebPass         string
+	WebCert         string // certificate and key to serve the status server over HTTPS
+	WebKey          string
+	EmptyRevert     time.Duration    // reset the filter to All after it matched nothing this long
+	ShowTarget      bool             // show the host as given next to resolved names
+	Widths          ColumnWidths     // preferred list column widths (zero value = defaults)
+	RTTBands        RTTBands         // RTT coloring thresholds (zero value = all green)
+	Inline          bool             // don't switch to the alternate screen
+	IncludeNetwork  bool             // keep network/broadcast addresses when expanding CIDRs
+	SessionFile     string           // saved with Ctrl+S, reloaded with Ctrl+O
+	ViewStateFile   string           // view restored at start and saved on quit (empty = don't)
+	Bell            bool             // ring the terminal bell when a host goes offline
+	JSONCase        string           // key casing of /json: snake or camel
+	Duration        time.Duration    // quit after this long (0 = run until quit)
+	Hidden          map[string]bool  // host specs hidden from the start
+	HostFileWatcher *HostFileWatcher // reloads the host file while running (nil = -hostfile-watch off)
+	Influx          InfluxOptions    // metrics exporter (empty URL = disabled)
+}
+
+func NewTUIModel(ps *monitor.PingService, repo monitor.HostRepository, tw *monitor.TransitionWriter, opts TUIOptions) *TUIModel {
+	initialFilter := opts.InitialFilter
 	if initialFilter != FilterOnline && initialFilter != FilterOffline && initialFilter != FilterSmart {
 		initialFilter = FilterSmart
 	}
 
 	hostList := NewHostListModel()
 	hostList.filterMode = initialFilter
+	hostList.lastReplyOnline = opts.LastReplyOnline
+	hostList.showTarget = opts.ShowTarget
+	if opts.Widths != (ColumnWidths{}) {
+		hostList.widths = opts.Widths
+	}
+	hostList.rttBands = opts.RTTBands
+	header := NewHeaderModel()
+	var statusMessage string
+	if opts.ViewStateFile != "" {
+		state, err := LoadViewState(opts.ViewStateFile)
+		switch {
+		case err == nil:
+			state.Apply(&hostList, &header, repo.GetAll())
+			// An initial filter given on the command line wins
+			if opts.InitialFilter == FilterOnline || opts.InitialFilter == FilterOffline {
+				hostList.filterMode = opts.InitialFilter
+			}
+		case !errors.Is(err, fs.ErrNotExist):
+			statusMessage = fmt.Sprintf("Saved view not restored: %v", err)
+		}
+	}
+	if len(opts.Hidden) > 0 {
+		// Hidden hosts of a loaded session replace the saved view's
+		hostList.hiddenHosts = hiddenWrapperKeys(repo.GetAll(), opts.Hidden)
+	}
+	header.filterMode = hostList.filterMode
+	header.sortMode = hostList.sortMode
+	header.sortReversed = hostList.sortReversed
+	footer := NewFooterModel()
+	footer.session = opts.SessionFile != ""
 
 	return &TUIModel{
 		ps:               ps,
 		repo:             repo,
-		header:           NewHeaderModel(),
-		footer:           NewFooterModel(),
+		header:           header,
+		statusMessage:    statusMessage,
+		footer:           footer,
 		hostList:         hostList,
 		transitionWriter: tw,
-		statsCache:       make(map[string]PWStats),
+		hostInput:        newHostEditor(),
+		statsCache:       make(map[string]hostStats),
 		statsCacheTime:   time.Time{},
 		lastTickTime:     time.Now(),
+		emptyRevert:      opts.EmptyRevert,
+		inline:           opts.Inline,
+		includeNetwork:   opts.IncludeNetwork,
+		sessionFile:      opts.SessionFile,
+		viewStateFile:    opts.ViewStateFile,
+		duration:         opts.Duration,
+		bell:             opts.Bell,
+		bellRung:         make(map[string]time.Time),
 	}
 }
 
 // tickMsg is sent every 100ms to update the display
 type tickMsg time.Time
 
+// setRateMsg changes the update rate from outside the TUI (web server)
+type setRateMsg UpdateRate
+
+// durationElapsedMsg ends a -duration session
+type durationElapsedMsg struct{}
+
+// hostFileReloadedMsg reports a reload of the watched host file
+type hostFileReloadedMsg HostFileReload
+
+// dnsResolvedMsg reports a reverse lookup requested from the detail view
+type dnsResolvedMsg struct {
+	ip      string
+	name    string
+	changed bool
+}
+
 // keyMap defines the keyboard shortcuts
 type keyMap struct {
 	Up          key.Binding
@@ -95,12 +216,31 @@ type keyMap struct {
 	Enter       key.Binding
 	Quit        key.Binding
 	FilterCycle key.Binding
+	FilterFlip  key.Binding
+	GroupCycle  key.Binding
 	SortCycle   key.Binding
+	SortReverse key.Binding
 	Escape      key.Binding
 	EditHosts   key.Binding
 	HideHost    key.Binding
 	ShowAll     key.Binding
 	CycleRate   key.Binding
+	TimeMode    key.Binding
+	Legend      key.Binding
+	ShowTarget  key.Binding
+	Heatmap     key.Binding
+	Pause       key.Binding
+	Export      key.Binding
+	Left        key.Binding
+	Right       key.Binding
+	SaveSession key.Binding
+	LoadSession key.Binding
+	ResolveDNS  key.Binding
+	Search      key.Binding
+	Top         key.Binding
+	Bottom      key.Binding
+	Help        key.Binding
+	MoveColumns key.Binding
 }
 
 var keys = keyMap{
@@ -132,10 +272,22 @@ var keys = keyMap{
 		key.WithKeys("f"),
 		key.WithHelp("f", "cycle filter"),
 	),
+	FilterFlip: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "invert filter"),
+	),
+	GroupCycle: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "cycle the host file groups shown, then all"),
+	),
 	SortCycle: key.NewBinding(
 		key.WithKeys("s"),
 		key.WithHelp("s", "cycle sort"),
 	),
+	SortReverse: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "reverse sort"),
+	),
 	Escape: key.NewBinding(
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "back"),
@@ -156,6 +308,70 @@ var keys = keyMap{
 		key.WithKeys("r"),
 		key.WithHelp("r", "cycle update rate"),
 	),
+	TimeMode: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "toggle absolute/relative time"),
+	),
+	Legend: key.NewBinding(
+		key.WithKeys("l"),
+		key.WithHelp("l", "toggle legend"),
+	),
+	ShowTarget: key.NewBinding(
+		key.WithKeys("h"),
+		key.WithHelp("h", "toggle host as given next to DNS name"),
+	),
+	Heatmap: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "toggle heatmap"),
+	),
+	Left: key.NewBinding(
+		key.WithKeys("left"),
+		key.WithHelp("←", "previous host (heatmap)"),
+	),
+	Right: key.NewBinding(
+		key.WithKeys("right"),
+		key.WithHelp("→", "next host (heatmap)"),
+	),
+	SaveSession: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "save session"),
+	),
+	LoadSession: key.NewBinding(
+		key.WithKeys("ctrl+o"),
+		key.WithHelp("ctrl+o", "reload session"),
+	),
+	Top: key.NewBinding(
+		key.WithKeys("g", "home"),
+		key.WithHelp("g", "first host"),
+	),
+	Bottom: key.NewBinding(
+		key.WithKeys("G", "end"),
+		key.WithHelp("G", "last host"),
+	),
+	Search: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "search"),
+	),
+	ResolveDNS: key.NewBinding(
+		key.WithKeys("D"),
+		key.WithHelp("D", "re-resolve DNS name (details)"),
+	),
+	Pause: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pause/resume probing"),
+	),
+	Export: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "export the list as CSV"),
+	),
+	MoveColumns: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "move columns: then 1-9 push that column left"),
+	),
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "show/close this help"),
+	),
 }
 
 // Styles
@@ -184,10 +400,27 @@ var (
 			Foreground(lipgloss.Color("#4ade80")).
 			Bold(true)
 
+	rttWarnStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#facc15")).
+			Bold(true)
+
+	degradedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#fbbf24")).
+			Bold(true)
+
+	lossyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#fb923c")).
+			Bold(true)
+
 	offlineStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#f87171")).
 			Bold(true)
 
+	alertStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#ffffff")).
+			Background(lipgloss.Color("#b91c1c")).
+			Bold(true)
+
 	helpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#9ca3af")).
 			MarginLeft(1)
@@ -206,25 +439,47 @@ var (
 			Foreground(lipgloss.Color("#4b5563"))
 )
 
-
 func (m *TUIModel) Init() tea.Cmd {
 	// Don't block in Init() - let first View() happen quickly
 	// Cache will be filled by first tick
-	return tea.Batch(
-		m.tickCmd(),
-		tea.EnterAltScreen,
-	)
+	cmds := []tea.Cmd{m.tickCmd()}
+	if !m.inline {
+		cmds = append(cmds, tea.EnterAltScreen)
+	}
+	if m.duration > 0 {
+		cmds = append(cmds, tea.Tick(m.duration, func(time.Time) tea.Msg {
+			return durationElapsedMsg{}
+		}))
+	}
+	return tea.Batch(cmds...)
 }
 
 // tickCmd returns a command that ticks every 100ms for UI updates
 func (m *TUIModel) tickCmd() tea.Cmd {
-	return tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg {
+	tick := 100 * time.Millisecond
+	if m.blurred {
+		tick = blurredTick
+	}
+	return tea.Tick(tick, func(t time.Time) tea.Msg {
 		return tickMsg(t)
 	})
 }
 
 func (m *TUIModel) getTickDuration() time.Duration {
-	switch m.header.updateRate {
+	if d := m.rateDuration(); !m.blurred || d > blurredTick {
+		return d
+	}
+	return blurredTick
+}
+
+// rateDuration is the stats interval selected with the rate key
+func (m *TUIModel) rateDuration() time.Duration {
+	return updateRateDuration(m.header.updateRate)
+}
+
+// updateRateDuration returns the stats interval of an update rate
+func updateRateDuration(rate UpdateRate) time.Duration {
+	switch rate {
 	case UpdateRate100ms:
 		return 100 * time.Millisecond
 	case UpdateRate1s:
@@ -238,38 +493,89 @@ func (m *TUIModel) getTickDuration() time.Duration {
 	}
 }
 
-// updateStatsCache updates the cached stats for all wrappers
-// This is called once per tick to avoid recalculating stats multiple times per frame
+// hostStats are a wrapper's stats as of the last updateStatsCache
+type hostStats struct {
+	wrapper monitor.PingWrapperInterface // a host removed and added again has a new wrapper under the same key
+	stats   *monitor.PWStats             // snapshot returned by CalcStats, never written
+}
+
+// updateStatsCache recomputes the stats of the monitored hosts. The cache is
+// rebuilt, so hosts removed by editing or reloading drop out of it.
 func (m *TUIModel) updateStatsCache() {
 	m.statsCacheTime = time.Now()
-	for _, wrapper := range m.repo.GetAll() {
-		stats := wrapper.CalcStats(2 * 1e9)
-		m.statsCache[wrapper.Host()] = stats
+	wrappers := m.repo.GetAll()
+	cache := make(map[string]hostStats, len(wrappers))
+	var points []string
+	for _, wrapper := range wrappers {
+		stats := wrapper.CalcStats(monitor.DefaultOfflineAfter)
+		if m.influx != nil {
+			points = append(points, influxPoint(wrapper, &stats))
+		}
+		if m.bell {
+			if prev, ok := m.statsCache[wrapper.Host()]; ok && prev.wrapper == wrapper && prev.stats.State && !stats.State {
+				m.ringBellFor(wrapper.Host(), m.statsCacheTime)
+			}
+		}
+		cache[wrapper.Host()] = hostStats{wrapper, &stats}
+	}
+	m.statsCache = cache
+	if m.influx != nil {
+		m.influx.Update(points)
+	}
+	for host := range m.bellRung {
+		if _, ok := cache[host]; !ok {
+			delete(m.bellRung, host)
+		}
+	}
+}
+
+// ringBellFor requests the bell for a host gone offline, unless it rang for
+// that host within bellInterval
+func (m *TUIModel) ringBellFor(host string, now time.Time) {
+	if last, ok := m.bellRung[host]; ok && now.Sub(last) < bellInterval {
+		return
 	}
+	m.bellRung[host] = now
+	m.bellPending = true
+}
+
+// ringBell writes the terminal bell. The renderer writes each frame at once,
+// so the bell lands between frames.
+func ringBell() tea.Msg {
+	os.Stdout.WriteString("\a")
+	return nil
 }
 
 // getCachedStats returns cached stats for a wrapper
-func (m *TUIModel) getCachedStats(wrapper PingWrapperInterface) PWStats {
-	if stats, ok := m.statsCache[wrapper.Host()]; ok {
-		return stats
+func (m *TUIModel) getCachedStats(wrapper monitor.PingWrapperInterface) monitor.PWStats {
+	if cached, ok := m.statsCache[wrapper.Host()]; ok && cached.wrapper == wrapper {
+		return cached.stats.Snapshot()
 	}
-	// Cache miss - return empty stats instead of calling CalcStats()
+	// Cache miss or a host replaced since the last update - return empty
+	// stats instead of calling CalcStats() or showing the previous host's
 	// This prevents blocking on first View() before cache is filled
-	return PWStats{
-		hrepr:  wrapper.Host(),
-		iprepr: wrapper.Host(),
-		state:  false,
-	}
+	return monitor.PlaceholderStats(wrapper.Host())
 }
 
 func (m *TUIModel) applyHostInput() {
-	raw := strings.TrimSpace(m.hostInput)
-	hosts := parseHostsInput(raw)
-	m.ps.ReplaceHosts(hosts)
+	raw := strings.TrimSpace(m.hostInput.Value())
+	hosts, err := parseHostsInput(raw, m.includeNetwork)
+	if err != nil {
+		// Keep the editor open to fix the range
+		m.statusMessage = err.Error()
+		return
+	}
+	if err := m.ps.ReplaceHosts(hosts); err != nil {
+		// Keep the editor open to fix the host
+		m.statusMessage = err.Error()
+		return
+	}
 	m.hostList.cursor = -1
 	m.hostList.scrollOffset = 0
 	m.hostList.filterMode = FilterAll
 	m.header.filterMode = FilterAll
+	m.hostList.group = ""
+	m.header.group = ""
 	m.footer.showDetails = false
 	if len(hosts) == 0 {
 		m.statusMessage = "Cleared hosts; no targets configured."
@@ -278,6 +584,154 @@ func (m *TUIModel) applyHostInput() {
 	}
 	m.hostList.cacheInvalidated = true
 	m.editingHosts = false
+	m.hostInput.Blur()
+	m.sessionChanged()
+}
+
+// sessionChanged marks the session as having changes to save, with a
+// -session-file only
+func (m *TUIModel) sessionChanged() {
+	if m.sessionFile != "" {
+		m.sessionDirty = true
+	}
+}
+
+// requestQuit quits right away unless a half-typed host list or unsaved
+// session changes would be lost, then it asks first
+func (m *TUIModel) requestQuit() tea.Cmd {
+	if !m.editingHosts && !m.sessionDirty {
+		return m.quit()
+	}
+	m.confirmQuit = true
+	m.footer.confirmQuit = true
+	return nil
+}
+
+// updateConfirmQuit handles the answer to "Quit? (y/n)": only y quits
+func (m *TUIModel) updateConfirmQuit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.confirmQuit = false
+	m.footer.confirmQuit = false
+	if msg.String() == "y" || msg.String() == "Y" {
+		return m, m.quit()
+	}
+	return m, nil
+}
+
+// quit stops probing and saves the view before ending the program
+func (m *TUIModel) quit() tea.Cmd {
+	m.quitting = true
+	if m.viewStateFile != "" {
+		m.viewStateErr = NewViewState(&m.hostList, m.header.updateRate).Save(m.viewStateFile)
+	}
+	m.ps.Stop()
+	return tea.Quit
+}
+
+// saveSession writes the host set with its hidden and expected state to
+// the -session-file
+func (m *TUIModel) saveSession() {
+	if m.sessionFile == "" {
+		m.statusMessage = "No session file, start with -session-file to save"
+		return
+	}
+	session := NewSession(m.ps.HostSpecs(), m.repo.GetAll(), m.hostList.hiddenHosts)
+	if err := session.Save(m.sessionFile); err != nil {
+		m.statusMessage = fmt.Sprintf("Saving session failed: %v", err)
+		return
+	}
+	m.sessionDirty = false
+	m.statusMessage = fmt.Sprintf("Saved session (%d hosts) to %s", len(session.Hosts), m.sessionFile)
+}
+
+// loadSession replaces the hosts and hidden state with the -session-file's
+func (m *TUIModel) loadSession() {
+	if m.sessionFile == "" {
+		m.statusMessage = "No session file, start with -session-file to load"
+		return
+	}
+	session, err := LoadSession(m.sessionFile)
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("Loading session failed: %v", err)
+		return
+	}
+	hosts, expect, alias, group, hidden := session.Specs()
+	if err := m.ps.ReplaceAnnotatedHosts(hosts, expect, alias, group); err != nil {
+		m.statusMessage = fmt.Sprintf("Loading session failed: %v", err)
+		return
+	}
+	m.hostList.hiddenHosts = hiddenWrapperKeys(m.repo.GetAll(), hidden)
+	m.hostList.cursor = -1
+	m.hostList.scrollOffset = 0
+	m.hostList.cacheInvalidated = true
+	m.footer.showDetails = false
+	m.sessionDirty = false
+	m.statusMessage = fmt.Sprintf("Loaded session (%d hosts) from %s", len(hosts), m.sessionFile)
+	m.pushStatusView()
+}
+
+// updateSearch handles keys while a search query is typed: the list is
+// filtered live, Enter keeps the query and Esc drops it
+func (m *TUIModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.setSearch("")
+	case tea.KeyEnter:
+	case tea.KeyCtrlC:
+		return m, m.quit()
+	case tea.KeyBackspace:
+		if r := []rune(m.header.search); len(r) > 0 {
+			m.setSearch(string(r[:len(r)-1]))
+		}
+		return m, nil
+	case tea.KeyRunes, tea.KeySpace:
+		m.setSearch(m.header.search + string(msg.Runes))
+		return m, nil
+	default:
+		return m, nil
+	}
+	m.header.searching = false
+	m.footer.searching = false
+	return m, nil
+}
+
+// setSearch applies a search query and moves the cursor to the first match
+func (m *TUIModel) setSearch(query string) {
+	m.header.search = query
+	m.hostList.search = strings.ToLower(query)
+	m.hostList.cacheInvalidated = true
+	m.hostList.scrollOffset = 0
+	m.hostList.cursor = -1
+	if query != "" && len(m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)) > 0 {
+		m.hostList.cursor = 0
+	}
+}
+
+// resolveSelectedCmd re-runs the reverse lookup for the host shown in the
+// detail view right away instead of waiting for the DNS updater's cycle
+func (m *TUIModel) resolveSelectedCmd() tea.Cmd {
+	if monitor.SkipDNS {
+		m.statusMessage = "DNS lookups are disabled (-no-dns)"
+		return nil
+	}
+	filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
+	if m.hostList.cursor < 0 || m.hostList.cursor >= len(filtered) {
+		return nil
+	}
+	wrapper := filtered[m.hostList.cursor]
+	if alias := wrapper.Stats().Alias; alias != "" {
+		m.statusMessage = fmt.Sprintf("DNS: %s is named %s by the host file", wrapper.Stats().IPRepr, alias)
+		return nil
+	}
+	m.statusMessage = fmt.Sprintf("DNS: resolving %s...", wrapper.Stats().IPRepr)
+	return func() tea.Msg {
+		changed := monitor.UpdateHostDisplayName(wrapper)
+		stats := wrapper.Stats()
+		name := stats.GetHostRepr()
+		if name == stats.IPRepr {
+			name = ""
+		}
+		return dnsResolvedMsg{ip: stats.IPRepr, name: name, changed: changed}
+	}
 }
 
 func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -286,7 +740,59 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.header.width = msg.Width
 		m.footer.width = msg.Width
 		m.hostList.width = msg.Width
+		m.height = msg.Height
 		m.hostList.height = msg.Height - 5 // Adjust for header/footer
+		if m.header.showLegend {
+			m.hostList.height -= legendLines
+		}
+		m.resizeHostEditor()
+		return m, nil
+
+	case setRateMsg:
+		m.header.updateRate = UpdateRate(msg)
+		m.statusMessage = fmt.Sprintf("Update rate set via web: %s", m.header.getUpdateRateString())
+		return m, nil
+
+	case hostFileReloadedMsg:
+		if msg.Err != nil {
+			m.statusMessage = fmt.Sprintf("Reloading host file failed, keeping the hosts: %v", msg.Err)
+			return m, nil
+		}
+		m.hostList.cursor = -1
+		m.hostList.scrollOffset = 0
+		m.hostList.cacheInvalidated = true
+		m.footer.showDetails = false
+		m.statusMessage = fmt.Sprintf("Reloaded host file (%d hosts)", msg.Hosts)
+		return m, nil
+
+	case durationElapsedMsg:
+		return m, m.quit()
+
+	case dnsResolvedMsg:
+		switch {
+		case msg.changed:
+			m.statusMessage = fmt.Sprintf("DNS: %s is now %s", msg.ip, msg.name)
+			m.updateStatsCache()
+			m.hostList.cacheInvalidated = true
+		case msg.name != "":
+			m.statusMessage = fmt.Sprintf("DNS: %s unchanged (%s)", msg.ip, msg.name)
+		default:
+			m.statusMessage = fmt.Sprintf("DNS: no name for %s", msg.ip)
+		}
+		return m, nil
+
+	case tea.BlurMsg:
+		m.blurred = true
+		return m, nil
+
+	case tea.FocusMsg:
+		m.blurred = false
+		// Refresh right away instead of waiting for the slow tick
+		m.lastTickTime = time.Time{}
+		return m, nil
+
+	case tea.MouseMsg:
+		m.handleMouse(msg)
 		return m, nil
 
 	case tickMsg:
@@ -301,91 +807,184 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.updateStatsCache()
 			m.lastTickTime = now
 			m.hostList.cacheInvalidated = true
+			m.checkEmptyFilter(now)
 		}
-		
+
 		// Update countdown in header
 		m.header.countdown = m.getRemainingTime()
 
+		if m.bellPending {
+			m.bellPending = false
+			return m, tea.Batch(m.tickCmd(), ringBell)
+		}
 		// Always continue UI ticker at 100ms
 		return m, m.tickCmd()
 
 	case tea.KeyMsg:
+		if m.confirmQuit {
+			return m.updateConfirmQuit(msg)
+		}
+		if m.showHelp {
+			return m.updateHelp(msg)
+		}
+		if m.movingColumns {
+			return m.updateMoveColumns(msg)
+		}
+		if m.header.searching {
+			return m.updateSearch(msg)
+		}
 		if m.editingHosts {
 			switch {
 			case key.Matches(msg, keys.Escape):
 				m.editingHosts = false
-				m.hostInput = ""
+				m.hostInput.Blur()
+				m.hostInput.Reset()
 				m.statusMessage = ""
 				return m, nil
-			case key.Matches(msg, keys.Enter):
+			case key.Matches(msg, keys.Enter), msg.Type == tea.KeyCtrlS:
 				m.applyHostInput()
 				return m, nil
-			}
-			// basic inline input editing
-			switch msg.Type {
-			case tea.KeyBackspace, tea.KeyDelete:
-				if len(m.hostInput) > 0 {
-					m.hostInput = m.hostInput[:len(m.hostInput)-1]
-				}
-				return m, nil
-			case tea.KeyCtrlL:
-				m.hostInput = ""
-				return m, nil
-			case tea.KeyCtrlN:
-				m.hostInput += "\n"
-				return m, nil
-			case tea.KeySpace:
-				m.hostInput += " "
-				return m, nil
-			case tea.KeyRunes:
-				m.hostInput += string(msg.Runes)
+			case msg.Type == tea.KeyCtrlL:
+				m.hostInput.Reset()
 				return m, nil
+			case msg.Type == tea.KeyCtrlC:
+				return m, m.requestQuit()
 			}
-			return m, nil
+			var cmd tea.Cmd
+			m.hostInput, cmd = m.hostInput.Update(msg)
+			return m, cmd
 		}
 
 		switch {
 		case key.Matches(msg, keys.Quit):
-			m.quitting = true
-			m.ps.Stop()
-			return m, tea.Quit
+			return m, m.requestQuit()
+
+		case key.Matches(msg, keys.MoveColumns):
+			m.movingColumns = true
+			m.footer.movingColumns = true
+			m.statusMessage = "Move columns: " + m.hostList.columnOrderString()
+			return m, nil
+
+		case key.Matches(msg, keys.Help):
+			m.showHelp = true
+			m.helpScroll = 0
+			return m, nil
 
 		case key.Matches(msg, keys.Escape):
 			if m.footer.showDetails {
 				m.footer.showDetails = false
+			} else if m.hostList.search != "" {
+				m.setSearch("")
 			}
 			return m, nil
 
+		case key.Matches(msg, keys.Search) && !m.footer.showDetails:
+			m.header.searching = true
+			m.footer.searching = true
+			return m, nil
+
+		case m.footer.showDetails && key.Matches(msg, keys.ResolveDNS):
+			return m, m.resolveSelectedCmd()
+
 		case key.Matches(msg, keys.Enter):
 			if m.hostList.cursor >= 0 {
 				m.footer.showDetails = !m.footer.showDetails
+				m.detailScroll = 0
 			}
 			return m, nil
 
 		case key.Matches(msg, keys.Up):
+			if m.footer.showDetails {
+				if m.detailScroll > 0 {
+					m.detailScroll--
+				}
+				return m, nil
+			}
 			filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
 			if len(filtered) > 0 {
 				if m.hostList.cursor < 0 {
 					m.hostList.cursor = 0
 				} else if m.hostList.cursor > 0 {
-					m.hostList.cursor--
+					m.hostList.cursor = max(m.hostList.cursor-m.hostList.rowStep(), 0)
 				}
 				m.hostList.adjustScroll()
 			}
 			return m, nil
 
 		case key.Matches(msg, keys.Down):
+			if m.footer.showDetails {
+				// Upper bound is clamped in renderDetailView once the content height is known
+				m.detailScroll++
+				return m, nil
+			}
 			filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
 			if len(filtered) > 0 {
 				if m.hostList.cursor < 0 {
 					m.hostList.cursor = 0
 				} else if m.hostList.cursor < len(filtered)-1 {
-					m.hostList.cursor++
+					m.hostList.cursor = min(m.hostList.cursor+m.hostList.rowStep(), len(filtered)-1)
 				}
 				m.hostList.adjustScroll()
 			}
 			return m, nil
 
+		case key.Matches(msg, keys.Top), key.Matches(msg, keys.Bottom):
+			top := key.Matches(msg, keys.Top)
+			if m.footer.showDetails {
+				// The bottom is clamped in renderDetailView
+				m.detailScroll = 0
+				if !top {
+					m.detailScroll = math.MaxInt32
+				}
+				return m, nil
+			}
+			filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
+			if len(filtered) > 0 {
+				m.hostList.cursor = 0
+				if !top {
+					m.hostList.cursor = len(filtered) - 1
+				}
+				m.hostList.adjustScroll()
+			}
+			return m, nil
+
+		case m.hostList.heatmap && !m.footer.showDetails && (key.Matches(msg, keys.Left) || key.Matches(msg, keys.Right)):
+			filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
+			if len(filtered) > 0 {
+				if key.Matches(msg, keys.Left) {
+					m.hostList.cursor = max(m.hostList.cursor-1, 0)
+				} else {
+					m.hostList.cursor = min(m.hostList.cursor+1, len(filtered)-1)
+				}
+				m.hostList.adjustScroll()
+			}
+			return m, nil
+
+		case key.Matches(msg, keys.Export):
+			m.exportCSV()
+			return m, nil
+
+		case key.Matches(msg, keys.Pause):
+			if m.ps.Paused() {
+				m.ps.Resume()
+				m.statusMessage = "Probing resumed"
+			} else {
+				m.ps.Pause()
+				m.statusMessage = "Probing paused, stats frozen; p resumes"
+			}
+			m.header.paused = m.ps.Paused()
+			return m, nil
+
+		case key.Matches(msg, keys.Heatmap):
+			m.hostList.heatmap = !m.hostList.heatmap
+			m.footer.heatmap = m.hostList.heatmap
+			if m.hostList.heatmap {
+				m.statusMessage = "Heatmap: one cell per host, green to red by RTT, gray when offline"
+			} else {
+				m.statusMessage = "List view"
+			}
+			return m, nil
+
 		case key.Matches(msg, keys.PageUp):
 			filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
 			if len(filtered) > 0 {
@@ -433,6 +1032,29 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.pushStatusView()
 			return m, nil
 
+		case key.Matches(msg, keys.FilterFlip):
+			m.hostList.filterMode = invertFilterMode(m.hostList.filterMode)
+			m.header.filterMode = m.hostList.filterMode
+			m.hostList.cursor = -1
+			m.hostList.scrollOffset = 0
+			m.hostList.cacheInvalidated = true
+			m.statusMessage = fmt.Sprintf("Filter: %s", filterModeName(m.hostList.filterMode))
+			m.pushStatusView()
+			return m, nil
+
+		case key.Matches(msg, keys.GroupCycle):
+			group := nextGroup(m.hostList.group, m.repo.GetAll())
+			if group == "" && m.hostList.group == "" {
+				m.statusMessage = "No groups, declare them in the host file with [name] or group=name"
+				return m, nil
+			}
+			m.hostList.group = group
+			m.header.group = group
+			m.hostList.cursor = -1
+			m.hostList.scrollOffset = 0
+			m.hostList.cacheInvalidated = true
+			return m, nil
+
 		case key.Matches(msg, keys.SortCycle):
 			m.hostList.sortMode = nextSortMode(m.hostList.sortMode)
 			m.header.sortMode = m.hostList.sortMode
@@ -440,12 +1062,48 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.pushStatusView()
 			return m, nil
 
+		case key.Matches(msg, keys.SortReverse):
+			m.hostList.sortReversed = !m.hostList.sortReversed
+			m.header.sortReversed = m.hostList.sortReversed
+			m.hostList.cacheInvalidated = true
+			m.pushStatusView()
+			return m, nil
+
 		case key.Matches(msg, keys.CycleRate):
 			m.header.updateRate = nextUpdateRate(m.header.updateRate)
 			m.statusMessage = fmt.Sprintf("Update rate: %s", m.header.getUpdateRateString())
 			// No need to restart any tickers - the time-based calculation handles everything
 			return m, nil
 
+		case key.Matches(msg, keys.TimeMode):
+			m.hostList.absoluteTime = !m.hostList.absoluteTime
+			if m.hostList.absoluteTime {
+				m.statusMessage = "Timestamps: absolute"
+			} else {
+				m.statusMessage = "Timestamps: relative"
+			}
+			m.pushStatusView()
+			return m, nil
+
+		case key.Matches(msg, keys.ShowTarget):
+			m.hostList.showTarget = !m.hostList.showTarget
+			if m.hostList.showTarget {
+				m.statusMessage = "Names: DNS name (host as given)"
+			} else {
+				m.statusMessage = "Names: DNS name only"
+			}
+			return m, nil
+
+		case key.Matches(msg, keys.Legend):
+			m.header.showLegend = !m.header.showLegend
+			if m.header.showLegend {
+				m.hostList.height -= legendLines
+			} else {
+				m.hostList.height += legendLines
+			}
+			m.hostList.adjustScroll()
+			return m, nil
+
 		case key.Matches(msg, keys.HideHost):
 			if m.hostList.cursor >= 0 && !m.footer.showDetails {
 				filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
@@ -460,6 +1118,7 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.hostList.adjustScroll()
 					m.hostList.cacheInvalidated = true
 					m.pushStatusView()
+					m.sessionChanged()
 				}
 			}
 			return m, nil
@@ -469,6 +1128,7 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				count := len(m.hostList.hiddenHosts)
 				m.hostList.hiddenHosts = make(map[string]bool)
 				m.statusMessage = fmt.Sprintf("Showing all hosts (%d unhidden)", count)
+				m.sessionChanged()
 			} else {
 				m.statusMessage = "No hidden hosts"
 			}
@@ -476,22 +1136,24 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.pushStatusView()
 			return m, nil
 
+		case key.Matches(msg, keys.SaveSession):
+			m.saveSession()
+			return m, nil
+
+		case key.Matches(msg, keys.LoadSession):
+			m.loadSession()
+			return m, nil
+
 		case key.Matches(msg, keys.EditHosts):
 			m.editingHosts = true
 			m.statusMessage = "Edit hosts: one per line, Enter=apply, Esc=cancel, Ctrl+L=clear, Ctrl+N=new line."
-			var b strings.Builder
-			for i, w := range m.repo.GetAll() {
-				if i > 0 {
-					b.WriteString("\n")
-				}
-				b.WriteString(w.Host())
-			}
-			m.hostInput = b.String()
-			return m, nil
+			m.resizeHostEditor()
+			m.hostInput.SetValue(strings.Join(m.ps.HostSpecs(), "\n"))
+			return m, m.hostInput.Focus()
 
 		default:
-			// Handle number keys 1-6 for column toggling
-			if len(msg.String()) == 1 && msg.String() >= "1" && msg.String() <= "6" {
+			// Handle number keys 1-9 for column toggling
+			if len(msg.String()) == 1 && msg.String() >= "1" && msg.String() <= "9" {
 				colNum := int(msg.String()[0] - '0')
 				m.hostList.visibleColumns[colNum] = !m.hostList.visibleColumns[colNum]
 				colName := m.hostList.getColumnName(colNum)
@@ -509,12 +1171,54 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// checkEmptyFilter reverts to FilterAll once the active filter has matched
+// no host for emptyRevert, so an empty screen isn't mistaken for a failure.
+func (m *TUIModel) checkEmptyFilter(now time.Time) {
+	if m.emptyRevert <= 0 || m.hostList.filterMode == FilterAll {
+		m.emptySince = time.Time{}
+		return
+	}
+	all := m.repo.GetAll()
+	if len(all) == 0 || len(m.hostList.getFilteredWrappers(all, m.getCachedStats)) > 0 {
+		m.emptySince = time.Time{}
+		return
+	}
+	if m.emptySince.IsZero() {
+		m.emptySince = now
+		return
+	}
+	if now.Sub(m.emptySince) < m.emptyRevert {
+		return
+	}
+	m.statusMessage = fmt.Sprintf("Filter %s matched no host for %s, showing all", filterModeName(m.hostList.filterMode), m.emptyRevert)
+	m.hostList.filterMode = FilterAll
+	m.header.filterMode = FilterAll
+	m.hostList.cursor = -1
+	m.hostList.scrollOffset = 0
+	m.hostList.cacheInvalidated = true
+	m.emptySince = time.Time{}
+	m.pushStatusView()
+}
+
 func (m *TUIModel) View() string {
 	if m.quitting {
 		return "Goodbye!\n"
 	}
 
 	var s strings.Builder
+	m.listTop = -1
+
+	if m.showHelp {
+		return m.renderHelp()
+	}
+
+	all := m.repo.GetAll()
+	m.hostList.totalHosts = len(all)
+	m.countExpectations(all)
+	m.markStale()
+	if m.header.searching || m.header.search != "" {
+		m.header.matches = len(m.hostList.getFilteredWrappers(all, m.getCachedStats))
+	}
 
 	// Header
 	s.WriteString(m.header.View())
@@ -526,18 +1230,28 @@ func (m *TUIModel) View() string {
 
 	if m.editingHosts {
 		s.WriteString(m.renderHostInput())
+		if m.confirmQuit {
+			s.WriteString(m.footer.View())
+		}
 		return s.String()
 	}
 
 	// Get filtered and sorted wrappers
-	filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
+	filtered := m.hostList.getFilteredWrappers(all, m.getCachedStats)
 
 	if m.footer.showDetails && m.hostList.cursor >= 0 && m.hostList.cursor < len(filtered) {
 		// Show detail view
 		s.WriteString(m.renderDetailView(filtered[m.hostList.cursor]))
 	} else {
 		// Show list view
-		s.WriteString(m.hostList.renderListView(filtered, m.getCachedStats))
+		if m.hostList.heatmap && len(filtered) > 0 {
+			s.WriteString(m.hostList.renderHeatmap(filtered, m.getCachedStats))
+		} else {
+			if len(filtered) > 0 {
+				m.listTop = strings.Count(s.String(), "\n") + listHeaderLines
+			}
+			s.WriteString(m.hostList.renderListView(filtered, m.getCachedStats))
+		}
 	}
 
 	// Footer
@@ -546,51 +1260,182 @@ func (m *TUIModel) View() string {
 	return s.String()
 }
 
+// countExpectations updates the header's expect= summary
+func (m *TUIModel) countExpectations(all []monitor.PingWrapperInterface) {
+	m.header.expected, m.header.alerts = 0, 0
+	for _, w := range all {
+		stats := m.getCachedStats(w)
+		if stats.Expect == "" {
+			continue
+		}
+		m.header.expected++
+		if stats.Alert() {
+			m.header.alerts++
+		}
+	}
+}
+
 func (m *TUIModel) renderHostInput() string {
 	var b strings.Builder
 	b.WriteString("Edit hosts (one per line, CIDR allowed):\n")
-	b.WriteString("Ctrl+L: clear all │ Ctrl+N: new line │ enter: apply │ esc: cancel\n\n")
-	b.WriteString("hosts>\n")
-	b.WriteString(m.hostInput)
-	b.WriteString("█")
+	b.WriteString("arrows: move │ Ctrl+L: clear all │ Ctrl+N: new line │ enter/Ctrl+S: apply │ esc: cancel\n\n")
+	b.WriteString(m.hostInput.View())
 	b.WriteString("\n\n")
 	return b.String()
 }
 
-func (m *TUIModel) renderDetailView(wrapper PingWrapperInterface) string {
+// newHostEditor returns the multi-line host editor. Enter applies the list,
+// so new lines are inserted with Ctrl+N.
+func newHostEditor() textarea.Model {
+	ta := textarea.New()
+	ta.Prompt = "│ "
+	ta.ShowLineNumbers = true
+	ta.CharLimit = 0
+	ta.MaxHeight = 0
+	ta.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("ctrl+n"))
+	return ta
+}
+
+// resizeHostEditor fits the host editor below the header, status line and
+// editor help.
+func (m *TUIModel) resizeHostEditor() {
+	if m.header.width > 0 {
+		m.hostInput.SetWidth(m.header.width)
+	}
+	m.hostInput.SetHeight(max(m.hostList.height-4, 3))
+}
+
+func (m *TUIModel) renderDetailView(wrapper monitor.PingWrapperInterface) string {
 	stats := m.getCachedStats(wrapper)
-	isOnline := stats.state && stats.error_message == ""
+	isOnline := stats.State && stats.ErrorMessage == ""
+	now := time.Now().UnixNano()
+	abs := m.hostList.absoluteTime
 
 	var details strings.Builder
 	details.WriteString(fmt.Sprintf("Host: %s\n", wrapper.Host()))
-	details.WriteString(fmt.Sprintf("IP: %s\n\n", stats.iprepr))
+	if name := stats.GetHostRepr(); m.hostList.showTarget && name != "" && name != stats.Target {
+		details.WriteString(fmt.Sprintf("Name: %s\n", name))
+	}
+	details.WriteString(fmt.Sprintf("IP: %s\n", stats.IPRepr))
+	if stats.Group != "" {
+		details.WriteString(fmt.Sprintf("Group: %s\n", stats.Group))
+	}
+	details.WriteString("\n")
 
 	if isOnline {
 		details.WriteString(onlineStyle.Render("Status: ONLINE ✓"))
 		details.WriteString("\n\n")
-		details.WriteString(accentStyle.Render(fmt.Sprintf("Last RTT: %s\n", stats.lastrtt_as_string)))
-		details.WriteString(accentStyle.Render(fmt.Sprintf("Last Received: %s ago\n", time.Duration(stats.last_seen_nano).Round(time.Millisecond))))
-		if stats.last_loss_nano > 0 {
+		details.WriteString(accentStyle.Render(fmt.Sprintf("Last RTT: %s\n", stats.LastRTTString)))
+		details.WriteString(accentStyle.Render(fmt.Sprintf("Last Received: %s\n", formatTimestamp(stats.LastRecv, now, time.Millisecond, abs))))
+		if rtts := stats.RecentRTTs(sparklineSamples); len(rtts) >= sparklineMinSamples {
+			details.WriteString(fmt.Sprintf("RTT trend: %s %s-%s\n", sparkline(rtts), monitor.Round(slices.Min(rtts), 2), monitor.Round(slices.Max(rtts), 2)))
+		}
+		if stats.LastLossNano > 0 {
 			details.WriteString("\n")
-			details.WriteString(fmt.Sprintf("Last Loss: %s\n", time.Unix(0, stats.last_loss_nano).Format("2006-01-02 15:04:05")))
-			details.WriteString(fmt.Sprintf("Loss Duration: %s\n", time.Duration(stats.last_loss_duration).Round(time.Second)))
+			details.WriteString(fmt.Sprintf("Last Loss: %s\n", time.Unix(0, stats.LastLossNano).Format("2006-01-02 15:04:05")))
+			details.WriteString(fmt.Sprintf("Loss Duration: %s\n", time.Duration(stats.LastLossDuration).Round(time.Second)))
 		}
+	} else if stats.Degraded && stats.ErrorMessage == "" {
+		details.WriteString(degradedStyle.Render("Status: DEGRADED ~"))
+		details.WriteString("\n\n")
+		details.WriteString(accentStyle.Render(fmt.Sprintf("Last RTT: %s (limit %s)\n", stats.LastRTTString, stats.OnlineMaxRTT)))
+		details.WriteString(accentStyle.Render(fmt.Sprintf("Last Received: %s\n", formatTimestamp(stats.LastRecv, now, time.Millisecond, abs))))
 	} else {
 		details.WriteString(offlineStyle.Render("Status: OFFLINE ✗"))
 		details.WriteString("\n\n")
-		if stats.error_message != "" {
-			details.WriteString(fmt.Sprintf("Error: %s\n", stats.error_message))
+		if stats.ErrorMessage != "" {
+			details.WriteString(fmt.Sprintf("Error: %s\n", stats.ErrorMessage))
 		}
-		if stats.lastrecv == 0 {
+		if stats.LastRecv == 0 {
 			details.WriteString("Never received a reply\n")
 		} else {
-			details.WriteString(fmt.Sprintf("Last seen: %s ago\n", time.Duration(stats.last_seen_nano).Round(time.Second)))
+			details.WriteString(fmt.Sprintf("Last seen: %s\n", formatTimestamp(stats.LastRecv, now, time.Second, abs)))
 		}
 	}
 
-	details.WriteString(fmt.Sprintf("\nOnline time: %s\n", stats.OnlineUptime(time.Now().UnixNano()).Round(time.Second)))
+	if stats.Alert() {
+		details.WriteString("\n" + alertStyle.Render(fmt.Sprintf("ALERT: expected %s", stats.Expect)) + "\n")
+	} else if stats.Expect != "" {
+		details.WriteString(fmt.Sprintf("\nExpected: %s (met)\n", stats.Expect))
+	}
 
-	return detailStyle.Render(details.String())
+	details.WriteString(fmt.Sprintf("\nOnline time: %s", stats.OnlineUptime(now).Round(time.Second)))
+	if pct, ok := stats.UptimePercent(now); ok {
+		details.WriteString(fmt.Sprintf(" (%.1f%%)", pct))
+	}
+	details.WriteString("\n")
+	if strings.HasPrefix(stats.Target, "http://") || strings.HasPrefix(stats.Target, "https://") {
+		if stats.HTTPStatus > 0 {
+			details.WriteString(fmt.Sprintf("HTTP status: %d %s\n", stats.HTTPStatus, http.StatusText(stats.HTTPStatus)))
+		} else {
+			details.WriteString("HTTP status: no response\n")
+		}
+	}
+	if stats.DNSName != "" {
+		result := stats.DNSResult
+		if result == "" {
+			result = "-"
+		}
+		details.WriteString(fmt.Sprintf("DNS query: A %s -> %s\n", stats.DNSName, result))
+	}
+	if n := stats.RTTSampleCount(); n > 0 {
+		details.WriteString(fmt.Sprintf("RTT samples: %d over the last %s\n", n, stats.RTTSampleSpan(now).Round(time.Second)))
+		minRTT, avg, maxRTT, stddev := stats.RTTStats()
+		details.WriteString(fmt.Sprintf("RTT min/avg/max/stddev: %s / %s / %s / %s\n", monitor.Round(minRTT, 2), monitor.Round(avg, 2), monitor.Round(maxRTT, 2), monitor.Round(stddev, 2)))
+		if stats.State || stats.Degraded {
+			details.WriteString(fmt.Sprintf("Jitter: %s\n", monitor.Round(stats.Jitter, 2)))
+		}
+	}
+	if stats.PacketsSent > 0 {
+		details.WriteString(fmt.Sprintf("Loss: %.1f%% (%d/%d replies)\n", stats.LossPercent(), stats.PacketsRecv, stats.PacketsSent))
+		if stats.LossWindow > 0 {
+			details.WriteString(fmt.Sprintf("Loss last %s: %.1f%%\n", stats.LossWindow, stats.WindowLossPercent()))
+		}
+	}
+	if stats.MaxMissStreak > 0 {
+		details.WriteString(fmt.Sprintf("Worst streak: %d misses\n", stats.MaxMissStreak))
+	}
+	if stats.MaxRTT > 0 {
+		details.WriteString(fmt.Sprintf("Replies over max RTT (%s): %d\n", stats.MaxRTT, stats.OverMaxRTT))
+	}
+	if stats.SendRetries > 0 {
+		details.WriteString(fmt.Sprintf("Send retries (transient errors): %d\n", stats.SendRetries))
+	}
+	if stats.SeqTracking {
+		details.WriteString(fmt.Sprintf("Sequence: %d skipped │ %d reordered │ %d duplicate\n", stats.SeqGaps, stats.Reorders, stats.DupReplies))
+	}
+
+	return detailStyle.Render(m.scrollDetailContent(details.String()))
+}
+
+// scrollDetailContent cuts the detail content to the rows available on screen,
+// starting at detailScroll, and appends a scroll indicator when content is cut.
+func (m *TUIModel) scrollDetailContent(content string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+
+	// height - border(2) - padding(2) - status message(2)
+	maxLines := m.hostList.height - 6
+	if m.hostList.height <= 0 || len(lines) <= maxLines {
+		m.detailScroll = 0
+		return content
+	}
+	// Keep one row for the scroll indicator
+	maxLines--
+	if maxLines < 1 {
+		maxLines = 1
+	}
+
+	if m.detailScroll > len(lines)-maxLines {
+		m.detailScroll = len(lines) - maxLines
+	}
+	if m.detailScroll < 0 {
+		m.detailScroll = 0
+	}
+
+	end := m.detailScroll + maxLines
+	visible := append([]string{}, lines[m.detailScroll:end]...)
+	visible = append(visible, helpStyle.Render(fmt.Sprintf("[%d-%d/%d] ↑↓ scroll", m.detailScroll+1, end, len(lines))))
+	return strings.Join(visible, "\n")
 }
 
 func (m *TUIModel) pushStatusView() {
@@ -598,13 +1443,37 @@ func (m *TUIModel) pushStatusView() {
 		return
 	}
 	m.statusServer.UpdateView(ServerView{
-		Filter: m.hostList.filterMode,
-		Sort:   m.hostList.sortMode,
-		Hidden: cloneHiddenHosts(m.hostList.hiddenHosts),
-		Cols:   visibleColumnsList(m.hostList.visibleColumns),
+		Filter:  m.hostList.filterMode,
+		Sort:    m.hostList.sortMode,
+		Reverse: m.hostList.sortReversed,
+		Hidden:  cloneHiddenHosts(m.hostList.hiddenHosts),
+		Cols:    m.hostList.orderedColumns(),
+		AbsTime: m.hostList.absoluteTime,
 	})
 }
 
+// The detail view's RTT sparkline covers the latest replies once there are
+// enough of them to show a trend
+const (
+	sparklineSamples    = 60
+	sparklineMinSamples = 5
+)
+
+// staleAfter is how old the shown stats may get before they are marked:
+// two probe intervals, after which they likely differ from the latest probes
+const staleAfter = 2 * monitor.DefaultProbeInterval
+
+// markStale dims the list and shows the stats' age in the header while
+// the cached stats are older than staleAfter, e.g. during a 30s countdown
+func (m *TUIModel) markStale() {
+	age := time.Since(m.statsCacheTime)
+	if m.statsCacheTime.IsZero() || age <= staleAfter {
+		age = 0
+	}
+	m.header.staleAge = age
+	m.hostList.stale = age > 0
+}
+
 // getRemainingTime returns a countdown string for 5s and 30s rates
 func (m *TUIModel) getRemainingTime() string {
 	// Only show countdown for 5s and 30s rates
@@ -631,7 +1500,7 @@ func (m *TUIModel) getRemainingTime() string {
 }
 
 // RunTUI starts the TUI interface with an initial filter mode applied
-func RunTUI(ps *PingService, repo HostRepository, tw *TransitionWriter, initialFilter FilterMode, webPort int) (finalErr error) {
+func RunTUI(ps *monitor.PingService, repo monitor.HostRepository, tw *monitor.TransitionWriter, opts TUIOptions) (finalErr error) {
 	// Early panic protection before any terminal manipulation
 	defer func() {
 		if r := recover(); r != nil {
@@ -644,7 +1513,7 @@ func RunTUI(ps *PingService, repo HostRepository, tw *TransitionWriter, initialF
 		}
 	}()
 
-	if DebugMode {
+	if monitor.DebugMode {
 		fmt.Fprintf(os.Stderr, "DEBUG: Starting wrapper initialization (this may take a moment for large subnets)...\n")
 	}
 
@@ -669,36 +1538,73 @@ func RunTUI(ps *PingService, repo HostRepository, tw *TransitionWriter, initialF
 		ps.Start()
 	}()
 
+	startupTimeout := opts.StartupTimeout
+	if startupTimeout <= 0 {
+		startupTimeout = 60 * time.Second
+	}
+	timeout := time.After(startupTimeout)
+	total := len(repo.GetAll())
+
+	// Progress is only shown if startup takes noticeable time
+	progress := time.NewTicker(250 * time.Millisecond)
+	defer progress.Stop()
+	shownProgress := false
+	startBegin := time.Now()
+
 	// Wait for startup with timeout and interrupt support
-	select {
-	case <-startDone:
-		if DebugMode {
-			fmt.Fprintf(os.Stderr, "DEBUG: All wrappers started, launching TUI...\n")
-		}
-	case err := <-startErr:
-		return fmt.Errorf("error starting wrappers: %w", err)
-	case <-sigChan:
-		fmt.Fprintf(os.Stderr, "\nInterrupted during startup, cleaning up...\n")
-		ps.Stop()
-		return fmt.Errorf("interrupted by user")
-	case <-time.After(60 * time.Second):
-		ps.Stop()
-		return fmt.Errorf("timeout waiting for wrappers to start (60s)")
-	}
-
-	model := NewTUIModel(ps, repo, tw, initialFilter)
+waitStartup:
+	for {
+		select {
+		case <-startDone:
+			if shownProgress {
+				fmt.Fprintf(os.Stderr, "\rStarted %d/%d wrappers.          \n", total, total)
+			}
+			if monitor.DebugMode {
+				fmt.Fprintf(os.Stderr, "DEBUG: All wrappers started, launching TUI...\n")
+			}
+			break waitStartup
+		case err := <-startErr:
+			return fmt.Errorf("error starting wrappers: %w", err)
+		case <-sigChan:
+			fmt.Fprintf(os.Stderr, "\nInterrupted during startup, cleaning up...\n")
+			ps.Stop()
+			return fmt.Errorf("interrupted by user")
+		case <-progress.C:
+			if time.Since(startBegin) >= 500*time.Millisecond {
+				fmt.Fprintf(os.Stderr, "\rStarting %d/%d wrappers...", ps.StartProgress(), total)
+				shownProgress = true
+			}
+		case <-timeout:
+			ps.Stop()
+			return fmt.Errorf("timeout waiting for wrappers to start (%s, %d/%d started); raise it with -startup-timeout", startupTimeout, ps.StartProgress(), total)
+		}
+	}
+
+	model := NewTUIModel(ps, repo, tw, opts)
 	var statusServer *StatusServer
-	if webPort > 0 {
+	if opts.WebAddr != "" {
 		initialView := ServerView{
-			Filter: model.hostList.filterMode,
-			Sort:   model.hostList.sortMode,
-			Hidden: cloneHiddenHosts(model.hostList.hiddenHosts),
-			Cols:   visibleColumnsList(model.hostList.visibleColumns),
+			Filter:  model.hostList.filterMode,
+			Sort:    model.hostList.sortMode,
+			Reverse: model.hostList.sortReversed,
+			Hidden:  cloneHiddenHosts(model.hostList.hiddenHosts),
+			Cols:    model.hostList.orderedColumns(),
 		}
 		var err error
-		statusServer, err = StartStatusServer(repo, model.getCachedStats, initialView, webPort)
+		statusServer, err = StartStatusServer(repo, model.getCachedStats, initialView, StatusServerOptions{
+			Addr:      opts.WebAddr,
+			Token:     opts.WebToken,
+			JSONCamel: opts.JSONCase == "camel",
+			User:      opts.WebUser,
+			Password:  opts.WebPass,
+			CertFile:  opts.WebCert,
+			KeyFile:   opts.WebKey,
+			RTTBands:  opts.RTTBands,
+		})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to start status server on port %d: %v\n", webPort, err)
+			fmt.Fprintf(os.Stderr, "failed to start status server on %s: %v\n", opts.WebAddr, err)
+			// stderr is hidden by the alternate screen
+			model.statusMessage = fmt.Sprintf("Status server not started: %v", err)
 		} else {
 			model.statusServer = statusServer
 		}
@@ -708,11 +1614,35 @@ func RunTUI(ps *PingService, repo HostRepository, tw *TransitionWriter, initialF
 	if statusServer != nil {
 		defer statusServer.Stop()
 	}
+	if opts.Influx.URL != "" {
+		exporter, err := StartInfluxExporter(opts.Influx)
+		if err != nil {
+			return fmt.Errorf("starting the influx exporter: %w", err)
+		}
+		defer exporter.Stop()
+		model.influx = exporter
+	}
+
+	progOpts := []tea.ProgramOption{tea.WithReportFocus()}
+	// Inline, the scrollback must stay selectable and scrollable by the
+	// terminal, and rows aren't at known screen positions
+	if !opts.Inline {
+		progOpts = append(progOpts, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(model, progOpts...)
+	if statusServer != nil {
+		statusServer.SetRateHandler(func(rate UpdateRate) {
+			p.Send(setRateMsg(rate))
+		})
+	}
 
-	p := tea.NewProgram(
-		model,
-		tea.WithAltScreen(),
-	)
+	if opts.HostFileWatcher != nil {
+		opts.HostFileWatcher.SetReloadHandler(func(r HostFileReload) {
+			p.Send(hostFileReloadedMsg(r))
+		})
+		opts.HostFileWatcher.Start()
+		defer opts.HostFileWatcher.Stop()
+	}
 
 	// Additional panic protection for bubbletea Run
 	defer func() {
@@ -727,5 +1657,8 @@ func RunTUI(ps *PingService, repo HostRepository, tw *TransitionWriter, initialF
 	}()
 
 	_, err := p.Run()
+	if model.viewStateErr != nil {
+		fmt.Fprintf(os.Stderr, "Saving the view to %s failed: %v\n", model.viewStateFile, model.viewStateErr)
+	}
 	return err
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultColumnOrder is the display order of the list columns 1-9 until
+// they are moved with o
+func defaultColumnOrder() []int {
+	return []int{1, 2, 3, 4, 5, 6, 7, 8, 9}
+}
+
+// isColumnOrder reports whether order holds each column 1-9 exactly once
+func isColumnOrder(order []int) bool {
+	sorted := slices.Sorted(slices.Values(order))
+	return slices.Equal(sorted, defaultColumnOrder())
+}
+
+// orderedColumns returns the visible columns in display order
+func (m *HostListModel) orderedColumns() []int {
+	var cols []int
+	for _, c := range m.columnOrder {
+		if m.visibleColumns[c] {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// moveColumnLeft swaps a visible column with the visible column before it.
+// The first column moves to the end, so repeating it reaches any position.
+// Hidden columns keep their place and can't be moved.
+func (m *HostListModel) moveColumnLeft(col int) bool {
+	cols := m.orderedColumns()
+	i := slices.Index(cols, col)
+	if i < 0 {
+		return false
+	}
+	from := slices.Index(m.columnOrder, col)
+	if i == 0 {
+		m.columnOrder = append(slices.Delete(m.columnOrder, from, from+1), col)
+		return true
+	}
+	to := slices.Index(m.columnOrder, cols[i-1])
+	m.columnOrder[from], m.columnOrder[to] = m.columnOrder[to], m.columnOrder[from]
+	return true
+}
+
+// columnOrderString lists the visible columns by name in display order
+func (m *HostListModel) columnOrderString() string {
+	var names []string
+	for _, c := range m.orderedColumns() {
+		names = append(names, m.getColumnName(c))
+	}
+	return strings.Join(names, " │ ")
+}
+
+// updateMoveColumns handles keys after o: 1-9 push that column one place to
+// the left, q still quits and any other key ends moving columns
+func (m *TUIModel) updateMoveColumns(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if k := msg.String(); len(k) == 1 && k >= "1" && k <= "9" {
+		col := int(k[0] - '0')
+		if !m.hostList.moveColumnLeft(col) {
+			m.statusMessage = fmt.Sprintf("Column %d (%s) is hidden, show it with %d first", col, m.hostList.getColumnName(col), col)
+			return m, nil
+		}
+		m.statusMessage = "Columns: " + m.hostList.columnOrderString()
+		m.pushStatusView()
+		return m, nil
+	}
+	m.movingColumns = false
+	m.footer.movingColumns = false
+	m.statusMessage = "Columns: " + m.hostList.columnOrderString()
+	if key.Matches(msg, keys.Quit) {
+		return m, m.requestQuit()
+	}
+	return m, nil
+}
@@ -3,19 +3,34 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/babs/multiping/monitor"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 // HeaderModel handles the top bar
 type HeaderModel struct {
-	width      int
-	filterMode FilterMode
-	sortMode   SortMode
-	updateRate UpdateRate
-	countdown  string
+	width        int
+	filterMode   FilterMode
+	sortMode     SortMode
+	sortReversed bool
+	updateRate   UpdateRate
+	countdown    string
+	staleAge     time.Duration // age of the shown stats once over staleAfter
+	paused       bool          // probing is paused (p)
+	search       string        // active search query
+	searching    bool          // search query is being typed
+	matches      int           // hosts shown with the current filter and search
+	showLegend   bool
+	expected     int    // hosts with an expect= annotation
+	alerts       int    // of those, hosts not in their expected state
+	group        string // group filter (c), empty = all
 }
 
+// legendLines is the screen height taken by the legend when shown
+const legendLines = 2
+
 func NewHeaderModel() HeaderModel {
 	return HeaderModel{
 		updateRate: UpdateRate100ms,
@@ -36,21 +51,70 @@ func (m HeaderModel) View() string {
 	s.WriteString("\n")
 
 	filterText := fmt.Sprintf("Filter: %s", m.getFilterModeString())
-	sortText := fmt.Sprintf("Sort: %s", m.getSortModeString())
+	if m.group != "" {
+		filterText += fmt.Sprintf(" │ Group: %s", m.group)
+	}
+	sortText := fmt.Sprintf("Sort: %s ↑", m.getSortModeString())
+	if m.sortReversed {
+		sortText = fmt.Sprintf("Sort: %s ↓", m.getSortModeString())
+	}
 	rateText := fmt.Sprintf("Rate: %s", m.getUpdateRateString())
 
 	if m.countdown != "" {
 		rateText += " " + m.countdown
 	}
+	if m.staleAge > 0 {
+		rateText += fmt.Sprintf(" ⏱ %s old", m.staleAge.Round(time.Second))
+	}
 
-	header := headerStyle.Render(fmt.Sprintf(" %s │ %s │ %s ", filterText, sortText, rateText))
-	s.WriteString(header)
+	header := fmt.Sprintf(" %s │ %s │ %s ", filterText, sortText, rateText)
+	if m.searching || m.search != "" {
+		cursor := ""
+		if m.searching {
+			cursor = "▏"
+		}
+		header += fmt.Sprintf("│ Search: %s%s (%d) ", m.search, cursor, m.matches)
+	}
+	s.WriteString(headerStyle.Render(header))
+	if m.paused {
+		s.WriteString(" " + alertStyle.Render(" ⏸ PAUSED "))
+	}
+	if m.expected > 0 {
+		summary := fmt.Sprintf(" Expectations: %d/%d met ", m.expected-m.alerts, m.expected)
+		if m.alerts > 0 {
+			s.WriteString(" " + alertStyle.Render(fmt.Sprintf(" ALERT: %d │%s", m.alerts, summary)))
+		} else {
+			s.WriteString(" " + onlineStyle.Render(summary))
+		}
+	}
 	s.WriteString("\n\n")
+	if m.showLegend {
+		s.WriteString(renderLegend())
+		s.WriteString("\n\n")
+	}
 	return s.String()
 }
 
+// renderLegend explains row colors and status symbols using the list styles
+func renderLegend() string {
+	items := []string{
+		onlineStyle.Render("✓ online"),
+		newOnlineStyle.Render("✓ recovered (<20s)"),
+		lossyStyle.Render("✓ lossy (loss over -loss-threshold)"),
+		degradedStyle.Render("~ degraded (RTT over -online-max-rtt)"),
+		offlineStyle.Render("✗ offline"),
+		alertStyle.Render("! alert (expect= not met)"),
+		selectedStyle.Render("selected"),
+	}
+	return " " + strings.Join(items, "  ")
+}
+
 func (m HeaderModel) getFilterModeString() string {
-	switch m.filterMode {
+	return filterModeName(m.filterMode)
+}
+
+func filterModeName(mode FilterMode) string {
+	switch mode {
 	case FilterAll:
 		return "All"
 	case FilterSmart:
@@ -76,6 +140,8 @@ func (m HeaderModel) getSortModeString() string {
 		return "Last Seen"
 	case SortByIP:
 		return "IP"
+	case SortByUptime:
+		return "Uptime"
 	default:
 		return "Unknown"
 	}
@@ -98,54 +164,128 @@ func (m HeaderModel) getUpdateRateString() string {
 
 // FooterModel handles the bottom help bar
 type FooterModel struct {
-	width       int
-	showDetails bool
+	width         int
+	showDetails   bool
+	heatmap       bool
+	searching     bool // typing a search query
+	session       bool // -session-file given, save/load keys are active
+	confirmQuit   bool // asking before quitting
+	movingColumns bool // o was pressed, digits move columns
 }
 
 func NewFooterModel() FooterModel {
 	return FooterModel{}
 }
 
+func (m FooterModel) sessionHelp() string {
+	if !m.session {
+		return ""
+	}
+	return " │ ctrl+s/ctrl+o: save/reload session"
+}
+
 func (m FooterModel) View() string {
 	var s strings.Builder
 	s.WriteString("\n")
-	if m.showDetails {
-		s.WriteString(helpStyle.Render("esc: back │ q: quit"))
+	if m.confirmQuit {
+		s.WriteString(alertStyle.Render("Quit? (y/n)"))
+	} else if m.movingColumns {
+		s.WriteString(helpStyle.Render("1-9: move that column left (the first one moves to the end) │ any other key: done"))
+	} else if m.searching {
+		s.WriteString(helpStyle.Render("type to search host names and addresses │ backspace: delete │ enter: keep and navigate │ esc: clear"))
+	} else if m.showDetails {
+		s.WriteString(helpStyle.Render("↑↓/jk: scroll │ g/G: top/bottom │ D: re-resolve DNS │ esc: back │ q: quit"))
+	} else if m.heatmap {
+		s.WriteString(helpStyle.Render("←↑↓→: select │ enter: details │ m: list view │ e: edit hosts │ l: legend │ q: quit"))
+		s.WriteString("\n")
+		s.WriteString(helpStyle.Render("f: cycle filters (smart/online/offline/all) │ i: invert filter │ c: cycle groups │ s: cycle sort (name/status/rtt/last/ip/uptime) │ S: reverse sort │ r: cycle rate (100ms/1s/5s/30s)" + m.sessionHelp()))
 	} else {
-		s.WriteString(helpStyle.Render("↑↓/jk: navigate │ enter: details │ e: edit hosts │ 1-6: toggle columns │ q: quit"))
+		s.WriteString(helpStyle.Render("↑↓/jk: navigate │ g/G: first/last │ enter: details │ /: search │ e: edit hosts │ 1-9: toggle columns │ o: move columns │ t: abs/rel time │ h: show target │ m: heatmap │ p: pause │ x: export csv │ l: legend │ ?: help │ q: quit"))
 		s.WriteString("\n")
-		s.WriteString(helpStyle.Render("f: cycle filters (smart/online/offline/all) │ s: cycle sort (name/status/rtt/last/ip) │ r: cycle rate (100ms/1s/5s/30s)"))
+		s.WriteString(helpStyle.Render("f: cycle filters (smart/online/offline/all) │ i: invert filter │ c: cycle groups │ s: cycle sort (name/status/rtt/last/ip/uptime) │ S: reverse sort │ r: cycle rate (100ms/1s/5s/30s)" + m.sessionHelp()))
 	}
 	return s.String()
 }
 
 // HostListModel handles the list of hosts
 type HostListModel struct {
-	wrappers       []PingWrapperInterface
-	cursor         int
-	scrollOffset   int
-	width          int
-	height         int
-	visibleColumns map[int]bool
-	statsCache     map[string]PWStats
-	filterMode     FilterMode
-	sortMode       SortMode
-	hiddenHosts    map[string]bool
-	cachedWrappers []PingWrapperInterface
+	wrappers         []monitor.PingWrapperInterface
+	cursor           int
+	scrollOffset     int
+	width            int
+	height           int
+	visibleColumns   map[int]bool
+	columnOrder      []int // display order of the columns 1-9, see orderedColumns
+	statsCache       map[string]monitor.PWStats
+	filterMode       FilterMode
+	sortMode         SortMode
+	sortReversed     bool // S, see reverseSorted
+	hiddenHosts      map[string]bool
+	cachedWrappers   []monitor.PingWrapperInterface
 	cacheInvalidated bool
+	absoluteTime     bool // show wall-clock timestamps instead of "ago"
+	lastReplyOnline  bool // show last reply for online hosts, not only offline ones
+	totalHosts       int  // hosts before filtering, for the empty list hint
+	showTarget       bool // append the host as given when a DNS name is shown
+	widths           ColumnWidths
+	rttBands         RTTBands
+	heatmap          bool   // one colored cell per host instead of rows
+	stale            bool   // stats are older than staleAfter, rows are dimmed
+	search           string // lowercase substring hosts must contain, empty = all
+	group            string // host file group hosts must be in (c), empty = all
+}
+
+// ColumnWidths are the preferred list column widths, shrunk down to
+// minColumnWidths when the terminal is too narrow
+type ColumnWidths struct {
+	Name      int
+	IP        int
+	RTT       int
+	LastReply int
+	LastLoss  int
+}
+
+// RTTBands are the -rtt-warn and -rtt-crit thresholds RTTs are colored by.
+// A zero threshold disables its band.
+type RTTBands struct {
+	Warn time.Duration
+	Crit time.Duration
 }
 
+// Band returns "good", "warn" or "crit" for rtt, also the CSS class of the
+// band in the web view
+func (b RTTBands) Band(rtt time.Duration) string {
+	switch {
+	case b.Crit > 0 && rtt >= b.Crit:
+		return "crit"
+	case b.Warn > 0 && rtt >= b.Warn:
+		return "warn"
+	default:
+		return "good"
+	}
+}
+
+var (
+	defaultColumnWidths = ColumnWidths{Name: 32, IP: 18, RTT: 10, LastReply: 16, LastLoss: 16}
+	minColumnWidths     = ColumnWidths{Name: 15, IP: 12, RTT: 8, LastReply: 12, LastLoss: 12}
+)
+
 func NewHostListModel() HostListModel {
 	visibleCols := make(map[int]bool)
 	for i := 1; i <= 6; i++ {
 		visibleCols[i] = true
 	}
+	visibleCols[7] = false // Loss is opt-in
+	visibleCols[8] = false // Jitter is opt-in
+	visibleCols[9] = false // Uptime is opt-in
 	return HostListModel{
-		cursor:         -1,
-		visibleColumns: visibleCols,
-		statsCache:     make(map[string]PWStats),
-		hiddenHosts:    make(map[string]bool),
-		sortMode:       SortByIP, // Default sort
+		cursor:           -1,
+		visibleColumns:   visibleCols,
+		columnOrder:      defaultColumnOrder(),
+		statsCache:       make(map[string]monitor.PWStats),
+		hiddenHosts:      make(map[string]bool),
+		sortMode:         SortByIP, // Default sort
+		widths:           defaultColumnWidths,
 		cacheInvalidated: true,
 	}
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// csvExportColumns is the header row of an export written with x
+var csvExportColumns = []string{"name", "ip", "status", "rtt", "last_reply", "last_loss", "uptime"}
+
+// exportCSV writes the hosts as currently filtered and sorted to a
+// timestamped CSV file in the working directory
+func (m *TUIModel) exportCSV() {
+	now := time.Now()
+	base := "mping-export-" + now.Format("20060102-150405")
+	filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
+	path := base + ".csv"
+	err := writeCSVExport(path, filtered, m.getCachedStats, now)
+	// Several exports within a second are numbered
+	for n := 2; errors.Is(err, fs.ErrExist) && n < 100; n++ {
+		path = fmt.Sprintf("%s-%d.csv", base, n)
+		err = writeCSVExport(path, filtered, m.getCachedStats, now)
+	}
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("Export failed: %v", err)
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	m.statusMessage = fmt.Sprintf("Exported %d hosts to %s", len(filtered), path)
+}
+
+// writeCSVExport writes one row per host below the header to a new file at
+// path, an existing one is not overwritten. Without hosts only the header is
+// written. Timestamps are RFC 3339, values a host doesn't have yet (no
+// reply, no loss) are left empty.
+func writeCSVExport(path string, wrappers []monitor.PingWrapperInterface, getStats func(monitor.PingWrapperInterface) monitor.PWStats, now time.Time) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	cw.Write(csvExportColumns)
+	for _, w := range wrappers {
+		stats := getStats(w)
+		name := stats.GetHostRepr()
+		if name == "" {
+			name = w.Host()
+		}
+		online := stats.State && stats.ErrorMessage == ""
+		degraded := stats.Degraded && stats.ErrorMessage == ""
+		status, rtt := "offline", ""
+		if online || degraded {
+			status, rtt = "online", stats.LastRTTString
+			if !online {
+				status = "degraded"
+			}
+		}
+		var lastReply, lastLoss, uptime string
+		if stats.LastRecv > 0 {
+			lastReply = time.Unix(0, stats.LastRecv).Format(time.RFC3339)
+		}
+		if stats.LastLossNano > 0 {
+			lastLoss = time.Unix(0, stats.LastLossNano).Format(time.RFC3339)
+		}
+		if pct, ok := stats.UptimePercent(now.UnixNano()); ok {
+			uptime = fmt.Sprintf("%.1f%%", pct)
+		}
+		cw.Write([]string{name, stats.IPRepr, status, rtt, lastReply, lastLoss, uptime})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/babs/multiping/monitor"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	heatmapCell    = "■ " // one host, colored by RTT
+	heatmapCellLen = 2
+	heatmapMaxRTT  = 200 * time.Millisecond // RTT at the red end of the scale
+)
+
+// heatmapStops go from fast (green) over yellow to slow (red)
+var heatmapStops = [][3]float64{
+	{0x4a, 0xde, 0x80},
+	{0xfb, 0xbf, 0x24},
+	{0xf8, 0x71, 0x71},
+}
+
+var heatmapOfflineColor = lipgloss.Color("#4b5563")
+
+// heatmapColumns returns how many cells fit on a line of the given width
+func heatmapColumns(width int) int {
+	cols := (width - 2) / heatmapCellLen
+	if cols < 1 {
+		return 1
+	}
+	return cols
+}
+
+// heatColor maps an RTT onto the green-yellow-red scale
+func heatColor(rtt time.Duration) lipgloss.Color {
+	t := float64(rtt) / float64(heatmapMaxRTT)
+	t = max(0, min(t, 1)) * float64(len(heatmapStops)-1)
+	i := min(int(t), len(heatmapStops)-2)
+	f := t - float64(i)
+	var c [3]int
+	for k := range c {
+		c[k] = int(heatmapStops[i][k] + (heatmapStops[i+1][k]-heatmapStops[i][k])*f)
+	}
+	return lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2]))
+}
+
+// renderHeatmap shows each host as a single cell in a grid filling the
+// terminal width, in the current sort order, with the selected host's
+// summary below.
+func (m *HostListModel) renderHeatmap(wrappers []monitor.PingWrapperInterface, getCachedStats func(monitor.PingWrapperInterface) monitor.PWStats) string {
+	var s strings.Builder
+
+	cols := heatmapColumns(m.width)
+	rows := (len(wrappers) + cols - 1) / cols
+	// Same space as the list: table header and separator become the
+	// selection line and a blank line
+	visibleRows := max(m.height-7, 1)
+
+	first := 0
+	if m.cursor >= 0 && m.cursor/cols >= visibleRows {
+		first = m.cursor/cols - visibleRows + 1
+	}
+
+	selected := "arrows: select │ enter: details │ m: list view"
+	if m.cursor >= 0 && m.cursor < len(wrappers) {
+		stats := getCachedStats(wrappers[m.cursor])
+		selected = fmt.Sprintf("%s │ %s │ %s", stats.DisplayName(m.showTarget), stats.IPRepr, heatmapState(&stats))
+	}
+	s.WriteString(headerStyle.Render(selected))
+	s.WriteString("\n\n")
+
+	for row := first; row < rows && row < first+visibleRows; row++ {
+		s.WriteString(" ")
+		for col := 0; col < cols; col++ {
+			i := row*cols + col
+			if i >= len(wrappers) {
+				break
+			}
+			stats := getCachedStats(wrappers[i])
+			style := lipgloss.NewStyle().Foreground(heatmapOfflineColor)
+			if (stats.State || stats.Degraded) && stats.ErrorMessage == "" {
+				style = style.Foreground(heatColor(stats.LastRTT))
+			}
+			if i == m.cursor {
+				style = style.Background(lipgloss.Color("#3b82f6"))
+			}
+			s.WriteString(style.Render(heatmapCell))
+		}
+		s.WriteString("\n")
+	}
+
+	if rows > visibleRows {
+		s.WriteString(helpStyle.Render(fmt.Sprintf(" [rows %d-%d/%d] ", first+1, min(first+visibleRows, rows), rows)))
+	}
+	return s.String()
+}
+
+// rowStep is how far up/down moves the cursor: a grid row in the heatmap
+func (m *HostListModel) rowStep() int {
+	if m.heatmap {
+		return heatmapColumns(m.width)
+	}
+	return 1
+}
+
+// heatmapState describes a host for the heatmap selection line
+func heatmapState(stats *monitor.PWStats) string {
+	switch {
+	case stats.ErrorMessage != "":
+		return "error: " + stats.ErrorMessage
+	case stats.State:
+		return "online, RTT " + stats.LastRTTString
+	case stats.Degraded:
+		return "degraded, RTT " + stats.LastRTTString
+	default:
+		return "offline"
+	}
+}
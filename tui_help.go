@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// helpBindings returns the key bindings in the order the help overlay lists them
+func (k keyMap) helpBindings() []key.Binding {
+	return []key.Binding{
+		k.Up, k.Down, k.PageUp, k.PageDown, k.Top, k.Bottom, k.Left, k.Right,
+		k.Enter, k.Escape, k.Search,
+		k.MoveColumns, k.FilterCycle, k.FilterFlip, k.GroupCycle, k.SortCycle, k.SortReverse,
+		k.CycleRate, k.TimeMode, k.ShowTarget, k.Legend, k.Heatmap,
+		k.HideHost, k.ShowAll, k.EditHosts, k.SaveSession, k.LoadSession,
+		k.ResolveDNS, k.Pause, k.Export, k.Help, k.Quit,
+	}
+}
+
+// helpExtras are help overlay rows for input that has no key binding
+var helpExtras = [][2]string{
+	{"1-9", "toggle list columns"},
+	{"mouse", "click selects a host, a click on the selected host opens its details, the wheel scrolls"},
+	{"ctrl+n", "new line (host editor)"},
+	{"ctrl+l", "clear all hosts (host editor)"},
+}
+
+// updateHelp handles a key while the help overlay is shown: the movement keys
+// scroll it, ctrl+c still quits and any other key closes it.
+func (m *TUIModel) updateHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyCtrlC:
+		return m, m.requestQuit()
+	case key.Matches(msg, keys.Up):
+		m.helpScroll = max(m.helpScroll-1, 0)
+	case key.Matches(msg, keys.Down):
+		// Upper bound is clamped in renderHelp once the content height is known
+		m.helpScroll++
+	case key.Matches(msg, keys.PageUp):
+		m.helpScroll = max(m.helpScroll-m.helpRows(), 0)
+	case key.Matches(msg, keys.PageDown):
+		m.helpScroll += m.helpRows()
+	default:
+		m.showHelp = false
+	}
+	return m, nil
+}
+
+// helpRows is how many help lines fit below the title, keeping one row for
+// the scroll indicator
+func (m *TUIModel) helpRows() int {
+	return max(m.height-3, 1)
+}
+
+// renderHelp lists every key with its description, wrapping descriptions to
+// the terminal width and scrolling when the list is taller than the terminal
+func (m *TUIModel) renderHelp() string {
+	var rows [][2]string
+	for _, b := range keys.helpBindings() {
+		h := b.Help()
+		rows = append(rows, [2]string{h.Key, h.Desc})
+	}
+	rows = append(rows, helpExtras...)
+
+	keyWidth := 0
+	for _, row := range rows {
+		keyWidth = max(keyWidth, lipgloss.Width(row[0]))
+	}
+	// "  key  description"
+	indent := strings.Repeat(" ", keyWidth+4)
+	descStyle := lipgloss.NewStyle()
+	if m.header.width > 0 {
+		descStyle = descStyle.Width(max(m.header.width-keyWidth-4, 10))
+	}
+
+	var lines []string
+	for _, row := range rows {
+		desc := strings.Split(descStyle.Render(row[1]), "\n")
+		keyCell := accentStyle.Render(row[0]) + strings.Repeat(" ", keyWidth-lipgloss.Width(row[0]))
+		lines = append(lines, "  "+keyCell+"  "+strings.TrimRight(desc[0], " "))
+		for _, d := range desc[1:] {
+			lines = append(lines, indent+strings.TrimRight(d, " "))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Keys"))
+	b.WriteString("\n\n")
+
+	maxLines := m.helpRows()
+	if m.height <= 0 || len(lines) <= maxLines {
+		m.helpScroll = 0
+		b.WriteString(strings.Join(lines, "\n"))
+		b.WriteString("\n")
+		b.WriteString(helpStyle.Render("any key: close"))
+		return b.String()
+	}
+	m.helpScroll = min(max(m.helpScroll, 0), len(lines)-maxLines)
+	end := m.helpScroll + maxLines
+	b.WriteString(strings.Join(lines[m.helpScroll:end], "\n"))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render(fmt.Sprintf("[%d-%d/%d] ↑↓/pgup/pgdown: scroll │ any other key: close", m.helpScroll+1, end, len(lines))))
+	return b.String()
+}
@@ -6,13 +6,39 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/babs/multiping/monitor"
+	"github.com/charmbracelet/lipgloss"
 )
 
-func (m *HostListModel) renderListView(wrappers []PingWrapperInterface, getCachedStats func(PingWrapperInterface) PWStats) string {
+func (m *HostListModel) renderListView(wrappers []monitor.PingWrapperInterface, getCachedStats func(monitor.PingWrapperInterface) monitor.PWStats) string {
 	var s strings.Builder
 
 	if len(wrappers) == 0 {
-		s.WriteString(helpStyle.Render("No hosts match the current filter"))
+		if m.totalHosts == 0 {
+			s.WriteString(helpStyle.Render("No hosts configured (e: edit hosts)"))
+			return s.String()
+		}
+		filter := filterModeName(m.filterMode)
+		if m.group != "" {
+			filter += fmt.Sprintf(", group %s", m.group)
+		}
+		if m.search != "" {
+			filter += fmt.Sprintf(", search %q", m.search)
+		}
+		s.WriteString(helpStyle.Render(fmt.Sprintf("No hosts match the current filter (%s, %d hosts total)", filter, m.totalHosts)))
+		s.WriteString("\n")
+		hint := "f: cycle filters"
+		if m.group != "" {
+			hint += " │ c: cycle groups"
+		}
+		if m.search != "" {
+			hint += " │ /, esc: clear search"
+		}
+		if len(m.hiddenHosts) > 0 {
+			hint += fmt.Sprintf(" │ ins: show %d hidden", len(m.hiddenHosts))
+		}
+		s.WriteString(helpStyle.Render(hint))
 		return s.String()
 	}
 
@@ -20,63 +46,52 @@ func (m *HostListModel) renderListView(wrappers []PingWrapperInterface, getCache
 
 	// Dynamic column widths with toggleable columns
 	statusWidth := 3
-	nameWidth := 32
-	ipWidth := 18
-	rttWidth := 10
-	lastReplyWidth := 16
-	lastLossWidth := 16
-	minName := 15
-	minIP := 12
-	minRTT := 8
-	minLastReply := 12
-	minLastLoss := 12
-
-	// Count visible columns for spacing calculation
-	visibleCount := 0
-	if m.visibleColumns[1] {
-		visibleCount++
-	}
-	if m.visibleColumns[2] {
-		visibleCount++
-	}
-	if m.visibleColumns[3] {
-		visibleCount++
-	}
-	if m.visibleColumns[4] {
-		visibleCount++
-	}
-	if m.visibleColumns[5] {
-		visibleCount++
-	}
-	if m.visibleColumns[6] {
-		visibleCount++
-	}
-
-	spaceCount := visibleCount - 1 // spaces between visible columns
-	if spaceCount < 0 {
-		spaceCount = 0
-	}
-
-	totalWidth := 0
-	if m.visibleColumns[1] {
-		totalWidth += statusWidth
-	}
-	if m.visibleColumns[2] {
-		totalWidth += nameWidth
-	}
-	if m.visibleColumns[3] {
-		totalWidth += ipWidth
-	}
-	if m.visibleColumns[4] {
-		totalWidth += rttWidth
-	}
-	if m.visibleColumns[5] {
-		totalWidth += lastReplyWidth
+	lossWidth := 6   // "100.0%"
+	jitterWidth := 8 // "123.45µs"
+	uptimeWidth := 8 // "9:Uptime"
+	nameWidth := m.widths.Name
+	ipWidth := m.widths.IP
+	rttWidth := m.widths.RTT
+	lastReplyWidth := m.widths.LastReply
+	lastLossWidth := m.widths.LastLoss
+	minName := minColumnWidths.Name
+	minIP := minColumnWidths.IP
+	minRTT := minColumnWidths.RTT
+	minLastReply := minColumnWidths.LastReply
+	minLastLoss := minColumnWidths.LastLoss
+
+	cols := m.orderedColumns()
+	columnWidth := func(c int) int {
+		switch c {
+		case 1:
+			return statusWidth
+		case 2:
+			return nameWidth
+		case 3:
+			return ipWidth
+		case 4:
+			return rttWidth
+		case 5:
+			return lastReplyWidth
+		case 6:
+			return lastLossWidth
+		case 7:
+			return lossWidth
+		case 8:
+			return jitterWidth
+		default:
+			return uptimeWidth
+		}
 	}
-	if m.visibleColumns[6] {
-		totalWidth += lastLossWidth
+	// Column widths plus the spaces between visible columns
+	listWidth := func() int {
+		total := max(len(cols)-1, 0)
+		for _, c := range cols {
+			total += columnWidth(c)
+		}
+		return total
 	}
-	totalWidth += spaceCount
+	totalWidth := listWidth()
 
 	target := m.width - 2
 	if target < 50 {
@@ -101,50 +116,16 @@ shrinkColumns:
 			// We hit mins; break to avoid infinite loop
 			break shrinkColumns
 		}
-		totalWidth = 0
-		if m.visibleColumns[1] {
-			totalWidth += statusWidth
-		}
-		if m.visibleColumns[2] {
-			totalWidth += nameWidth
-		}
-		if m.visibleColumns[3] {
-			totalWidth += ipWidth
-		}
-		if m.visibleColumns[4] {
-			totalWidth += rttWidth
-		}
-		if m.visibleColumns[5] {
-			totalWidth += lastReplyWidth
-		}
-		if m.visibleColumns[6] {
-			totalWidth += lastLossWidth
-		}
-		totalWidth += spaceCount
+		totalWidth = listWidth()
 	}
 
 	// Build table header based on visible columns with dynamic widths
 	var headerParts []string
-	if m.visibleColumns[1] {
-		headerParts = append(headerParts, fmt.Sprintf("%-*s", statusWidth, "1:St"))
-	}
-	if m.visibleColumns[2] {
-		headerParts = append(headerParts, fmt.Sprintf("%-*s", nameWidth, "2:Name"))
-	}
-	if m.visibleColumns[3] {
-		headerParts = append(headerParts, fmt.Sprintf("%-*s", ipWidth, "3:IP"))
-	}
-	if m.visibleColumns[4] {
-		headerParts = append(headerParts, fmt.Sprintf("%-*s", rttWidth, "4:RTT"))
-	}
-	if m.visibleColumns[5] {
-		headerParts = append(headerParts, fmt.Sprintf("%-*s", lastReplyWidth, "5:Last Reply"))
-	}
-	if m.visibleColumns[6] {
-		headerParts = append(headerParts, "6:Last Loss")
+	for _, c := range cols {
+		headerParts = append(headerParts, fmt.Sprintf("%-*s", columnWidth(c), fmt.Sprintf("%d:%s", c, m.getColumnName(c))))
 	}
 
-	headerLine := strings.Join(headerParts, " ")
+	headerLine := strings.TrimRight(strings.Join(headerParts, " "), " ")
 	s.WriteString(headerStyle.Render(headerLine))
 	s.WriteString("\n")
 	// Separator line with minimum width
@@ -156,10 +137,7 @@ shrinkColumns:
 	s.WriteString("\n")
 
 	// Calculate visible range (accounting for header)
-	visibleLines := m.height - 7 // Reduced for header
-	if visibleLines < 1 {
-		visibleLines = 1
-	}
+	visibleLines := m.visibleRows()
 
 	start := m.scrollOffset
 	end := m.scrollOffset + visibleLines
@@ -171,15 +149,22 @@ shrinkColumns:
 	for i := start; i < end; i++ {
 		wrapper := wrappers[i]
 		stats := getCachedStats(wrapper)
-		isOnline := stats.state && stats.error_message == ""
+		isOnline := stats.State && stats.ErrorMessage == ""
+		isDegraded := stats.Degraded && stats.ErrorMessage == ""
 
 		// Column values
 		status := "✓"
-		if !isOnline {
+		if isDegraded {
+			status = "~"
+		} else if !isOnline {
 			status = "✗"
 		}
+		alert := stats.Alert()
+		if alert {
+			status += "!"
+		}
 
-		name := stats.GetHostRepr()
+		name := stats.DisplayName(m.showTarget)
 		if name == "" {
 			name = wrapper.Host()
 		}
@@ -191,7 +176,7 @@ shrinkColumns:
 			}
 		}
 
-		ip := stats.iprepr
+		ip := stats.IPRepr
 		if len(ip) > ipWidth {
 			if ipWidth > 3 {
 				ip = ip[:ipWidth-3] + "..."
@@ -200,59 +185,112 @@ shrinkColumns:
 			}
 		}
 
-		rtt := stats.lastrtt_as_string
-		if !isOnline {
+		rtt := stats.LastRTTString
+		if !isOnline && !isDegraded {
 			rtt = "-"
 		}
 
-		// Only show last reply when host is offline to avoid clutter for healthy hosts
+		// Only show last reply when host is offline to avoid clutter for healthy hosts,
+		// unless explicitly requested for online hosts as well
 		lastReply := "-"
-		if !isOnline {
-			if stats.lastrecv > 0 {
-				lastReply = time.Duration(stats.last_seen_nano).Round(time.Second).String() + " ago"
+		if !isOnline || m.lastReplyOnline {
+			if stats.LastRecv > 0 {
+				lastReply = formatTimestamp(stats.LastRecv, now, time.Second, m.absoluteTime)
 			} else {
 				lastReply = "never"
 			}
 		}
 
 		lastLoss := "-"
-		if stats.last_loss_nano > 0 {
-			lastLoss = fmt.Sprintf("%s ago (%s)",
-				time.Duration(time.Now().UnixNano()-stats.last_loss_nano).Round(time.Second),
-				time.Duration(stats.last_loss_duration).Round(time.Second/10))
+		if stats.LastLossNano > 0 {
+			lastLoss = fmt.Sprintf("%s (%s)",
+				formatTimestamp(stats.LastLossNano, now, time.Second, m.absoluteTime),
+				time.Duration(stats.LastLossDuration).Round(time.Second/10))
 		}
 
-		// Build line based on visible columns with dynamic widths
-		var lineParts []string
-		if m.visibleColumns[1] {
-			lineParts = append(lineParts, fmt.Sprintf("%-*s", statusWidth, status))
-		}
-		if m.visibleColumns[2] {
-			lineParts = append(lineParts, fmt.Sprintf("%-*s", nameWidth, name))
+		loss := "-"
+		if stats.PacketsSent > 0 {
+			loss = fmt.Sprintf("%.1f%%", stats.WindowLossPercent())
 		}
-		if m.visibleColumns[3] {
-			lineParts = append(lineParts, fmt.Sprintf("%-*s", ipWidth, ip))
-		}
-		if m.visibleColumns[4] {
-			lineParts = append(lineParts, fmt.Sprintf("%-*s", rttWidth, rtt))
-		}
-		if m.visibleColumns[5] {
-			lineParts = append(lineParts, fmt.Sprintf("%-*s", lastReplyWidth, lastReply))
+
+		jitter := "-"
+		if isOnline || isDegraded {
+			jitter = monitor.Round(stats.Jitter, 2).String()
 		}
-		if m.visibleColumns[6] {
-			lineParts = append(lineParts, lastLoss)
+
+		uptime := "-"
+		if pct, ok := stats.UptimePercent(now); ok {
+			uptime = fmt.Sprintf("%.1f%%", pct)
 		}
 
-		line := strings.Join(lineParts, " ")
+		// Build line based on visible columns with dynamic widths
+		var lineParts []string
+		rttPart := -1
+		for _, c := range cols {
+			switch c {
+			case 1:
+				lineParts = append(lineParts, fmt.Sprintf("%-*s", statusWidth, status))
+			case 2:
+				lineParts = append(lineParts, fmt.Sprintf("%-*s", nameWidth, name))
+			case 3:
+				lineParts = append(lineParts, fmt.Sprintf("%-*s", ipWidth, ip))
+			case 4:
+				rttPart = len(lineParts)
+				lineParts = append(lineParts, fmt.Sprintf("%-*s", rttWidth, rtt))
+			case 5:
+				lineParts = append(lineParts, fmt.Sprintf("%-*s", lastReplyWidth, lastReply))
+			case 6:
+				lineParts = append(lineParts, fmt.Sprintf("%-*s", lastLossWidth, lastLoss))
+			case 7:
+				lineParts = append(lineParts, fmt.Sprintf("%*s", lossWidth, loss))
+			case 8:
+				lineParts = append(lineParts, fmt.Sprintf("%*s", jitterWidth, jitter))
+			case 9:
+				lineParts = append(lineParts, fmt.Sprintf("%*s", uptimeWidth, uptime))
+			}
+		}
 
+		var style lipgloss.Style
+		rowColor := false // the row's own color, the RTT may be colored apart
 		if i == m.cursor && m.cursor >= 0 {
-			line = selectedStyle.Render(line)
-		} else if isOnline && stats.last_up_transition > 0 && now-stats.last_up_transition < int64(20*time.Second) {
-			line = newOnlineStyle.Render(line)
+			style = selectedStyle
+		} else if alert {
+			style = alertStyle
+		} else if isOnline && stats.LastUpTransition > 0 && now-stats.LastUpTransition < int64(20*time.Second) {
+			style = newOnlineStyle
+		} else if isOnline && stats.Lossy() {
+			style, rowColor = lossyStyle, true
 		} else if isOnline {
-			line = onlineStyle.Render(line)
+			style, rowColor = onlineStyle, true
+		} else if isDegraded {
+			style, rowColor = degradedStyle, true
+		} else {
+			style = offlineStyle
+		}
+		// Dim old values, the selected row stays readable
+		if m.stale && i != m.cursor {
+			style = style.Faint(true)
+		}
+
+		var line string
+		if rowColor && rttPart >= 0 && rtt != "-" {
+			rttStyle := m.rttStyle(stats.LastRTT)
+			if m.stale {
+				rttStyle = rttStyle.Faint(true)
+			}
+			before := strings.Join(lineParts[:rttPart], " ")
+			if before != "" {
+				before += " "
+			}
+			after := strings.TrimRight(strings.Join(lineParts[rttPart+1:], " "), " ")
+			if after != "" {
+				after = " " + after
+			} else {
+				lineParts[rttPart] = strings.TrimRight(lineParts[rttPart], " ")
+			}
+			line = style.Render(before) + rttStyle.Render(lineParts[rttPart]) + style.Render(after)
 		} else {
-			line = offlineStyle.Render(line)
+			line = style.Render(strings.TrimRight(strings.Join(lineParts, " "), " "))
 		}
 
 		s.WriteString(line)
@@ -269,17 +307,34 @@ shrinkColumns:
 	return s.String()
 }
 
+// rttStyle returns the style of an RTT in its -rtt-warn/-rtt-crit band
+func (m *HostListModel) rttStyle(rtt time.Duration) lipgloss.Style {
+	switch m.rttBands.Band(rtt) {
+	case "crit":
+		return offlineStyle
+	case "warn":
+		return rttWarnStyle
+	default:
+		return onlineStyle
+	}
+}
+
+// listHeaderLines are the lines renderListView writes above the rows: the
+// column header and the separator
+const listHeaderLines = 2
+
+// visibleRows returns how many hosts the list shows at once:
+// height - title(1) - header(1) - spacing(1) - table_header(1) - separator(1) - help(2) = height - 7
+func (m *HostListModel) visibleRows() int {
+	return max(m.height-7, 1)
+}
+
 func (m *HostListModel) adjustScroll() {
 	if m.cursor < 0 {
 		return
 	}
 
-	// Calculate available height for list items
-	// height - title(1) - header(1) - spacing(1) - table_header(1) - separator(1) - help(2) = height - 7
-	visibleLines := m.height - 7
-	if visibleLines < 1 {
-		visibleLines = 1
-	}
+	visibleLines := m.visibleRows()
 
 	// Scroll up if cursor is above visible area
 	if m.cursor < m.scrollOffset {
@@ -292,13 +347,20 @@ func (m *HostListModel) adjustScroll() {
 	}
 }
 
-func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, getCachedStats func(PingWrapperInterface) PWStats) []PingWrapperInterface {
+// matchesSearch reports whether the host as given or its display name
+// contains the lowercase query
+func matchesSearch(wrapper monitor.PingWrapperInterface, stats *monitor.PWStats, query string) bool {
+	return strings.Contains(strings.ToLower(wrapper.Host()), query) ||
+		strings.Contains(strings.ToLower(stats.GetHostRepr()), query)
+}
+
+func (m *HostListModel) getFilteredWrappers(wrappers []monitor.PingWrapperInterface, getCachedStats func(monitor.PingWrapperInterface) monitor.PWStats) []monitor.PingWrapperInterface {
 	// Return cached result if valid
 	if !m.cacheInvalidated && m.cachedWrappers != nil {
 		return m.cachedWrappers
 	}
 
-	var filtered []PingWrapperInterface
+	var filtered []monitor.PingWrapperInterface
 
 	for _, wrapper := range wrappers {
 		// Skip hidden hosts
@@ -307,8 +369,14 @@ func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, get
 		}
 
 		stats := getCachedStats(wrapper)
-		isOnline := stats.state && stats.error_message == ""
-		seen := stats.has_ever_received
+		if m.search != "" && !matchesSearch(wrapper, &stats, m.search) {
+			continue
+		}
+		if m.group != "" && stats.Group != m.group {
+			continue
+		}
+		isOnline := stats.State && stats.ErrorMessage == ""
+		seen := stats.HasEverReceived
 
 		switch m.filterMode {
 		case FilterAll:
@@ -334,8 +402,8 @@ func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, get
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := getCachedStats(filtered[i])
 			statsJ := getCachedStats(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 
 			// Push hosts without recent replies to the end
 			if onlineI != onlineJ {
@@ -357,8 +425,8 @@ func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, get
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := getCachedStats(filtered[i])
 			statsJ := getCachedStats(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 			if onlineI != onlineJ {
 				return onlineI
 			}
@@ -368,22 +436,22 @@ func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, get
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := getCachedStats(filtered[i])
 			statsJ := getCachedStats(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 
 			// Push hosts without recent replies to the end
 			if onlineI != onlineJ {
 				return onlineI
 			}
 
-			return statsI.lastrtt < statsJ.lastrtt
+			return statsI.LastRTT < statsJ.LastRTT
 		})
 	case SortByLastSeen:
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := getCachedStats(filtered[i])
 			statsJ := getCachedStats(filtered[j])
-			onlineI := statsI.state && statsI.error_message == ""
-			onlineJ := statsJ.state && statsJ.error_message == ""
+			onlineI := statsI.State && statsI.ErrorMessage == ""
+			onlineJ := statsJ.State && statsJ.ErrorMessage == ""
 
 			// Offline hosts first, then online hosts
 			if onlineI != onlineJ {
@@ -392,28 +460,28 @@ func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, get
 
 			// Among offline hosts: never received replies go last
 			if !onlineI && !onlineJ {
-				if statsI.lastrecv == 0 && statsJ.lastrecv == 0 {
+				if statsI.LastRecv == 0 && statsJ.LastRecv == 0 {
 					return filtered[i].Host() < filtered[j].Host()
 				}
-				if statsI.lastrecv == 0 {
+				if statsI.LastRecv == 0 {
 					return false
 				}
-				if statsJ.lastrecv == 0 {
+				if statsJ.LastRecv == 0 {
 					return true
 				}
-				// Both have received before: sort by last_loss_nano (most recent problem first)
-				return statsI.last_loss_nano > statsJ.last_loss_nano
+				// Both have received before: sort by LastLossNano (most recent problem first)
+				return statsI.LastLossNano > statsJ.LastLossNano
 			}
 
 			// Among online hosts: sort by whether they ever had a loss
-			hasLossI := statsI.last_loss_nano > 0
-			hasLossJ := statsJ.last_loss_nano > 0
+			hasLossI := statsI.LastLossNano > 0
+			hasLossJ := statsJ.LastLossNano > 0
 			if hasLossI != hasLossJ {
 				return hasLossI // hosts with past issues first
 			}
 			if hasLossI && hasLossJ {
 				// Both had losses: sort by most recent loss
-				return statsI.last_loss_nano > statsJ.last_loss_nano
+				return statsI.LastLossNano > statsJ.LastLossNano
 			}
 
 			// Both are stable online hosts with no history of loss: sort by name
@@ -431,8 +499,8 @@ func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, get
 		sort.Slice(filtered, func(i, j int) bool {
 			statsI := getCachedStats(filtered[i])
 			statsJ := getCachedStats(filtered[j])
-			keyI := ipKey(statsI.iprepr)
-			keyJ := ipKey(statsJ.iprepr)
+			keyI := ipKey(statsI.IPRepr)
+			keyJ := ipKey(statsJ.IPRepr)
 			if keyI != nil && keyJ != nil && !bytes.Equal(keyI, keyJ) {
 				return bytes.Compare(keyI, keyJ) < 0
 			}
@@ -444,6 +512,26 @@ func (m *HostListModel) getFilteredWrappers(wrappers []PingWrapperInterface, get
 			}
 			return filtered[i].Host() < filtered[j].Host()
 		})
+	case SortByUptime:
+		now := time.Now().UnixNano()
+		sort.Slice(filtered, func(i, j int) bool {
+			statsI := getCachedStats(filtered[i])
+			statsJ := getCachedStats(filtered[j])
+			uptimeI, okI := statsI.UptimePercent(now)
+			uptimeJ, okJ := statsJ.UptimePercent(now)
+			// Least available first, hosts not observed yet last
+			if okI != okJ {
+				return okI
+			}
+			if uptimeI != uptimeJ {
+				return uptimeI < uptimeJ
+			}
+			return filtered[i].Host() < filtered[j].Host()
+		})
+	}
+
+	if m.sortReversed {
+		reverseSorted(filtered, m.sortMode, getCachedStats)
 	}
 
 	// Update cache
@@ -467,8 +555,13 @@ func (m *HostListModel) getColumnName(colNum int) string {
 		return "Last Reply"
 	case 6:
 		return "Last Loss"
+	case 7:
+		return "Loss"
+	case 8:
+		return "Jitter"
+	case 9:
+		return "Uptime"
 	default:
 		return "Unknown"
 	}
 }
-
@@ -0,0 +1,58 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// mouseWheelStep is how many rows or detail lines one wheel notch scrolls
+const mouseWheelStep = 3
+
+// handleMouse selects a host with a click on its row, and opens its details
+// with a click on the selected row. The wheel scrolls the list or the detail
+// view. Clicks outside the rows, in the heatmap or while editing are ignored.
+func (m *TUIModel) handleMouse(msg tea.MouseMsg) {
+	if m.editingHosts || m.header.searching {
+		return
+	}
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp || msg.Button == tea.MouseButtonWheelDown:
+		step := mouseWheelStep
+		if msg.Button == tea.MouseButtonWheelUp {
+			step = -step
+		}
+		if m.showHelp {
+			// The bottom is clamped in renderHelp
+			m.helpScroll = max(m.helpScroll+step, 0)
+			return
+		}
+		if m.footer.showDetails {
+			// The bottom is clamped in renderDetailView
+			m.detailScroll = max(m.detailScroll+step, 0)
+			return
+		}
+		if m.hostList.heatmap {
+			return
+		}
+		filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
+		maxOffset := max(len(filtered)-m.hostList.visibleRows(), 0)
+		m.hostList.scrollOffset = min(max(m.hostList.scrollOffset+step, 0), maxOffset)
+
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+		if m.showHelp || m.footer.showDetails || m.listTop < 0 {
+			return
+		}
+		row := msg.Y - m.listTop
+		if row < 0 || row >= m.hostList.visibleRows() {
+			return
+		}
+		filtered := m.hostList.getFilteredWrappers(m.repo.GetAll(), m.getCachedStats)
+		index := m.hostList.scrollOffset + row
+		if index >= len(filtered) {
+			return
+		}
+		if index == m.hostList.cursor {
+			m.footer.showDetails = true
+			m.detailScroll = 0
+			return
+		}
+		m.hostList.cursor = index
+	}
+}
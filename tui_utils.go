@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
+	"github.com/babs/multiping/monitor"
 	"net"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
 func nextFilterMode(current FilterMode) FilterMode {
@@ -18,6 +23,55 @@ func nextFilterMode(current FilterMode) FilterMode {
 	}
 }
 
+// invertFilterMode swaps online/offline and all/smart
+func invertFilterMode(current FilterMode) FilterMode {
+	switch current {
+	case FilterOnline:
+		return FilterOffline
+	case FilterOffline:
+		return FilterOnline
+	case FilterSmart:
+		return FilterAll
+	default:
+		return FilterSmart
+	}
+}
+
+// nextGroup returns the group after current among the groups of wrappers,
+// in alphabetical order, then "" for all hosts
+func nextGroup(current string, wrappers []monitor.PingWrapperInterface) string {
+	var groups []string
+	for _, w := range wrappers {
+		if g := w.Stats().Group; g != "" && !slices.Contains(groups, g) {
+			groups = append(groups, g)
+		}
+	}
+	slices.Sort(groups)
+	for _, g := range groups {
+		if g > current {
+			return g
+		}
+	}
+	return ""
+}
+
+// reverseSorted reverses hosts sorted by mode. The name and RTT sorts keep
+// online hosts first, only the order within both groups flips; the other
+// sorts are reversed as a whole, e.g. status lists offline hosts first.
+func reverseSorted(hosts []monitor.PingWrapperInterface, mode SortMode, getStats func(monitor.PingWrapperInterface) monitor.PWStats) {
+	slices.Reverse(hosts)
+	if mode != SortByName && mode != SortByRTT {
+		return
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		statsI := getStats(hosts[i])
+		statsJ := getStats(hosts[j])
+		onlineI := statsI.State && statsI.ErrorMessage == ""
+		onlineJ := statsJ.State && statsJ.ErrorMessage == ""
+		return onlineI && !onlineJ
+	})
+}
+
 func nextSortMode(current SortMode) SortMode {
 	switch current {
 	case SortByName:
@@ -28,6 +82,8 @@ func nextSortMode(current SortMode) SortMode {
 		return SortByLastSeen
 	case SortByLastSeen:
 		return SortByIP
+	case SortByIP:
+		return SortByUptime
 	default:
 		return SortByName
 	}
@@ -48,6 +104,16 @@ func nextUpdateRate(current UpdateRate) UpdateRate {
 	}
 }
 
+// updateRateFor returns the update rate whose interval is d, if any
+func updateRateFor(d time.Duration) (UpdateRate, bool) {
+	for _, rate := range []UpdateRate{UpdateRate100ms, UpdateRate1s, UpdateRate5s, UpdateRate30s} {
+		if updateRateDuration(rate) == d {
+			return rate, true
+		}
+	}
+	return 0, false
+}
+
 func cloneHiddenHosts(src map[string]bool) map[string]bool {
 	dst := make(map[string]bool, len(src))
 	for k, v := range src {
@@ -58,7 +124,7 @@ func cloneHiddenHosts(src map[string]bool) map[string]bool {
 
 func visibleColumnsList(cols map[int]bool) []int {
 	var out []int
-	for i := 1; i <= 6; i++ {
+	for i := 1; i <= 9; i++ {
 		if cols[i] {
 			out = append(out, i)
 		}
@@ -66,17 +132,23 @@ func visibleColumnsList(cols map[int]bool) []int {
 	return out
 }
 
-func parseHostsInput(raw string) []string {
+// parseHostsInput splits the host editor's text into hosts, expanding CIDRs.
+// A CIDR over -max-cidr is an error.
+func parseHostsInput(raw string, includeNetwork bool) ([]string, error) {
 	fields := strings.Fields(raw)
 	var hosts []string
 	for _, item := range fields {
-		if ips, err := ExpandCIDR(item); err == nil {
+		ips, err := ExpandCIDR(item, includeNetwork)
+		switch {
+		case err == nil:
 			hosts = append(hosts, ips...)
-		} else {
+		case errors.Is(err, ErrCIDRTooLarge):
+			return nil, err
+		default:
 			hosts = append(hosts, item)
 		}
 	}
-	return hosts
+	return hosts, nil
 }
 
 func ipKey(s string) []byte {
@@ -89,3 +161,38 @@ func ipKey(s string) []byte {
 	}
 	return ip.To16()
 }
+
+// sparkBlocks are the sparkline levels, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders values as block characters scaled between their min and
+// max. All-equal values render as a flat low line.
+func sparkline(values []time.Duration) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := slices.Min(values), slices.Max(values)
+	out := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if hi > lo {
+			level = int(float64(v-lo) / float64(hi-lo) * float64(len(sparkBlocks)-1))
+		}
+		out[i] = sparkBlocks[level]
+	}
+	return string(out)
+}
+
+// formatTimestamp renders a past UnixNano timestamp either relative to now
+// ("3s ago", rounded to round) or as an absolute wall-clock time. Absolute
+// times omit the date when it is today to keep columns narrow.
+func formatTimestamp(ts int64, now int64, round time.Duration, absolute bool) string {
+	if absolute {
+		t := time.Unix(0, ts)
+		if t.Format("2006-01-02") == time.Unix(0, now).Format("2006-01-02") {
+			return t.Format("15:04:05")
+		}
+		return t.Format("01-02 15:04:05")
+	}
+	return time.Duration(now-ts).Round(round).String() + " ago"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ViewState is the TUI's view saved on quit and restored at the next start,
+// unless -no-view-state is given. Unlike a Session it holds no hosts, only
+// how they are shown.
+type ViewState struct {
+	Filter  string   `json:"filter"`            // as ?filter= of the web server
+	Sort    string   `json:"sort"`              // as ?sort= of the web server
+	Reverse bool     `json:"reverse,omitempty"` // sort order reversed (S)
+	Rate    string   `json:"rate"`              // stats update rate: 100ms, 1s, 5s or 30s
+	Columns []int    `json:"columns"`           // visible list columns
+	Order   []int    `json:"order,omitempty"`   // display order of all columns (o)
+	Hidden  []string `json:"hidden,omitempty"`  // hidden hosts by wrapper Host()
+}
+
+// updateRates lists the update rates in the order r cycles through them
+var updateRates = []UpdateRate{UpdateRate100ms, UpdateRate1s, UpdateRate5s, UpdateRate30s}
+
+// defaultViewStatePath returns where the view state is kept:
+// mping/view.json in the user's config directory
+func defaultViewStatePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mping", "view.json"), nil
+}
+
+// paramName returns the query parameter value of a filter or sort mode
+func paramName[M comparable](params map[string]M, mode M) string {
+	for name, m := range params {
+		if m == mode {
+			return name
+		}
+	}
+	return ""
+}
+
+// NewViewState captures the view of the host list and header
+func NewViewState(hostList *HostListModel, rate UpdateRate) *ViewState {
+	s := &ViewState{
+		Filter:  paramName(filterParams, hostList.filterMode),
+		Sort:    paramName(sortParams, hostList.sortMode),
+		Reverse: hostList.sortReversed,
+		Rate:    updateRateDuration(rate).String(),
+		Columns: visibleColumnsList(hostList.visibleColumns),
+		Order:   slices.Clone(hostList.columnOrder),
+	}
+	for host, hidden := range hostList.hiddenHosts {
+		if hidden {
+			s.Hidden = append(s.Hidden, host)
+		}
+	}
+	slices.Sort(s.Hidden)
+	return s
+}
+
+// LoadViewState reads a view state written by Save. Values it doesn't know,
+// e.g. from another version, are an error so the caller keeps the defaults.
+func LoadViewState(path string) (*ViewState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s ViewState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := filterParams[s.Filter]; !ok {
+		return nil, fmt.Errorf("%s: unknown filter %q", path, s.Filter)
+	}
+	if _, ok := sortParams[s.Sort]; !ok {
+		return nil, fmt.Errorf("%s: unknown sort %q", path, s.Sort)
+	}
+	if _, ok := s.updateRate(); !ok {
+		return nil, fmt.Errorf("%s: unknown rate %q", path, s.Rate)
+	}
+	for _, c := range s.Columns {
+		if c < 1 || c > 9 {
+			return nil, fmt.Errorf("%s: unknown column %d", path, c)
+		}
+	}
+	if s.Order != nil && !isColumnOrder(s.Order) {
+		return nil, fmt.Errorf("%s: column order %v is not the columns 1-9", path, s.Order)
+	}
+	return &s, nil
+}
+
+func (s *ViewState) updateRate() (UpdateRate, bool) {
+	for _, rate := range updateRates {
+		if updateRateDuration(rate).String() == s.Rate {
+			return rate, true
+		}
+	}
+	return 0, false
+}
+
+// Apply restores the view on a new host list and header. Hidden hosts are
+// only hidden again if they are still monitored.
+func (s *ViewState) Apply(hostList *HostListModel, header *HeaderModel, wrappers []monitor.PingWrapperInterface) {
+	hostList.filterMode = filterParams[s.Filter]
+	hostList.sortMode = sortParams[s.Sort]
+	hostList.sortReversed = s.Reverse
+	for c := range hostList.visibleColumns {
+		hostList.visibleColumns[c] = slices.Contains(s.Columns, c)
+	}
+	if s.Order != nil {
+		hostList.columnOrder = slices.Clone(s.Order)
+	}
+	hostList.hiddenHosts = make(map[string]bool)
+	for _, w := range wrappers {
+		if slices.Contains(s.Hidden, w.Host()) {
+			hostList.hiddenHosts[w.Host()] = true
+		}
+	}
+	header.updateRate, _ = s.updateRate()
+}
+
+// Save writes the view state to path, creating its directory
+func (s *ViewState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
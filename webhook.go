@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/babs/multiping/monitor"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	webhookQueueSize = 256             // transitions waiting to be sent, more are dropped
+	webhookTimeout   = 5 * time.Second // per request
+	webhookRetries   = 2               // after a failed request, with webhookBackoff doubling
+	webhookBackoff   = time.Second
+)
+
+// WebhookPayload is the JSON body posted to -webhook for a transition. Text
+// and Content carry the same message for Slack and Discord incoming webhooks,
+// which show those fields.
+type WebhookPayload struct {
+	Host       string `json:"host"`
+	IP         string `json:"ip"`
+	State      string `json:"state"`      // "online" or "offline"
+	Transition string `json:"transition"` // "down to up" or "up to down"
+	Timestamp  string `json:"timestamp"`  // RFC 3339
+	UnixNano   int64  `json:"unixnano"`
+	Text       string `json:"text"`
+	Content    string `json:"content"`
+}
+
+// WebhookSender posts transitions to a URL from its own goroutine, so a slow
+// endpoint never holds up the stats computation. Transitions arriving while
+// the queue is full are dropped and counted.
+type WebhookSender struct {
+	url     string
+	client  *http.Client
+	queue   chan WebhookPayload
+	done    chan struct{}
+	dropped atomic.Int64
+	mu      sync.Mutex // guards closing queue against Send
+	closed  bool
+}
+
+// NewWebhookSender starts a sender posting to url
+func NewWebhookSender(url string) *WebhookSender {
+	s := &WebhookSender{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan WebhookPayload, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Send queues a transition without blocking
+func (s *WebhookSender) Send(rec monitor.TransitionRecord) {
+	state := "offline"
+	if rec.State {
+		state = "online"
+	}
+	host := rec.Host
+	if host == "" {
+		host = rec.Ip
+	}
+	text := fmt.Sprintf("%s (%s) is %s", host, rec.Ip, state)
+	payload := WebhookPayload{
+		Host:       rec.Host,
+		IP:         rec.Ip,
+		State:      state,
+		Transition: rec.Transition,
+		Timestamp:  time.Unix(0, rec.UnixNano).Format(time.RFC3339),
+		UnixNano:   rec.UnixNano,
+		Text:       text,
+		Content:    text,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- payload:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Close sends the queued transitions, waiting at most one request timeout,
+// and stops the sender. Transitions sent after Close are dropped.
+func (s *WebhookSender) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(webhookTimeout):
+	}
+	if n := s.dropped.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "webhook: %d transitions dropped, the endpoint didn't keep up\n", n)
+	}
+}
+
+func (s *WebhookSender) run() {
+	defer close(s.done)
+	for payload := range s.queue {
+		body, _ := json.Marshal(payload)
+		backoff := webhookBackoff
+		for attempt := 0; ; attempt++ {
+			err := s.post(body)
+			if err == nil {
+				break
+			}
+			if attempt == webhookRetries {
+				if monitor.DebugMode {
+					fmt.Fprintf(os.Stderr, "DEBUG: webhook for %s failed: %v\n", payload.Host, err)
+				}
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// post sends one request. Any 2xx status is a success.
+func (s *WebhookSender) post(body []byte) error {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}